@@ -0,0 +1,102 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Markdown blocks a matched checkbox can sit in. Config.SkipBlocks lists the
+// ones whose checkboxes are examples rather than tasks.
+const (
+	blockFenced   = "fenced"   // ``` or ~~~ fenced code block
+	blockIndented = "indented" // code block indented by 4 or more columns
+	blockComment  = "comment"  // <!-- HTML comment -->
+)
+
+// allBlockKinds is the default for Config.SkipBlocks.
+var allBlockKinds = []string{blockFenced, blockIndented, blockComment}
+
+var listItemRe = regexp.MustCompile(`^\s*([-*+]|\d+[.)])(\s|$)`)
+
+// openingFence returns the fence that trimmed (a line without its indent)
+// opens, like "```" or "~~~~", or "".
+func openingFence(trimmed string) string {
+	if !strings.HasPrefix(trimmed, "```") && !strings.HasPrefix(trimmed, "~~~") {
+		return ""
+	}
+	n := len(trimmed) - len(strings.TrimLeft(trimmed, trimmed[:1]))
+	if trimmed[0] == '`' && strings.Contains(trimmed[n:], "`") {
+		return "" // a backtick fence's info string cannot hold backticks
+	}
+	return trimmed[:n]
+}
+
+// closesFence reports whether trimmed closes fence: a run of the same
+// character at least as long, followed only by whitespace.
+func closesFence(trimmed, fence string) bool {
+	if !strings.HasPrefix(trimmed, fence) {
+		return false
+	}
+	return strings.TrimSpace(strings.TrimLeft(trimmed, fence[:1])) == ""
+}
+
+// blockContexts returns the kind of block each of lines sits in ("" for
+// ordinary text), and the 1-based line of a fence that is never closed (or 0).
+//
+// It follows CommonMark where it matters for checkboxes. A fence is closed
+// only by a fence of the same character that is at least as long, so shorter
+// fences nest inside longer ones, and an unterminated fence runs to the end of
+// the file. Indented code needs a blank line before it and is not recognized
+// inside a list, where deeper indentation nests list items. An HTML comment is
+// a block starting with "<!--" and runs to the line holding "-->".
+func blockContexts(lines []string) ([]string, int) {
+	kinds := make([]string, len(lines))
+	fence, fenceLine := "", 0
+	inComment, inIndented, inList := false, false, false
+	prevBlank := true
+	for i, line := range lines {
+		line = strings.TrimRight(line, "\r\n")
+		trimmed := strings.TrimLeft(line, " \t")
+		blank := trimmed == ""
+		indent := indentWidth(line)
+
+		switch {
+		case fence != "":
+			kinds[i] = blockFenced
+			if closesFence(trimmed, fence) {
+				fence = ""
+			}
+		case inComment:
+			kinds[i] = blockComment
+			inComment = !strings.Contains(line, "-->")
+		case inIndented && (blank || indent >= 4):
+			kinds[i] = blockIndented
+		case blank:
+			inIndented = false
+		default:
+			if indent >= 4 && prevBlank && !inList {
+				inIndented = true
+				kinds[i] = blockIndented
+				break
+			}
+			inIndented = false
+			if listItemRe.MatchString(line) {
+				inList = true
+			} else if indent == 0 {
+				inList = false
+			}
+			if f := openingFence(trimmed); f != "" {
+				fence, fenceLine = f, i+1
+				kinds[i] = blockFenced
+			} else if strings.HasPrefix(trimmed, "<!--") {
+				kinds[i] = blockComment
+				inComment = !strings.Contains(trimmed[4:], "-->")
+			}
+		}
+		prevBlank = blank
+	}
+	if fence == "" {
+		fenceLine = 0
+	}
+	return kinds, fenceLine
+}
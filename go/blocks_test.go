@@ -0,0 +1,125 @@
+package main
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestBlockContexts(t *testing.T) {
+	const (
+		F = blockFenced
+		I = blockIndented
+		C = blockComment
+	)
+	cases := []struct {
+		name  string
+		lines []string
+		kinds []string
+		open  int
+	}{
+		{"backtick fence",
+			[]string{"- [ ] real", "```markdown", "- [ ] example", "```", "- [ ] real"},
+			[]string{"", F, F, F, ""}, 0},
+		{"tilde fence in a list item",
+			[]string{"- [ ] real", "  ~~~", "  - [ ] example", "  ~~~", "  - [ ] sub"},
+			[]string{"", F, F, F, ""}, 0},
+		{"nested fences",
+			[]string{"````md", "```", "- [ ] example", "```", "- [ ] still example", "````", "- [ ] real"},
+			[]string{F, F, F, F, F, F, ""}, 0},
+		{"other fence character does not close",
+			[]string{"~~~", "```", "- [ ] example", "~~~~~", "- [ ] real"},
+			[]string{F, F, F, F, ""}, 0},
+		{"closing fence with text is not a close",
+			[]string{"```", "``` not a close", "- [ ] example", "```  ", "- [ ] real"},
+			[]string{F, F, F, F, ""}, 0},
+		{"unterminated fence runs to the end",
+			[]string{"- [ ] real", "", "```", "- [ ] example", "", "- [ ] example"},
+			[]string{"", "", F, F, F, F}, 3},
+		{"inline backticks are not a fence",
+			[]string{"```code``` in text", "- [ ] real"},
+			[]string{"", ""}, 0},
+		{"indented code after a paragraph",
+			[]string{"Example:", "", "    - [ ] example", "", "    - [ ] example", "- [ ] real"},
+			[]string{"", "", I, I, I, ""}, 0},
+		{"deep list items are not code",
+			[]string{"- [ ] real", "", "    - [ ] sub", "        - [ ] subsub", "", "    - [ ] sub"},
+			[]string{"", "", "", "", "", ""}, 0},
+		{"indented line continuing a paragraph is not code",
+			[]string{"Text", "    - [ ] real"},
+			[]string{"", ""}, 0},
+		{"comment",
+			[]string{"<!--", "- [ ] example", "-->", "- [ ] real", "<!-- - [ ] example -->", "- [ ] real <!-- note -->"},
+			[]string{C, C, C, "", C, ""}, 0},
+		{"fence inside a comment",
+			[]string{"<!--", "```", "-->", "- [ ] real"},
+			[]string{C, C, C, ""}, 0},
+		{"comment inside a fence",
+			[]string{"```", "<!--", "```", "- [ ] real"},
+			[]string{F, F, F, ""}, 0},
+	}
+	for _, c := range cases {
+		kinds, open := blockContexts(c.lines)
+		if !reflect.DeepEqual(kinds, c.kinds) || open != c.open {
+			t.Errorf("%s: got %q (open %d), want %q (open %d)", c.name, kinds, open, c.kinds, c.open)
+		}
+	}
+}
+
+func TestScanSkipsCodeAndComments(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"doc.md": strings.Join([]string{
+			"# How to write tasks",
+			"- [ ] Real task",
+			"```",
+			"- [ ] Fenced example",
+			"```",
+			"<!--",
+			"- [ ] Commented out",
+			"-->",
+			"Indented:",
+			"",
+			"    - [ ] Indented example",
+			"",
+			"- [ ] Another real task",
+			"    - [ ] Its subtask",
+			"",
+		}, "\n"),
+		"open.md": "- [ ] Before\n```\n- [ ] After an unterminated fence\n",
+	})
+
+	bodies := func(cfg Config) []string {
+		t.Helper()
+		cfg.ScanBackend = "go"
+		ctx := NewParseContext(cfg)
+		tasks, _, err := scanVault([]string{dir}, ctx, cfg, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out []string
+		for _, task := range tasks {
+			out = append(out, task.Body)
+		}
+		sort.Strings(out)
+		return out
+	}
+
+	want := []string{"Another real task", "Before", "Its subtask", "Real task"}
+	if got := bodies(Config{}); !reflect.DeepEqual(got, want) {
+		t.Errorf("default: got %q, want %q", got, want)
+	}
+	if got := bodies(Config{Index: true, StateDir: t.TempDir()}); !reflect.DeepEqual(got, want) {
+		t.Errorf("index: got %q, want %q", got, want)
+	}
+
+	want = []string{"Another real task", "Before", "Commented out", "Its subtask", "Real task"}
+	if got := bodies(Config{SkipBlocks: []string{"fenced", "indented"}}); !reflect.DeepEqual(got, want) {
+		t.Errorf("comments kept: got %q, want %q", got, want)
+	}
+
+	if got := bodies(Config{SkipBlocks: []string{"none"}}); len(got) != 8 {
+		t.Errorf("none: got %q, want all 8 checkboxes", got)
+	}
+}
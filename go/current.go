@@ -0,0 +1,158 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// currentStatus is the running task as `task current --json` prints it and
+// `task current --format` templates see it. Tags and Duration come from the
+// task's line, which is the only file read; they are empty if the line
+// cannot be found.
+type currentStatus struct {
+	currentTaskResult
+	Start            string   `json:"start"`   // start time in the configured time format
+	Elapsed          string   `json:"elapsed"` // "45m", "1h05m"
+	ElapsedSeconds   int64    `json:"elapsed_seconds"`
+	Tags             []string `json:"tags"`
+	Duration         string   `json:"duration,omitempty"` // the task's estimate, "30m"
+	DurationSeconds  int64    `json:"duration_seconds,omitempty"`
+	Remaining        string   `json:"remaining,omitempty"`         // estimate left, if not used up
+	Over             string   `json:"over,omitempty"`              // time past the estimate, if used up
+	RemainingSeconds *int64   `json:"remaining_seconds,omitempty"` // negative once over
+}
+
+// humanizeDuration renders d in minutes, or hours and minutes from an hour
+// up ("45m", "1h05m").
+func humanizeDuration(d time.Duration) string {
+	m := int64(d / time.Minute)
+	if m < 60 {
+		return fmt.Sprintf("%dm", m)
+	}
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// currentTaskLine parses the running task's line, found by block ID or, if
+// the line no longer holds it, by fingerprint or name within its file.
+// Nothing is printed and ok is false when the task cannot be found.
+func currentTaskLine(ctx *ParseContext, ct *CurrentTask) (task Task, ok bool) {
+	lineNum := ct.LineNumber
+	if ct.ID != "" {
+		if n, err := findBlockIDLine(ct.FilePath, ct.ID); err == nil {
+			return readTaskLine(ctx, ct.FilePath, n)
+		}
+	}
+	if ct.Name != "" || ct.Fingerprint != "" {
+		var err error
+		if lineNum, err = relocateTask(ctx, ct.FilePath, lineNum, matchCurrentTask(ctx, *ct)); err != nil {
+			return Task{}, false
+		}
+	}
+	return readTaskLine(ctx, ct.FilePath, lineNum)
+}
+
+// readTaskLine is readTaskAt reporting failure as ok == false.
+func readTaskLine(ctx *ParseContext, filePath string, lineNum int) (Task, bool) {
+	task, err := readTaskAt(ctx, filePath, lineNum)
+	return task, err == nil
+}
+
+// buildCurrentStatus fills in the elapsed time and, from the task's line, its
+// tags and estimate.
+func buildCurrentStatus(ctx *ParseContext, ct *CurrentTask, now time.Time) currentStatus {
+	start := time.Unix(ct.StartTime, 0).In(time.Local)
+	elapsed := now.Sub(start)
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	s := currentStatus{
+		currentTaskResult: currentTaskResult{Name: ct.Name, File: ct.FilePath, Line: ct.LineNumber, StartTime: ct.StartTime, ID: ct.ID},
+		Start:             start.Format(ctx.formats.GoTime),
+		Elapsed:           humanizeDuration(elapsed),
+		ElapsedSeconds:    int64(elapsed / time.Second),
+		Tags:              []string{},
+	}
+	task, ok := currentTaskLine(ctx, ct)
+	if !ok {
+		return s
+	}
+	s.Line = task.LineNumber
+	if task.Tags != nil {
+		s.Tags = task.Tags
+	}
+	if d, err := time.ParseDuration(task.Duration); err == nil && d > 0 {
+		left := int64((d - elapsed) / time.Second)
+		s.Duration, s.DurationSeconds, s.RemainingSeconds = task.Duration, int64(d/time.Second), &left
+		if elapsed <= d {
+			s.Remaining = humanizeDuration(d - elapsed)
+		} else {
+			s.Over = humanizeDuration(elapsed - d)
+		}
+	}
+	return s
+}
+
+// cmdCurrent prints the running task: its name, a text/template over
+// currentStatus with --format, or currentStatus as JSON with --json. It reads
+// the state file and the task's own file only, never the vault, so that
+// statuslines can poll it.
+//
+//	task current [--format TEMPLATE | --json]
+func cmdCurrent(ctx *ParseContext, args []string, cfg Config) error {
+	return current(os.Stdout, ctx, args, cfg, time.Now().In(time.Local))
+}
+
+// current is cmdCurrent writing to out, with the clock passed in.
+func current(out io.Writer, ctx *ParseContext, args []string, cfg Config, now time.Time) error {
+	fs := flag.NewFlagSet("current", flag.ContinueOnError)
+	format := fs.String("format", "", `text/template for the output, e.g. "{{.Name}} {{.Elapsed}}"`)
+	asJSON := fs.Bool("json", false, "print the task as a JSON object, or null")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "" && *asJSON {
+		return fmt.Errorf("--format and --json are exclusive")
+	}
+	var tmpl *template.Template
+	if *format != "" {
+		var err error
+		tmpl, err = template.New("current").Funcs(template.FuncMap{"join": strings.Join}).Parse(*format)
+		if err != nil {
+			return fmt.Errorf("--format: %w", err)
+		}
+	}
+
+	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+	switch {
+	case ct == nil && *asJSON:
+		_, err = fmt.Fprintln(out, "null")
+		return err
+	case ct == nil:
+		return nil
+	case tmpl == nil && !*asJSON:
+		_, err = fmt.Fprintln(out, ct.Name)
+		return err
+	}
+
+	s := buildCurrentStatus(ctx, ct, now)
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(s)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, s); err != nil {
+		return fmt.Errorf("--format: %w", err)
+	}
+	_, err = fmt.Fprintln(out, strings.TrimRight(b.String(), "\n"))
+	return err
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCurrent(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("# Work\n- [ ] Fix leak #work #ops <30m> ::start [[2026-02-17]] 09:45\n"), 0644)
+	cfg := Config{StateDir: t.TempDir()}
+	run := func(args ...string) string {
+		t.Helper()
+		var out bytes.Buffer
+		if err := current(&out, defaultCtx, args, cfg, testNow); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		return out.String()
+	}
+
+	if got := run("--json"); got != "null\n" {
+		t.Errorf("no task, --json: %q", got)
+	}
+	if got := run("--format", "{{.Name}}"); got != "" {
+		t.Errorf("no task, --format: %q", got)
+	}
+
+	// The line moved down by one since the task was started
+	start := testNow.Add(-20*time.Minute - 30*time.Second)
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: start.Unix(), Name: "Fix leak", FilePath: path, LineNumber: 1})
+	if got := run(); got != "Fix leak\n" {
+		t.Errorf("plain: %q", got)
+	}
+	got := run("--format", `{{.Name}} [{{.Elapsed}}{{if .Remaining}}, {{.Remaining}} left{{end}}] {{join .Tags ","}} {{.File}}:{{.Line}} since {{.Start}}`)
+	if want := "Fix leak [20m, 9m left] work,ops " + path + ":2 since 09:39\n"; got != want {
+		t.Errorf("format:\n got %q\nwant %q", got, want)
+	}
+
+	var s map[string]interface{}
+	if err := json.Unmarshal([]byte(run("--json")), &s); err != nil {
+		t.Fatal(err)
+	}
+	if s["name"] != "Fix leak" || s["line"] != float64(2) || s["elapsed_seconds"] != float64(1230) || s["duration"] != "30m" ||
+		s["remaining_seconds"] != float64(570) || s["start_time"] != float64(start.Unix()) || s["over"] != nil {
+		t.Errorf("json: %v", s)
+	}
+
+	// Over the estimate
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: testNow.Add(-95 * time.Minute).Unix(), Name: "Fix leak", FilePath: path, LineNumber: 2})
+	if got := run("--format", "{{.Elapsed}} {{.Over}} over {{.RemainingSeconds}}"); got != "1h35m 1h05m over -3900\n" {
+		t.Errorf("over: %q", got)
+	}
+
+	// A task that is gone still reports what the state file holds
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: testNow.Unix(), Name: "Gone", FilePath: filepath.Join(dir, "missing.md"), LineNumber: 3})
+	if got := run("--json"); !strings.Contains(got, `"name":"Gone"`) || !strings.Contains(got, `"tags":[]`) || strings.Contains(got, "duration") {
+		t.Errorf("missing file: %s", got)
+	}
+
+	var out bytes.Buffer
+	if err := current(&out, defaultCtx, []string{"--format", "{{.Nope"}, cfg, testNow); err == nil {
+		t.Error("want an error for a bad template")
+	}
+	if err := current(&out, defaultCtx, []string{"--format", "{{.Name}}", "--json"}, cfg, testNow); err == nil {
+		t.Error("want an error for --format with --json")
+	}
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var keywordHyphenRe = regexp.MustCompile(`-([a-z])`)
+
+// parseDeferTarget resolves the --to value of `task defer`: anything
+// parseQueryDate accepts (a date, today, tomorrow, +3d, +2w, +5bd), a weekday
+// ("monday", "next-monday": the first one after today), or a period start or
+// end ("start_of_month", "end_of_week": its first or last day, honoring
+// week_start), optionally with offsets ("end_of_week+1w"). A bare offset
+// counts from due, the task's current due date, or from today if due is nil.
+func parseDeferTarget(s string, now time.Time, due *time.Time, ctx *ParseContext) (time.Time, error) {
+	today := extractDate(now)
+	// next-monday and end-of-week spell keywords with hyphens; a hyphen before
+	// a digit is an offset
+	kw := keywordHyphenRe.ReplaceAllString(strings.ToLower(strings.TrimSpace(s)), "_$1")
+	if wd, ok := weekdayNames[strings.TrimPrefix(kw, "next_")]; ok {
+		return NextOccurrence(strings.ToLower(wd.String()), today)
+	}
+	if strings.HasPrefix(kw, "start_of_") {
+		return resolveCalendarKeyword(kw, today, ctx.weekStart)
+	}
+	if strings.HasPrefix(kw, "end_of_") {
+		cutoff, err := resolveCalendarKeyword(kw, today, ctx.weekStart)
+		if err != nil {
+			return time.Time{}, err
+		}
+		return cutoff.AddDate(0, 0, -1), nil // cutoffs are exclusive
+	}
+	from := now
+	if due != nil && queryRelDateRe.MatchString(s) {
+		from = *due
+	}
+	d, err := parseQueryDate(s, from, ctx.formats.GoDate)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --to %q (want a date, today, tomorrow, +Nd, next-monday or end_of_week)", s)
+	}
+	return d, nil
+}
+
+// frontmatterDueLine finds the due key inside the frontmatter block at the
+// top of lines. It returns the line index and the [start, end) byte range of
+// the date within it, or -1 if there is no such key.
+func frontmatterDueLine(lines []string, key string) (int, int, int) {
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return -1, 0, 0
+	}
+	re := regexp.MustCompile(`^` + regexp.QuoteMeta(key) + `\s*:\s*["']?([^"'\s]+)`)
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "---" {
+			break
+		}
+		if loc := re.FindStringSubmatchIndex(lines[i]); loc != nil {
+			return i, loc[2], loc[3]
+		}
+	}
+	return -1, 0, 0
+}
+
+// inheritedDue returns the due date an undated task takes from the file's
+// frontmatter (see MergeFrontmatterDue), or nil.
+func inheritedDue(task Task, ctx *ParseContext) *time.Time {
+	if task.DueDate != nil {
+		return nil
+	}
+	tasks := []Task{task}
+	MergeFrontmatterDue(tasks, ctx.frontmatter, ctx.formats.GoDate, nil)
+	return tasks[0].DueDate
+}
+
+// deferLine adds the deferral bookkeeping to line idx: an ::original marker
+// with the first due date (unless one is already there) and a ::deferral
+// marker. With a non-nil to the due date moves as well: the inline date group
+// is rewritten or, for a task that inherits its date, the frontmatter due key.
+func deferLine(lines []string, idx int, to *time.Time, now time.Time, ctx *ParseContext, filePath string) error {
+	line := lines[idx]
+	dateLoc := ctx.dateRe.FindStringSubmatchIndex(line)
+
+	var current string
+	fmIdx := -1
+	var fmStart, fmEnd int
+	if dateLoc != nil {
+		current = line[dateLoc[2]:dateLoc[3]]
+	} else if to != nil {
+		// The inherited date comes from the parsed frontmatter rather than
+		// lines, which may already have moved it for another task.
+		task, err := ParseTask(RawMatch{Path: filePath, LineNumber: idx + 1, Text: line}, ctx)
+		if err == nil {
+			if due := inheritedDue(task, ctx); due != nil {
+				current = due.Format(ctx.formats.GoDate)
+				fmIdx, fmStart, fmEnd = frontmatterDueLine(lines, ctx.frontmatter.DueKeyResolved())
+			}
+		}
+		if fmIdx < 0 {
+			return fmt.Errorf("line %d has no due date to move", idx+1)
+		}
+	}
+
+	// If no ::original marker, copy the current date as ::original
+	if current != "" && !strings.Contains(line, ctx.markerPrefix+"original") {
+		line = appendToTaskLine(line, fmt.Sprintf("%soriginal [[%s]]", ctx.markerPrefix, current))
+	}
+
+	// Append ::deferral marker
+	line = appendToTaskLine(line, FormatMarker("deferral", now, ctx))
+
+	if to != nil {
+		newDate := to.Format(ctx.formats.GoDate)
+		if dateLoc != nil {
+			line = line[:dateLoc[2]] + newDate + line[dateLoc[3]:]
+		} else {
+			fm := lines[fmIdx]
+			lines[fmIdx] = fm[:fmStart] + newDate + fm[fmEnd:]
+		}
+	}
+	lines[idx] = line
+	return nil
+}
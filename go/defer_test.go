@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseDeferTarget(t *testing.T) {
+	sundayCtx := NewParseContext(Config{WeekStart: "sunday"})
+	cases := []struct {
+		in   string
+		ctx  *ParseContext
+		want string
+	}{
+		{"2026-03-05", defaultCtx, "2026-03-05"},
+		{"tomorrow", defaultCtx, "2026-02-18"},
+		{"+3d", defaultCtx, "2026-02-20"},
+		{"+2w", defaultCtx, "2026-03-03"},
+		{"next-monday", defaultCtx, "2026-02-23"},
+		{"monday", defaultCtx, "2026-02-23"},
+		{"next-tuesday", defaultCtx, "2026-02-24"},
+		{"end_of_week", defaultCtx, "2026-02-22"},
+		{"end-of-week", sundayCtx, "2026-02-21"},
+		{"end_of_month", defaultCtx, "2026-02-28"},
+		{"end_of_year", defaultCtx, "2026-12-31"},
+		{"end_of_week+1w", defaultCtx, "2026-03-01"},
+		{"end-of-month-1d", defaultCtx, "2026-02-27"},
+		{"start_of_month+1m", defaultCtx, "2026-03-01"},
+		{"+5bd", defaultCtx, "2026-02-24"},
+		{"+1m", defaultCtx, "2026-03-17"},
+	}
+	for _, c := range cases {
+		got, err := parseDeferTarget(c.in, testNow, nil, c.ctx)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if got.Format("2006-01-02") != c.want {
+			t.Errorf("%q = %s, want %s", c.in, got.Format("2006-01-02"), c.want)
+		}
+	}
+	// Offsets count from the due date; keywords and dates ignore it
+	due := testNow.AddDate(0, 1, 0) // 2026-03-17
+	for in, want := range map[string]string{"+3d": "2026-03-20", "-1w": "2026-03-10", "+2bd": "2026-03-19",
+		"tomorrow": "2026-02-18", "end_of_week": "2026-02-22", "2026-04-01": "2026-04-01"} {
+		got, err := parseDeferTarget(in, testNow, &due, defaultCtx)
+		if err != nil {
+			t.Errorf("%q from due: %v", in, err)
+		} else if got.Format("2006-01-02") != want {
+			t.Errorf("%q from due = %s, want %s", in, got.Format("2006-01-02"), want)
+		}
+	}
+	for _, bad := range []string{"someday", "end_of_decade", "+3x", "end_of_week+1x"} {
+		if _, err := parseDeferTarget(bad, testNow, nil, defaultCtx); err == nil {
+			t.Errorf("%q should fail", bad)
+		}
+	}
+}
+
+func TestCmdDefer_To(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.md")
+	os.WriteFile(path, []byte("- [ ] Task (@[[2026-02-17]] 09:00) ^tb-0001\n"), 0644)
+
+	if err := cmdDefer(defaultCtx, []string{path, "1", "--to", "2026-03-02"}); err != nil {
+		t.Fatal(err)
+	}
+	line := splitLines(readFile(t, path))[0]
+	if !strings.HasPrefix(line, "- [ ] Task (@[[2026-03-02]] 09:00) ::original [[2026-02-17]] ::deferral [[") {
+		t.Errorf("line = %q", line)
+	}
+	if !strings.HasSuffix(line, " ^tb-0001") {
+		t.Errorf("block ID should stay last: %q", line)
+	}
+
+	// A second deferral keeps the first original date.
+	if err := cmdDefer(defaultCtx, []string{"--to", "2026-03-09", path, "1"}); err != nil {
+		t.Fatal(err)
+	}
+	line = splitLines(readFile(t, path))[0]
+	if !strings.Contains(line, "(@[[2026-03-09]] 09:00)") || strings.Count(line, "::original") != 1 ||
+		!strings.Contains(line, "::original [[2026-02-17]]") || strings.Count(line, "::deferral") != 2 {
+		t.Errorf("line = %q", line)
+	}
+}
+
+func TestCmdDefer_ToOffsetFromDue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.md")
+	os.WriteFile(path, []byte("- [ ] Later (@[[2099-01-10]])\n"), 0644)
+
+	if err := cmdDefer(defaultCtx, []string{path, "1", "--to", "+3d"}); err != nil {
+		t.Fatal(err)
+	}
+	line := splitLines(readFile(t, path))[0]
+	if !strings.HasPrefix(line, "- [ ] Later (@[[2099-01-13]]) ::original [[2099-01-10]] ::deferral [[") {
+		t.Errorf("line = %q", line)
+	}
+}
+
+func TestCmdDefer_ToCustomFormat(t *testing.T) {
+	ctx := NewParseContext(Config{DateFormat: "%d.%m.%Y"})
+	path := filepath.Join(t.TempDir(), "test.md")
+	os.WriteFile(path, []byte("- [ ] Task (@[[17.02.2026]])\n"), 0644)
+
+	if err := cmdDefer(ctx, []string{path, "1", "--to", "05.03.2026"}); err != nil {
+		t.Fatal(err)
+	}
+	line := splitLines(readFile(t, path))[0]
+	if !strings.HasPrefix(line, "- [ ] Task (@[[05.03.2026]]) ::original [[17.02.2026]] ::deferral [[") {
+		t.Errorf("line = %q", line)
+	}
+}
+
+func TestCmdDefer_ToFrontmatterDue(t *testing.T) {
+	ResetFrontmatterCache()
+	path := filepath.Join(t.TempDir(), "project.md")
+	os.WriteFile(path, []byte("---\ntitle: Project\ndue: \"2026-02-17 14:00\"\n---\n- [ ] Inherits\n"), 0644)
+
+	if err := cmdDefer(defaultCtx, []string{path, "5", "--to", "2026-02-20"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, path))
+	if lines[2] != `due: "2026-02-20 14:00"` {
+		t.Errorf("frontmatter due = %q", lines[2])
+	}
+	if !strings.HasPrefix(lines[4], "- [ ] Inherits ::original [[2026-02-17]] ::deferral [[") {
+		t.Errorf("task line = %q", lines[4])
+	}
+}
+
+func TestCmdDefer_ToWithoutDate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.md")
+	orig := "- [ ] Undated\n"
+	os.WriteFile(path, []byte(orig), 0644)
+
+	if err := cmdDefer(defaultCtx, []string{path, "1", "--to", "tomorrow"}); err == nil {
+		t.Error("expected an error for a task without a due date")
+	}
+	if got := readFile(t, path); got != orig {
+		t.Errorf("file changed: %q", got)
+	}
+
+	inherit := false
+	ctx := NewParseContext(Config{Frontmatter: FrontmatterConfig{InheritDue: &inherit}})
+	path = filepath.Join(t.TempDir(), "fm.md")
+	os.WriteFile(path, []byte("---\ndue: 2026-02-17\n---\n- [ ] Not inherited\n"), 0644)
+	if err := cmdDefer(ctx, []string{path, "4", "--to", "tomorrow"}); err == nil {
+		t.Error("frontmatter due should not move when inherit_due is off")
+	}
+}
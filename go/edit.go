@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseTaskTime accepts a time in the configured format, 24-hour "15:04" or
+// 12-hour "3:04pm" / "3pm", and renders it in the configured format.
+func parseTaskTime(s string, ctx *ParseContext) (string, error) {
+	s = strings.TrimSpace(s)
+	for _, layout := range []string{ctx.formats.GoTime, "15:04", "3:04pm", "3:04 pm", "3pm", "3 pm"} {
+		if t, err := time.Parse(layout, strings.ToLower(s)); err == nil {
+			return t.Format(ctx.formats.GoTime), nil
+		}
+		if t, err := time.Parse(layout, s); err == nil {
+			return t.Format(ctx.formats.GoTime), nil
+		}
+	}
+	return "", fmt.Errorf("invalid time %q (want HH:MM or 3:04pm)", s)
+}
+
+// parseTaskDuration accepts a Go duration (45m, 1h30m) or a bare number of
+// minutes and renders it as a task duration ("90m").
+func parseTaskDuration(s string) (string, error) {
+	d, err := parseQueryDuration(strings.TrimSpace(s))
+	if err != nil || d <= 0 || d%time.Minute != 0 {
+		return "", fmt.Errorf("invalid duration %q (want whole minutes, like 30, 45m or 1h30m)", s)
+	}
+	return fmt.Sprintf("%dm", int(d/time.Minute)), nil
+}
+
+// isNone reports whether a flag value asks to clear a field.
+func isNone(s string) bool {
+	return s == "none" || s == ""
+}
+
+// cmdEdit changes the parts of a task line given as flags and writes the line
+// back with SerializeTask, leaving everything else on it as it was.
+//
+//	task edit <file> <line> [--due DATE|none] [--time HH:MM|none]
+//	    [--duration 30m|none] [--priority high|medium|low|none]
+//	    [--add-tag T]... [--remove-tag T]... [--body TEXT]
+func cmdEdit(ctx *ParseContext, args []string) error {
+	var addTags, removeTags tagList
+	fs := flag.NewFlagSet("edit", flag.ContinueOnError)
+	due := fs.String("due", "", "due date (as for defer --to), or none")
+	dueTime := fs.String("time", "", "due time, or none")
+	duration := fs.String("duration", "", "duration (30m, 1h30m, 90), or none")
+	priority := fs.String("priority", "", "priority: high, medium, low, or none")
+	fs.Var(&addTags, "add-tag", "add a tag (repeatable)")
+	fs.Var(&removeTags, "remove-tag", "remove a tag (repeatable)")
+	body := fs.String("body", "", "new task text")
+	filePath, lineNum, err := taskLocationWith(ctx, fs, args, "task edit <filepath> <linenum|^id> [--due D] [--time T] [--duration D] [--priority P] [--add-tag T] [--remove-tag T] [--body B]")
+	if err != nil {
+		return err
+	}
+	set := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if len(set) == 0 || len(set) == 1 && set["expect"] {
+		return fmt.Errorf("nothing to change; pass --due, --time, --duration, --priority, --add-tag, --remove-tag or --body")
+	}
+
+	now := time.Now().In(time.Local)
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNum - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range", lineNum)
+		}
+		task, err := ParseTask(RawMatch{Path: filePath, LineNumber: lineNum, Text: lines[idx]}, ctx)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum, err)
+		}
+
+		if set["due"] {
+			if isNone(*due) {
+				task.DueDate, task.DueTime = nil, ""
+			} else {
+				current := task.DueDate
+				if current == nil {
+					current = inheritedDue(task, ctx)
+				}
+				d, err := parseDeferTarget(*due, now, current, ctx)
+				if err != nil {
+					return nil, err
+				}
+				task.DueDate = &d
+			}
+		}
+		if set["time"] {
+			if isNone(*dueTime) {
+				task.DueTime = ""
+			} else if task.DueTime, err = parseTaskTime(*dueTime, ctx); err != nil {
+				return nil, err
+			}
+		}
+		if set["duration"] {
+			if isNone(*duration) {
+				task.Duration = ""
+			} else if task.Duration, err = parseTaskDuration(*duration); err != nil {
+				return nil, err
+			}
+		}
+		if set["priority"] {
+			if task.Priority, err = parsePriorityName(*priority); err != nil {
+				return nil, err
+			}
+		}
+		if set["body"] {
+			if strings.TrimSpace(*body) == "" {
+				return nil, fmt.Errorf("--body must not be empty")
+			}
+			task.Body = strings.TrimSpace(*body)
+		}
+		for _, tag := range removeTags {
+			tag = strings.TrimPrefix(tag, ctx.tagPrefix)
+			kept := task.Tags[:0:0]
+			for _, existing := range task.Tags {
+				if existing != tag {
+					kept = append(kept, existing)
+				}
+			}
+			task.Tags = kept
+		}
+		for _, tag := range addTags {
+			task.Tags = append(task.Tags, strings.TrimPrefix(tag, ctx.tagPrefix))
+		}
+
+		line, err := SerializeTask(lines[idx], task, ctx)
+		if err != nil {
+			return nil, err
+		}
+		lines[idx] = line
+		return lines, nil
+	})
+}
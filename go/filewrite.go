@@ -0,0 +1,163 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// errFileChanged reports that a file was modified by someone else while it
+// was being rewritten; the rewrite is abandoned rather than clobbering it.
+var errFileChanged = errors.New("file changed while it was being edited")
+
+// rewriteFile replaces the contents of filePath with edit(contents). The new
+// contents are written to a temporary file in the same directory and renamed
+// over the original, so readers never see a half-written file, and the
+// original permissions are kept. An advisory lock (flock) is held on the file
+// meanwhile, serializing taskbuffer processes. Writers that do not lock, such
+// as editors, are caught by re-reading the file just before the rename: if it
+// changed, rewriteFile returns an error wrapping errFileChanged.
+//
+// If the file does not exist and create is set, edit receives nil and the file
+// is created with mode 0644; otherwise edit receives a non-nil slice. A
+// symlinked path is resolved so the link itself is kept.
+func rewriteFile(filePath string, create bool, edit func(data []byte) ([]byte, error)) error {
+	if resolved, err := filepath.EvalSymlinks(filePath); err == nil {
+		filePath = resolved
+	}
+	for {
+		f, err := os.Open(filePath)
+		if errors.Is(err, os.ErrNotExist) && create {
+			err = createFile(filePath, edit)
+			if errors.Is(err, os.ErrExist) {
+				continue // created by someone else meanwhile; edit that
+			}
+			return err
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", filePath, err)
+		}
+		retry, err := rewriteLocked(f, filePath, edit)
+		f.Close()
+		if !retry {
+			return err
+		}
+	}
+}
+
+// rewriteLocked does the work of rewriteFile on an opened file. It reports
+// retry if the file was replaced while waiting for the lock.
+func rewriteLocked(f *os.File, filePath string, edit func([]byte) ([]byte, error)) (retry bool, err error) {
+	if err := lockFile(f); err != nil {
+		return false, fmt.Errorf("locking %s: %w", filePath, err)
+	}
+	defer unlockFile(f)
+
+	// Another process may have renamed a new file into place while we
+	// waited; the lock we hold is then on the old one.
+	info, err := f.Stat()
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	if current, err := os.Stat(filePath); err != nil || !os.SameFile(info, current) {
+		return true, nil
+	}
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	out, err := edit(data)
+	if err != nil {
+		return false, err
+	}
+
+	return false, replaceFile(filePath, out, info.Mode().Perm(), func() error {
+		current, err := os.ReadFile(filePath)
+		if err != nil || !bytes.Equal(current, data) {
+			return fmt.Errorf("%w: %s", errFileChanged, filePath)
+		}
+		return nil
+	})
+}
+
+// createFile creates a missing file with edit(nil). It fails with an error
+// wrapping os.ErrExist if the file appeared in the meantime.
+func createFile(filePath string, edit func([]byte) ([]byte, error)) error {
+	out, err := edit(nil)
+	if err != nil {
+		return err
+	}
+	return replaceFile(filePath, out, 0644, nil)
+}
+
+// replaceFile writes data to a temporary file next to filePath and moves it
+// into place. With a nil check the target must not exist yet; otherwise check
+// runs right before the rename and can veto it.
+func replaceFile(filePath string, data []byte, perm os.FileMode, check func() error) error {
+	dir, base := filepath.Split(filePath)
+	if dir == "" {
+		dir = "."
+	}
+	// A dot-prefixed name keeps `task watch` and most sync clients from
+	// picking up the temporary file.
+	tmp, err := os.CreateTemp(dir, "."+strings.TrimPrefix(base, ".")+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed or linked into place
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+
+	if check == nil {
+		// Link fails if the file exists, unlike rename.
+		if err := os.Link(tmp.Name(), filePath); err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return err
+			}
+			return fmt.Errorf("writing %s: %w", filePath, err)
+		}
+		return nil
+	}
+	if err := check(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filePath); err != nil {
+		return fmt.Errorf("writing %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// editLines is rewriteFile for line-based edits of an existing file. The
+// lines are split on "\n", so a trailing newline shows up as a final empty
+// line and is preserved.
+func editLines(filePath string, edit func(lines []string) ([]string, error)) error {
+	return rewriteFile(filePath, false, func(data []byte) ([]byte, error) {
+		lines, err := edit(strings.Split(string(data), "\n"))
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strings.Join(lines, "\n")), nil
+	})
+}
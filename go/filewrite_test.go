@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// assertNoTempFiles fails if a rewrite left temporary files behind.
+func assertNoTempFiles(t *testing.T, dir string) {
+	t.Helper()
+	entries, _ := os.ReadDir(dir)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("temporary file left behind: %s", e.Name())
+		}
+	}
+}
+
+func TestRewriteFile_PreservesMode(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("- [ ] Task\n"), 0600)
+
+	if err := CheckOffTask(path, 1); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("mode = %v, want 0600", info.Mode().Perm())
+	}
+	if got := readFile(t, path); got != "- [x] Task\n" {
+		t.Errorf("content = %q", got)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestRewriteFile_KeepsSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "real.md")
+	link := filepath.Join(dir, "link.md")
+	os.WriteFile(target, []byte("- [ ] Task\n"), 0644)
+	if err := os.Symlink(target, link); err != nil {
+		t.Skip("symlinks unsupported:", err)
+	}
+
+	if err := AppendToLine(link, 1, "::start [[2026-02-17]] 09:00"); err != nil {
+		t.Fatal(err)
+	}
+	if fi, _ := os.Lstat(link); fi.Mode()&os.ModeSymlink == 0 {
+		t.Error("symlink was replaced by a regular file")
+	}
+	if got := readFile(t, target); !strings.Contains(got, "::start") {
+		t.Errorf("target not updated: %q", got)
+	}
+}
+
+func TestRewriteFile_DetectsConcurrentChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("- [ ] Task\n"), 0644)
+
+	// An editor saves the file between our read and our write.
+	err := rewriteFile(path, false, func(data []byte) ([]byte, error) {
+		os.WriteFile(path, []byte("- [ ] Edited elsewhere\n"), 0644)
+		return []byte("- [x] Task\n"), nil
+	})
+	if !errors.Is(err, errFileChanged) {
+		t.Fatalf("err = %v, want errFileChanged", err)
+	}
+	if got := readFile(t, path); got != "- [ ] Edited elsewhere\n" {
+		t.Errorf("concurrent edit clobbered: %q", got)
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestRewriteFile_EditErrorLeavesFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("one\n"), 0644)
+
+	if err := AppendToLine(path, 5, "x"); err == nil {
+		t.Fatal("expected out-of-range error")
+	}
+	if got := readFile(t, path); got != "one\n" {
+		t.Errorf("content = %q", got)
+	}
+	assertNoTempFiles(t, dir)
+
+	if err := AppendToLine(filepath.Join(dir, "missing.md"), 1, "x"); err == nil {
+		t.Error("editing a missing file should fail")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "missing.md")); err == nil {
+		t.Error("missing file should not be created")
+	}
+}
+
+func TestRewriteFile_Creates(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "inbox.md")
+
+	if err := AppendToFile(path, "- [ ] First"); err != nil {
+		t.Fatal(err)
+	}
+	if err := AppendToFile(path, "- [ ] Second"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "- [ ] First\n- [ ] Second\n" {
+		t.Errorf("content = %q", got)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0644 {
+		t.Errorf("mode = %v, want 0644", info.Mode().Perm())
+	}
+	assertNoTempFiles(t, dir)
+}
+
+func TestRewriteFile_SerializesWriters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte(""), 0644)
+
+	const n = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- AppendToFile(path, fmt.Sprintf("- [ ] Task %d", i))
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	got := readFile(t, path)
+	for i := 0; i < n; i++ {
+		if !strings.Contains(got, fmt.Sprintf("- [ ] Task %d\n", i)) {
+			t.Errorf("lost write %d:\n%s", i, got)
+		}
+	}
+	assertNoTempFiles(t, dir)
+}
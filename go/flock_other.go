@@ -0,0 +1,11 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// lockFile is a no-op where flock is unavailable; rewriteFile still detects
+// concurrent changes before replacing a file.
+func lockFile(f *os.File) error { return nil }
+
+func unlockFile(f *os.File) error { return nil }
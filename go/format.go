@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"sort"
 	"strings"
@@ -76,11 +77,16 @@ type FormatOpts struct {
 	ShowMarkers   bool
 	IgnoreUndated bool
 	TagFilter     []string          // only show tasks matching these tags (OR logic)
+	Query         *Query            // only show tasks matching this query (ANDed with TagFilter)
 	TagPrefix     string            // prefix for tag display (default "#")
 	MarkerPrefix  string            // prefix for marker display (default "::")
 	Horizons      []ResolvedHorizon // resolved horizons; nil uses defaults
 	Overlap       string            // "sorted", "first_match", "narrowest"
 	DateFormat    string            // Go layout for date display (default "2006-01-02")
+	Sort          string            // order within a horizon: "date" (default) or "priority"
+	OverdueByTime bool              // tasks due earlier today, by their time, go with yesterday's
+
+	priorityColumn bool // set by FormatTaskfile when any listed task has a priority
 }
 
 func formatTaskLine(t Task, opts FormatOpts) string {
@@ -118,8 +124,22 @@ func formatTaskLine(t Task, opts FormatOpts) string {
 		b.WriteString("     |")
 	}
 
-	// Body
-	fmt.Fprintf(&b, "\t %s \t", t.Body)
+	// Priority column — 5 chars between pipes, only when some task has one
+	if opts.priorityColumn {
+		mark := ""
+		if t.Priority != 0 {
+			mark = priorityToken(t.Priority, prioritySyntaxBang)
+		}
+		fmt.Fprintf(&b, " %-3s |", mark)
+	}
+
+	// Body, indented under its parent
+	fmt.Fprintf(&b, "\t %s%s \t", strings.Repeat("  ", t.Depth), t.Body)
+
+	// Subtask progress
+	if len(t.Children) > 0 {
+		fmt.Fprintf(&b, " [%d/%d]", t.ChildrenDone, len(t.Children))
+	}
 
 	// Tags (before markers, after body tab)
 	if len(t.Tags) > 0 {
@@ -149,33 +169,53 @@ func formatTaskLine(t Task, opts FormatOpts) string {
 				fmt.Fprintf(&b, " %s", m.Time)
 			}
 		}
+
+		// Notes, one line each under the body, pointing back at the task.
+		// Blank date, time and duration columns without the pipes keep them
+		// apart from task lines.
+		columns := 16
+		if opts.priorityColumn {
+			columns += 6
+		}
+		for _, note := range t.Notes {
+			if note == "" {
+				continue
+			}
+			fmt.Fprintf(&b, "\n%s:%d:1:\t%10s\t%*s\t %s  %s", t.FilePath, t.LineNumber, "", columns, "", strings.Repeat("  ", t.Depth), note)
+		}
 	}
 
 	return b.String()
 }
 
-func taskMatchesTags(t Task, tags []string) bool {
-	for _, filter := range tags {
-		for _, tag := range t.Tags {
-			if tag == filter {
-				return true
-			}
-		}
-	}
-	return false
+// TaskGroup is a horizon label and the tasks bucketed under it, in display order.
+type TaskGroup struct {
+	Label string
+	Tasks []Task
 }
 
-func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
-	// Filter by tags if specified
-	if len(opts.TagFilter) > 0 {
-		var filtered []Task
-		for _, t := range tasks {
-			if taskMatchesTags(t, opts.TagFilter) {
-				filtered = append(filtered, t)
-			}
+// taskBucketDate is the day a dated task is bucketed (and sorted) on: its due
+// date, except that with overdueByTime a task due today at a time already
+// past counts as due yesterday, so it is overdue.
+func taskBucketDate(t Task, now time.Time, overdueByTime bool) time.Time {
+	date := extractDate(*t.DueDate)
+	today := extractDate(now)
+	if overdueByTime && date.Equal(today) {
+		if m, ok := clockMinutes(t.DueTime); ok && m < now.Hour()*60+now.Minute() {
+			return today.AddDate(0, 0, -1)
 		}
-		tasks = filtered
 	}
+	return date
+}
+
+// GroupTasks filters, sorts and buckets tasks into horizons exactly as they
+// appear in the taskfile. Empty horizons are omitted.
+func GroupTasks(tasks []Task, now time.Time, opts FormatOpts) []TaskGroup {
+	// Filter by tags and query if specified
+	tasks = andQueries(tagsQuery(opts.TagFilter), opts.Query).Filter(tasks)
+
+	// Subtasks whose parent is listed are placed under it, not sorted on their own
+	tasks, subtasks := nestSubtasks(tasks)
 
 	// Resolve horizons if not provided
 	horizons := opts.Horizons
@@ -183,15 +223,26 @@ func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
 		horizons, _ = ResolveHorizons(nil, now, time.Monday, "sorted")
 	}
 
-	// Split horizons into dated and undated
+	// Split horizons into dated, undated and untimed
 	var datedHorizons []ResolvedHorizon
-	var undatedHorizon *ResolvedHorizon
+	var undatedHorizon, untimedHorizon *ResolvedHorizon
 	for i := range horizons {
-		if horizons[i].Undated {
-			h := horizons[i]
+		h := horizons[i]
+		switch {
+		case h.Undated:
 			undatedHorizon = &h
-		} else {
-			datedHorizons = append(datedHorizons, horizons[i])
+		case h.Untimed:
+			untimedHorizon = &h
+		default:
+			datedHorizons = append(datedHorizons, h)
+		}
+	}
+
+	// Days that a time-of-day cutoff ("now", "4h", "at:12:00") falls within
+	splitDays := make(map[time.Time]bool)
+	for _, h := range datedHorizons {
+		if day := extractDate(h.Cutoff); !h.Cutoff.Equal(day) {
+			splitDays[day] = true
 		}
 	}
 
@@ -205,11 +256,53 @@ func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
 		}
 	}
 
-	// Sort dated tasks by date, then file path, then line number
+	today := extractDate(now)
+	bucketDate := func(t Task) time.Time {
+		return taskBucketDate(t, now, opts.OverdueByTime)
+	}
+
+	// Today's untimed tasks go to the untimed horizon, if there is one
+	toUntimed := func(t Task) bool {
+		_, timed := clockMinutes(t.DueTime)
+		return untimedHorizon != nil && !timed && bucketDate(t).Equal(today)
+	}
+
+	// dueAt is the moment a task is bucketed at: its due time, or the start
+	// of its day when it has none
+	dueAt := func(t Task) time.Time {
+		day := bucketDate(t)
+		if m, ok := clockMinutes(t.DueTime); ok && day.Equal(extractDate(*t.DueDate)) {
+			y, mo, d := day.Date()
+			return time.Date(y, mo, d, m/60, m%60, 0, 0, day.Location())
+		}
+		return day
+	}
+
+	// sortMinutes orders a day's tasks by time. Untimed tasks go last, except
+	// on a day split by time-of-day cutoffs, where they are bucketed at the
+	// start of the day and so sorted there too
+	sortMinutes := func(t Task) int {
+		if m, ok := clockMinutes(t.DueTime); ok {
+			return m
+		}
+		if splitDays[bucketDate(t)] && !toUntimed(t) {
+			return -1
+		}
+		return 24 * 60
+	}
+
+	// Sort dated tasks by date, then time of day, then file path, then line
+	// number
 	sort.Slice(dated, func(i, j int) bool {
+		if bi, bj := bucketDate(dated[i]), bucketDate(dated[j]); !bi.Equal(bj) {
+			return bi.Before(bj)
+		}
 		if !dated[i].DueDate.Equal(*dated[j].DueDate) {
 			return dated[i].DueDate.Before(*dated[j].DueDate)
 		}
+		if mi, mj := sortMinutes(dated[i]), sortMinutes(dated[j]); mi != mj {
+			return mi < mj
+		}
 		if dated[i].FilePath != dated[j].FilePath {
 			return dated[i].FilePath < dated[j].FilePath
 		}
@@ -235,12 +328,23 @@ func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
 		overlap = "sorted"
 	}
 
-	var b strings.Builder
+	var groups []TaskGroup
 	interval := 0
 	lastInterval := -1
 
+	untimedGroup := len(datedHorizons) // stands in for the untimed horizon in lastInterval
+
 	for _, t := range dated {
-		date := extractDate(*t.DueDate)
+		if toUntimed(t) {
+			if lastInterval != untimedGroup {
+				groups = append(groups, TaskGroup{Label: untimedHorizon.Label})
+				lastInterval = untimedGroup
+			}
+			g := &groups[len(groups)-1]
+			g.Tasks = append(g.Tasks, t)
+			continue
+		}
+		date := dueAt(t)
 
 		switch overlap {
 		case "first_match":
@@ -257,16 +361,11 @@ func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
 		}
 
 		if interval != lastInterval {
-			if lastInterval != -1 {
-				b.WriteString("\n")
-			}
-			b.WriteString(datedHorizons[interval].Label)
-			b.WriteString("\n")
+			groups = append(groups, TaskGroup{Label: datedHorizons[interval].Label})
 			lastInterval = interval
 		}
-
-		b.WriteString(formatTaskLine(t, opts))
-		b.WriteString("\n")
+		g := &groups[len(groups)-1]
+		g.Tasks = append(g.Tasks, t)
 	}
 
 	// Append undated tasks
@@ -276,16 +375,136 @@ func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
 	}
 
 	if len(undated) > 0 && !opts.IgnoreUndated {
-		if b.Len() > 0 {
+		groups = append(groups, TaskGroup{Label: undatedLabel, Tasks: undated})
+	}
+
+	// Within each horizon, more urgent tasks first; the date order above
+	// breaks ties
+	if opts.Sort == "priority" {
+		for i := range groups {
+			g := groups[i].Tasks
+			sort.SliceStable(g, func(a, b int) bool { return priorityRank(g[a]) < priorityRank(g[b]) })
+		}
+	}
+
+	for i := range groups {
+		groups[i].Tasks = withSubtasks(groups[i].Tasks, subtasks, 0)
+	}
+
+	return groups
+}
+
+func FormatTaskfile(tasks []Task, now time.Time, opts FormatOpts) string {
+	var b strings.Builder
+	groups := GroupTasks(tasks, now, opts)
+	for _, g := range groups {
+		for _, t := range g.Tasks {
+			opts.priorityColumn = opts.priorityColumn || t.Priority != 0
+		}
+	}
+	for i, g := range groups {
+		if i > 0 {
 			b.WriteString("\n")
 		}
-		b.WriteString(undatedLabel)
+		b.WriteString(g.Label)
 		b.WriteString("\n")
-		for _, t := range undated {
+		for _, t := range g.Tasks {
 			b.WriteString(formatTaskLine(t, opts))
 			b.WriteString("\n")
 		}
 	}
-
 	return b.String()
 }
+
+// TaskJSON is the JSON form of a task, shared by `task list --format json`,
+// `--format ndjson` and `task watch --format diff`.
+type TaskJSON struct {
+	File         string   `json:"file"`
+	Line         int      `json:"line"`
+	ID           string   `json:"id,omitempty"`
+	Body         string   `json:"body"`
+	Fingerprint  string   `json:"fingerprint"` // pass to mutations as --expect
+	Due          string   `json:"due,omitempty"`
+	Time         string   `json:"time,omitempty"`
+	Duration     string   `json:"duration"`
+	Tags         []string `json:"tags"`
+	Status       string   `json:"status"`
+	Markers      []Marker `json:"markers"`
+	SortLast     bool     `json:"sort_last"`
+	Recur        string   `json:"recurrence,omitempty"`
+	Horizon      string   `json:"horizon,omitempty"`
+	Parent       int      `json:"parent,omitempty"`        // line of the parent task
+	Children     []int    `json:"children,omitempty"`      // lines of the direct subtasks
+	ChildrenDone int      `json:"children_done,omitempty"` // direct subtasks no longer open
+	Depth        int      `json:"depth,omitempty"`         // nesting under the task listed above
+	Notes        []string `json:"notes,omitempty"`         // continuation lines below the task
+	Priority     string   `json:"priority,omitempty"`      // "high", "medium" or "low"
+}
+
+// toTaskJSON converts a task, formatting its due date with dateFmt. Nil tags
+// and markers become empty arrays so consumers never see null.
+func toTaskJSON(t Task, dateFmt, horizon string) TaskJSON {
+	j := TaskJSON{
+		File:         t.FilePath,
+		Line:         t.LineNumber,
+		ID:           t.ID,
+		Body:         t.Body,
+		Fingerprint:  TaskFingerprint(t.Status, t.Body),
+		Time:         t.DueTime,
+		Duration:     t.Duration,
+		Tags:         t.Tags,
+		Status:       t.Status,
+		Markers:      t.Markers,
+		SortLast:     t.SortLast,
+		Recur:        t.Recurrence,
+		Horizon:      horizon,
+		Parent:       t.Parent,
+		Children:     t.Children,
+		ChildrenDone: t.ChildrenDone,
+		Depth:        t.Depth,
+		Notes:        t.Notes,
+		Priority:     priorityNames[t.Priority],
+	}
+	if j.Tags == nil {
+		j.Tags = []string{}
+	}
+	if j.Markers == nil {
+		j.Markers = []Marker{}
+	}
+	if t.DueDate != nil {
+		j.Due = t.DueDate.Format(dateFmt)
+	}
+	return j
+}
+
+// FormatJSON renders the same view as FormatTaskfile as JSON: an array of
+// tasks, or one task object per line when ndjson is set. Each task carries
+// the label of the horizon it was bucketed into.
+func FormatJSON(tasks []Task, now time.Time, opts FormatOpts, ndjson bool) (string, error) {
+	dateFmt := opts.DateFormat
+	if dateFmt == "" {
+		dateFmt = "2006-01-02"
+	}
+	out := []TaskJSON{}
+	for _, g := range GroupTasks(tasks, now, opts) {
+		for _, t := range g.Tasks {
+			out = append(out, toTaskJSON(t, dateFmt, g.Label))
+		}
+	}
+
+	var b strings.Builder
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if !ndjson {
+		if err := enc.Encode(out); err != nil {
+			return "", err
+		}
+		return b.String(), nil
+	}
+	for _, j := range out {
+		if err := enc.Encode(j); err != nil {
+			return "", err
+		}
+	}
+	return b.String(), nil
+}
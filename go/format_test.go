@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"strings"
 	"testing"
 	"time"
@@ -223,6 +224,121 @@ func TestFormatTaskfile_SortsByDate(t *testing.T) {
 	}
 }
 
+func TestFormatTaskfile_SortsByTimeWithinDay(t *testing.T) {
+	today := mustDatePtr("2026-02-17")
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Untimed", DueDate: today, Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Evening", DueDate: today, DueTime: "17:00", Status: "open"},
+		{FilePath: "/b.md", LineNumber: 1, Body: "Noon", DueDate: today, DueTime: "12:00 PM", Status: "open"},
+		{FilePath: "/b.md", LineNumber: 2, Body: "Morning", DueDate: today, DueTime: "9:00 AM", Status: "open"},
+		{FilePath: "/c.md", LineNumber: 1, Body: "Afternoon", DueDate: today, DueTime: "1:30pm", Status: "open"},
+	}
+
+	var got []string
+	for _, g := range GroupTasks(tasks, testNow, defaultOpts) {
+		for _, task := range g.Tasks {
+			got = append(got, task.Body)
+		}
+	}
+	want := "Morning Noon Afternoon Evening Untimed"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+}
+
+func TestFormatTaskfile_OverdueByTime(t *testing.T) {
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Standup", DueDate: mustDatePtr("2026-02-17"), DueTime: "08:00", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Lunch", DueDate: mustDatePtr("2026-02-17"), DueTime: "12:00", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 3, Body: "Untimed", DueDate: mustDatePtr("2026-02-17"), Status: "open"},
+		{FilePath: "/a.md", LineNumber: 4, Body: "Old", DueDate: mustDatePtr("2026-02-10"), Status: "open"},
+	}
+	labels := func(opts FormatOpts) map[string]string {
+		out := make(map[string]string)
+		for _, g := range GroupTasks(tasks, testNow, opts) {
+			for _, task := range g.Tasks {
+				out[task.Body] = g.Label
+			}
+		}
+		return out
+	}
+
+	if got := labels(defaultOpts); got["Standup"] != "# Today" {
+		t.Errorf("without OverdueByTime a past time stays in Today: %v", got)
+	}
+	for _, overlap := range []string{"sorted", "narrowest"} {
+		horizons, _ := ResolveHorizons(nil, testNow, time.Monday, overlap)
+		got := labels(FormatOpts{OverdueByTime: true, Overlap: overlap, Horizons: horizons})
+		want := map[string]string{"Standup": "# Overdue", "Lunch": "# Today", "Untimed": "# Today", "Old": "# Overdue"}
+		for body, label := range want {
+			if got[body] != label {
+				t.Errorf("%s: %s in %q, want %q", overlap, body, got[body], label)
+			}
+		}
+	}
+}
+
+func TestFormatTaskfile_TimeOfDayHorizons(t *testing.T) {
+	d := mustDatePtr
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Standup", DueDate: d("2026-02-17"), DueTime: "08:00", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Call", DueDate: d("2026-02-17"), DueTime: "11:00", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 3, Body: "Lunch", DueDate: d("2026-02-17"), DueTime: "1:00 PM", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 4, Body: "Dinner", DueDate: d("2026-02-17"), DueTime: "19:00", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 5, Body: "Anytime", DueDate: d("2026-02-17"), Status: "open"},
+		{FilePath: "/a.md", LineNumber: 6, Body: "Tomorrow", DueDate: d("2026-02-18"), Status: "open"},
+		{FilePath: "/a.md", LineNumber: 7, Body: "Old", DueDate: d("2026-02-10"), Status: "open"},
+	}
+	ascending := []HorizonSpec{
+		{Label: "# Overdue", After: "past"},
+		{Label: "# Now", After: "now"},
+		{Label: "# This Afternoon", After: "at:12:00"},
+		{Label: "# Tonight", After: "at:6pm"},
+		{Label: "# Anytime Today", Untimed: true},
+		{Label: "# Tomorrow", After: float64(1)},
+		{Label: "# Later", After: float64(2)},
+		{Label: "# Someday", Undated: true},
+	}
+	descending := []HorizonSpec{
+		ascending[6], ascending[5], ascending[3], ascending[2], ascending[1], ascending[0], ascending[4], ascending[7],
+	}
+	want := "# Overdue: Old Standup | # Now: Call | # This Afternoon: Lunch | # Tonight: Dinner | " +
+		"# Anytime Today: Anytime | # Tomorrow: Tomorrow"
+
+	for _, c := range []struct {
+		overlap string
+		specs   []HorizonSpec
+	}{
+		{"sorted", ascending},
+		{"narrowest", ascending},
+		{"first_match", descending},
+	} {
+		horizons, err := ResolveHorizons(c.specs, testNow, time.Monday, c.overlap)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parts []string
+		for _, g := range GroupTasks(tasks, testNow, FormatOpts{Horizons: horizons, Overlap: c.overlap}) {
+			var bodies []string
+			for _, task := range g.Tasks {
+				bodies = append(bodies, task.Body)
+			}
+			parts = append(parts, g.Label+": "+strings.Join(bodies, " "))
+		}
+		if got := strings.Join(parts, " | "); got != want {
+			t.Errorf("%s:\ngot  %s\nwant %s", c.overlap, got, want)
+		}
+	}
+
+	// Without an untimed horizon, untimed tasks count from the start of
+	// their day: before "now", with the overdue ones here
+	horizons, _ := ResolveHorizons(append(ascending[:4:4], ascending[5:]...), testNow, time.Monday, "sorted")
+	groups := GroupTasks(tasks, testNow, FormatOpts{Horizons: horizons})
+	if groups[0].Label != "# Overdue" || len(groups[0].Tasks) != 3 || groups[0].Tasks[1].Body != "Anytime" {
+		t.Errorf("untimed without a sub-bucket: %+v", groups[0])
+	}
+}
+
 func TestFormatTaskfile_EmptyInput(t *testing.T) {
 	got := FormatTaskfile(nil, testNow, defaultOpts)
 	if got != "" {
@@ -737,3 +853,87 @@ func TestFormatTaskfile_CustomUndatedLabel(t *testing.T) {
 		t.Errorf("undated task should appear in output:\n%s", got)
 	}
 }
+
+func TestFormatJSON_FieldsAndHorizons(t *testing.T) {
+	tasks := []Task{
+		{FilePath: "/b.md", LineNumber: 4, Body: "Someday task", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Review PR", DueDate: mustDatePtr("2026-02-17"), DueTime: "14:00",
+			Duration: "30m", Tags: []string{"work"}, Status: "open",
+			Markers: []Marker{{Kind: "deferral", Date: "2026-02-16"}}},
+		{FilePath: "/p.md", LineNumber: 1, Body: "Project", DueDate: mustDatePtr("2026-02-15"), Status: "open", SortLast: true},
+	}
+
+	got, err := FormatJSON(tasks, testNow, defaultOpts, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out []TaskJSON
+	if err := json.Unmarshal([]byte(got), &out); err != nil {
+		t.Fatalf("invalid JSON %q: %v", got, err)
+	}
+	if len(out) != 3 {
+		t.Fatalf("got %d tasks, want 3", len(out))
+	}
+
+	want := []struct{ body, horizon string }{
+		{"Project", "# Overdue"},
+		{"Review PR", "# Today"},
+		{"Someday task", "# Someday"},
+	}
+	for i, w := range want {
+		if out[i].Body != w.body || out[i].Horizon != w.horizon {
+			t.Errorf("task %d = %q in %q, want %q in %q", i, out[i].Body, out[i].Horizon, w.body, w.horizon)
+		}
+	}
+
+	pr := out[1]
+	if pr.File != "/a.md" || pr.Line != 2 || pr.Due != "2026-02-17" || pr.Time != "14:00" ||
+		pr.Duration != "30m" || pr.Status != "open" || len(pr.Tags) != 1 || pr.Tags[0] != "work" {
+		t.Errorf("unexpected fields: %+v", pr)
+	}
+	if len(pr.Markers) != 1 || pr.Markers[0] != (Marker{Kind: "deferral", Date: "2026-02-16"}) {
+		t.Errorf("markers = %+v", pr.Markers)
+	}
+	if !out[0].SortLast {
+		t.Error("sort_last lost for project task")
+	}
+	if !strings.Contains(got, `"tags":[]`) || !strings.Contains(got, `"markers":[]`) {
+		t.Errorf("empty tags/markers should be [] not null: %s", got)
+	}
+	if !strings.Contains(got, `"sort_last":false`) || !strings.Contains(got, `"duration":""`) {
+		t.Errorf("sort_last and duration should always be present: %s", got)
+	}
+}
+
+func TestFormatJSON_NDJSON(t *testing.T) {
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "One", DueDate: mustDatePtr("2026-02-18"), Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Two", Status: "open"},
+	}
+	got, err := FormatJSON(tasks, testNow, FormatOpts{IgnoreUndated: true}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(got, "\n"), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("want one line (undated ignored), got %q", got)
+	}
+	var task TaskJSON
+	if err := json.Unmarshal([]byte(lines[0]), &task); err != nil {
+		t.Fatal(err)
+	}
+	if task.Body != "One" || task.Horizon != "# Tomorrow" {
+		t.Errorf("got %+v", task)
+	}
+}
+
+func TestFormatJSON_Empty(t *testing.T) {
+	got, _ := FormatJSON(nil, testNow, defaultOpts, false)
+	if got != "[]\n" {
+		t.Errorf("got %q, want empty array", got)
+	}
+	got, _ = FormatJSON(nil, testNow, defaultOpts, true)
+	if got != "" {
+		t.Errorf("got %q, want no lines", got)
+	}
+}
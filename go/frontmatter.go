@@ -3,6 +3,7 @@ package main
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 	"sync"
@@ -71,19 +72,34 @@ func (fm *Frontmatter) GetStringSlice(key string) []string {
 	}
 }
 
+// frontmatterEntry is a cached parse result along with the file identity it
+// was parsed from, so edits to the file invalidate the entry.
+type frontmatterEntry struct {
+	fm      *Frontmatter
+	modTime int64 // unix nanoseconds
+	size    int64
+}
+
 type frontmatterCache struct {
 	mu    sync.Mutex
-	cache map[string]*Frontmatter
+	cache map[string]frontmatterEntry
 }
 
-var fmCache = frontmatterCache{cache: make(map[string]*Frontmatter)}
+var fmCache = frontmatterCache{cache: make(map[string]frontmatterEntry)}
 
 // ParseFrontmatter reads and caches the full YAML frontmatter from a markdown file.
+// Cached entries are reused only while the file's mtime and size are unchanged.
 func ParseFrontmatter(filePath string) (*Frontmatter, error) {
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	modTime, size := info.ModTime().UnixNano(), info.Size()
+
 	fmCache.mu.Lock()
-	if fm, ok := fmCache.cache[filePath]; ok {
+	if e, ok := fmCache.cache[filePath]; ok && e.modTime == modTime && e.size == size {
 		fmCache.mu.Unlock()
-		return fm, nil
+		return e.fm, nil
 	}
 	fmCache.mu.Unlock()
 
@@ -92,10 +108,7 @@ func ParseFrontmatter(filePath string) (*Frontmatter, error) {
 		return nil, err
 	}
 
-	fmCache.mu.Lock()
-	fmCache.cache[filePath] = fm
-	fmCache.mu.Unlock()
-
+	primeFrontmatterCache(filePath, fm, modTime, size)
 	return fm, nil
 }
 
@@ -112,10 +125,26 @@ func ParseFrontmatterTags(filePath string) ([]string, error) {
 	return fm.Tags, nil
 }
 
+// primeFrontmatterCache stores an already-parsed frontmatter (possibly nil)
+// for filePath, as read at the given mtime and size, so later ParseFrontmatter
+// calls do not re-read the unchanged file.
+func primeFrontmatterCache(filePath string, fm *Frontmatter, modTime, size int64) {
+	fmCache.mu.Lock()
+	fmCache.cache[filePath] = frontmatterEntry{fm: fm, modTime: modTime, size: size}
+	fmCache.mu.Unlock()
+}
+
+// InvalidateFrontmatter drops the cached frontmatter for filePath.
+func InvalidateFrontmatter(filePath string) {
+	fmCache.mu.Lock()
+	delete(fmCache.cache, filePath)
+	fmCache.mu.Unlock()
+}
+
 // ResetFrontmatterCache clears the cache (useful for testing).
 func ResetFrontmatterCache() {
 	fmCache.mu.Lock()
-	fmCache.cache = make(map[string]*Frontmatter)
+	fmCache.cache = make(map[string]frontmatterEntry)
 	fmCache.mu.Unlock()
 }
 
@@ -125,8 +154,12 @@ func parseFrontmatterFromFile(filePath string) (*Frontmatter, error) {
 		return nil, err
 	}
 	defer f.Close()
+	return parseFrontmatterFrom(f)
+}
 
-	scanner := bufio.NewScanner(f)
+// parseFrontmatterFrom parses frontmatter from the start of r.
+func parseFrontmatterFrom(r io.Reader) (*Frontmatter, error) {
+	scanner := bufio.NewScanner(r)
 
 	// First line must be ---
 	if !scanner.Scan() || strings.TrimSpace(scanner.Text()) != "---" {
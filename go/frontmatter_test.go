@@ -73,16 +73,36 @@ func TestParseFrontmatterTags_Cached(t *testing.T) {
 	f := filepath.Join(dir, "test.md")
 	os.WriteFile(f, []byte("---\ntags:\n  - cached\n---\n"), 0644)
 
-	tags1, _ := ParseFrontmatterTags(f)
-	// Overwrite file — cached result should still be returned
-	os.WriteFile(f, []byte("---\ntags:\n  - different\n---\n"), 0644)
-	tags2, _ := ParseFrontmatterTags(f)
+	fm1, _ := ParseFrontmatter(f)
+	fm2, _ := ParseFrontmatter(f)
+	if fm1 == nil || fm1 != fm2 {
+		t.Errorf("unchanged file should be served from cache")
+	}
 
-	if len(tags1) != 1 || tags1[0] != "cached" {
-		t.Errorf("first call: tags = %v", tags1)
+	// Overwrite file — the changed size/mtime must invalidate the cache
+	os.WriteFile(f, []byte("---\ntags:\n  - different\n---\n"), 0644)
+	tags, _ := ParseFrontmatterTags(f)
+	if len(tags) != 1 || tags[0] != "different" {
+		t.Errorf("after edit: tags = %v, want [different]", tags)
 	}
-	if len(tags2) != 1 || tags2[0] != "cached" {
-		t.Errorf("second call should be cached: tags = %v", tags2)
+}
+
+func TestParseFrontmatter_InvalidateSameSizeEdit(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	f := filepath.Join(dir, "test.md")
+	os.WriteFile(f, []byte("---\ntags:\n  - aaaa\n---\n"), 0644)
+	info, _ := os.Stat(f)
+	ParseFrontmatter(f)
+
+	// Same size and mtime: only an explicit invalidation picks up the edit
+	os.WriteFile(f, []byte("---\ntags:\n  - bbbb\n---\n"), 0644)
+	os.Chtimes(f, info.ModTime(), info.ModTime())
+	InvalidateFrontmatter(f)
+
+	tags, _ := ParseFrontmatterTags(f)
+	if len(tags) != 1 || tags[0] != "bbbb" {
+		t.Errorf("tags = %v, want [bbbb]", tags)
 	}
 }
 
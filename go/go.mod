@@ -2,4 +2,4 @@ module taskbuffer
 
 go 1.22.7
 
-require gopkg.in/yaml.v3 v3.0.1 // indirect
+require gopkg.in/yaml.v3 v3.0.1
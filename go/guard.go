@@ -0,0 +1,149 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Exit codes for mutations whose target line no longer holds the expected
+// task, so editors can tell "task moved" apart from other failures.
+const (
+	exitTaskAmbiguous = 3
+	exitTaskNotFound  = 4
+)
+
+var (
+	errTaskAmbiguous = errors.New("task is ambiguous")
+	errTaskNotFound  = errors.New("task not found")
+)
+
+// fingerprintLen is the length of the fingerprints printed by `task list
+// --format json`. Shorter prefixes (down to minFingerprintLen) are accepted.
+const (
+	fingerprintLen    = 12
+	minFingerprintLen = 8
+)
+
+// relocateRadius is how far around the given line a moved task is looked for
+// before falling back to the whole file.
+const relocateRadius = 25
+
+// TaskFingerprint returns the fingerprint of a task: the first
+// fingerprintLen hex digits of the SHA-256 of its status and body, joined by
+// a space ("open Write report"). Markers, dates and tags are not part of the
+// body, so the fingerprint survives the mutations themselves; the status
+// tells the task apart from the finished copies a recurring task leaves
+// behind.
+func TaskFingerprint(status, body string) string {
+	return fingerprintHex(status, body)[:fingerprintLen]
+}
+
+func fingerprintHex(status, body string) string {
+	sum := sha256.Sum256([]byte(status + " " + body))
+	return hex.EncodeToString(sum[:])
+}
+
+// fingerprintMatcher returns a predicate matching tasks with the given
+// fingerprint (or fingerprint prefix).
+func fingerprintMatcher(expect string) (func(Task) bool, error) {
+	expect = strings.ToLower(strings.TrimSpace(expect))
+	if len(expect) < minFingerprintLen {
+		return nil, fmt.Errorf("fingerprint %q is too short (want at least %d hex digits)", expect, minFingerprintLen)
+	}
+	if _, err := hex.DecodeString(expect[:len(expect)&^1]); err != nil {
+		return nil, fmt.Errorf("fingerprint %q is not hexadecimal", expect)
+	}
+	return func(t Task) bool {
+		return strings.HasPrefix(fingerprintHex(t.Status, t.Body), expect)
+	}, nil
+}
+
+// relocateTask checks that line lineNum of filePath holds a task accepted by
+// match. If it does not, the task is looked for within relocateRadius lines
+// and then in the whole file. It returns the line the task is on now, an
+// error wrapping errTaskAmbiguous if several lines match, or one wrapping
+// errTaskNotFound if none does.
+func relocateTask(ctx *ParseContext, filePath string, lineNum int, match func(Task) bool) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	matchAt := func(n int) bool {
+		task, err := ParseTask(RawMatch{Path: filePath, LineNumber: n, Text: lines[n-1]}, ctx)
+		return err == nil && match(task)
+	}
+	if lineNum >= 1 && lineNum <= len(lines) && matchAt(lineNum) {
+		return lineNum, nil
+	}
+
+	search := func(from, to int) []int {
+		var found []int
+		for n := max(from, 1); n <= min(to, len(lines)); n++ {
+			if matchAt(n) {
+				found = append(found, n)
+			}
+		}
+		return found
+	}
+	found := search(lineNum-relocateRadius, lineNum+relocateRadius)
+	if len(found) == 0 {
+		found = search(1, len(lines))
+	}
+	switch len(found) {
+	case 0:
+		return 0, fmt.Errorf("%w: %s:%d no longer holds the task and it is not in the file", errTaskNotFound, filePath, lineNum)
+	case 1:
+		if Verbose {
+			fmt.Fprintf(os.Stderr, "taskbuffer: task moved from %s:%d to line %d\n", filePath, lineNum, found[0])
+		}
+		return found[0], nil
+	}
+	nums := make([]string, len(found))
+	for i, n := range found {
+		nums[i] = strconv.Itoa(n)
+	}
+	return 0, fmt.Errorf("%w: %d lines match its fingerprint (%s) and %s:%d no longer holds it", errTaskAmbiguous, len(found), strings.Join(nums, ", "), filePath, lineNum)
+}
+
+// currentTaskMatcher matches the running task by name. Names written by the
+// Neovim plugin may still carry the task's tags, so tags and repeated
+// whitespace are ignored on both sides.
+func currentTaskMatcher(ctx *ParseContext, name string) func(Task) bool {
+	normalize := func(s string) string {
+		return strings.Join(strings.Fields(ctx.tagRe.ReplaceAllString(s, " ")), " ")
+	}
+	want := normalize(name)
+	return func(t Task) bool {
+		return normalize(t.Body) == want
+	}
+}
+
+// matchCurrentTask returns the predicate that relocates a running or recent
+// task: its body's fingerprint, if the state recorded one, or its name.
+func matchCurrentTask(ctx *ParseContext, ct CurrentTask) func(Task) bool {
+	byName := currentTaskMatcher(ctx, ct.Name)
+	byFingerprint, err := fingerprintMatcher(ct.Fingerprint)
+	if err != nil {
+		return byName
+	}
+	return func(t Task) bool {
+		return byFingerprint(t) || byName(t)
+	}
+}
+
+// exitCode maps an error to the process exit status.
+func exitCode(err error) int {
+	switch {
+	case errors.Is(err, errTaskAmbiguous):
+		return exitTaskAmbiguous
+	case errors.Is(err, errTaskNotFound):
+		return exitTaskNotFound
+	}
+	return 1
+}
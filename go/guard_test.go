@@ -0,0 +1,218 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskFingerprint(t *testing.T) {
+	fp := TaskFingerprint("open", "Write report")
+	if len(fp) != fingerprintLen {
+		t.Fatalf("fingerprint %q has length %d", fp, len(fp))
+	}
+	if fp != TaskFingerprint("open", "Write report") || fp == TaskFingerprint("open", "Write reports") {
+		t.Error("fingerprint should depend on the body")
+	}
+	if fp == TaskFingerprint("done", "Write report") {
+		t.Error("fingerprint should depend on the status")
+	}
+
+	match, err := fingerprintMatcher(strings.ToUpper(fp[:minFingerprintLen]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !match(Task{Status: "open", Body: "Write report"}) || match(Task{Status: "open", Body: "Other"}) ||
+		match(Task{Status: "done", Body: "Write report"}) {
+		t.Error("prefix matcher mismatch")
+	}
+	for _, bad := range []string{"abc", "zzzzzzzzzzzz"} {
+		if _, err := fingerprintMatcher(bad); err == nil {
+			t.Errorf("fingerprintMatcher(%q) should fail", bad)
+		}
+	}
+}
+
+func TestRelocateTask(t *testing.T) {
+	var filler []string
+	for i := 0; i < 40; i++ {
+		filler = append(filler, fmt.Sprintf("- [ ] Filler %d", i))
+	}
+	fill := strings.Join(filler, "\n") + "\n"
+
+	cases := []struct {
+		name    string
+		content string
+		line    int
+		want    int
+		wantErr error
+	}{
+		{"unchanged", "- [ ] Target\n- [ ] Other\n", 1, 1, nil},
+		{"shifted down", "new line\n\n- [ ] Other\n- [ ] Target\n", 2, 4, nil},
+		{"far away", "- [ ] Other\n" + fill + "- [ ] Target\n", 1, 42, nil},
+		{"nearest wins over far", "- [ ] Target\n" + fill + "- [ ] Other\n- [ ] Target\n", 40, 43, nil},
+		{"ambiguous nearby", "- [ ] Target\n- [ ] Other\n- [ ] Target\n", 2, 0, errTaskAmbiguous},
+		{"removed", "- [ ] Other\n", 1, 0, errTaskNotFound},
+		{"line past end", "- [ ] Other\n", 9, 0, errTaskNotFound},
+		{"checked off copy", "- [x] Target ::complete [[2026-02-17]] 10:00\n", 1, 0, errTaskNotFound},
+		{"next occurrence", "- [x] Target ::complete [[2026-02-17]] 10:00\n- [ ] Target\n", 1, 2, nil},
+	}
+	match, _ := fingerprintMatcher(TaskFingerprint("open", "Target"))
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "a.md")
+			os.WriteFile(path, []byte(c.content), 0644)
+			got, err := relocateTask(defaultCtx, path, c.line, match)
+			if c.wantErr != nil {
+				if !errors.Is(err, c.wantErr) {
+					t.Fatalf("err = %v, want %v", err, c.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != c.want {
+				t.Errorf("line = %d, want %d", got, c.want)
+			}
+		})
+	}
+}
+
+func TestTaskLocation_Expect(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("- [ ] Inserted\n- [ ] Write report\n"), 0644)
+	fp := TaskFingerprint("open", "Write report")
+
+	for _, args := range [][]string{
+		{path, "1", "--expect", fp},
+		{"--expect", fp, path, "1"},
+		{path, "1", "--expect=" + fp},
+	} {
+		_, line, err := taskLocation(defaultCtx, args, "test")
+		if err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if line != 2 {
+			t.Errorf("%v: line = %d, want 2", args, line)
+		}
+	}
+
+	if _, line, _ := taskLocation(defaultCtx, []string{path, "1"}, "test"); line != 1 {
+		t.Errorf("without --expect the line is used as given, got %d", line)
+	}
+	if _, _, err := taskLocation(defaultCtx, []string{path, "1", "extra"}, "test"); err == nil {
+		t.Error("extra positional argument should fail")
+	}
+}
+
+func TestMutations_RefuseStaleLine(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	orig := "- [ ] Write report\n- [ ] Write report\n- [ ] Call bob\n"
+	os.WriteFile(path, []byte(orig), 0644)
+
+	err := cmdIrrelevant(defaultCtx, []string{path, "3", "--expect", TaskFingerprint("open", "Gone")})
+	if !errors.Is(err, errTaskNotFound) || exitCode(err) != exitTaskNotFound {
+		t.Errorf("err = %v (exit %d), want not found", err, exitCode(err))
+	}
+	err = cmdCheck(defaultCtx, []string{path, "3", "--expect", TaskFingerprint("open", "Write report")})
+	if !errors.Is(err, errTaskAmbiguous) || exitCode(err) != exitTaskAmbiguous {
+		t.Errorf("err = %v (exit %d), want ambiguous", err, exitCode(err))
+	}
+	if err != nil && !strings.Contains(err.Error(), "task is ambiguous: 2 lines match its fingerprint (1, 2)") {
+		t.Errorf("err = %q, want the matching lines counted", err)
+	}
+	if got := readFile(t, path); got != orig {
+		t.Errorf("file modified by refused mutations:\n%s", got)
+	}
+
+	if err := cmdCheck(defaultCtx, []string{path, "1", "--expect", TaskFingerprint("open", "Call bob")}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); !strings.Contains(got, "- [x] Call bob") || strings.Contains(got, "- [x] Write") {
+		t.Errorf("check hit the wrong line:\n%s", got)
+	}
+}
+
+func TestMutations_SkipFinishedCopy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	orig := "- [ ] Other\n- [x] Water plants (@[[2026-02-10]]) ::complete [[2026-02-10]] 09:00\n"
+	os.WriteFile(path, []byte(orig), 0644)
+
+	// The task was checked off since it was listed: neither its line nor the
+	// copy left on it is the open task the fingerprint is of
+	fp := TaskFingerprint("open", "Water plants")
+	if err := cmdCheck(defaultCtx, []string{path, "2", "--expect", fp}); !errors.Is(err, errTaskNotFound) {
+		t.Errorf("check: err = %v, want not found", err)
+	}
+	if err := cmdDefer(defaultCtx, []string{path, "2", "--to", "2026-02-20", "--expect", fp}); !errors.Is(err, errTaskNotFound) {
+		t.Errorf("defer: err = %v, want not found", err)
+	}
+	if got := readFile(t, path); got != orig {
+		t.Errorf("finished copy modified:\n%s", got)
+	}
+}
+
+func TestStopCurrentTask_RelocatesByName(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("- [ ] Write report #work\n"), 0644)
+	cfg := Config{StateDir: state}
+
+	// The Neovim plugin records the name with its tags.
+	ct := CurrentTask{StartTime: time.Now().Unix(), Name: "Write report  #work", FilePath: path, LineNumber: 1}
+	if err := WriteCurrentTaskTo(state, ct); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("- [ ] New first task\n- [ ] Write report #work\n"), 0644)
+
+	if _, err := stopCurrentTask(cfg); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(readFile(t, path), "\n")
+	if strings.Contains(lines[0], "::stop") || !strings.Contains(lines[1], "::stop") {
+		t.Errorf("stop marker on the wrong line:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// A deleted task is refused and the state is kept.
+	if err := WriteCurrentTaskTo(state, ct); err != nil {
+		t.Fatal(err)
+	}
+	os.WriteFile(path, []byte("- [ ] Something else\n"), 0644)
+	if _, err := stopCurrentTask(cfg); !errors.Is(err, errTaskNotFound) {
+		t.Errorf("err = %v, want not found", err)
+	}
+	if got, _ := ReadCurrentTaskFrom(state); got == nil {
+		t.Error("state should be kept when the task cannot be found")
+	}
+}
+
+func TestServe_ExpectErrors(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] Same\n- [ ] Same\n- [ ] Other\n"})
+	c := startServer(t, []string{dir}, Config{StateDir: t.TempDir()})
+
+	cases := []struct {
+		expect string
+		code   float64
+	}{
+		{TaskFingerprint("open", "Same"), rpcTaskAmbiguous},
+		{TaskFingerprint("open", "Missing"), rpcTaskNotFound},
+	}
+	for _, tc := range cases {
+		resp := c.send(fmt.Sprintf(`{"jsonrpc":"2.0","id":1,"method":"check","params":{"file":%q,"line":3,"expect":%q}}`, path, tc.expect))
+		e, ok := resp["error"].(map[string]interface{})
+		if !ok || e["code"] != tc.code {
+			t.Errorf("expect %s: got %v, want code %v", tc.expect, resp, tc.code)
+		}
+	}
+	if c.call("check", map[string]interface{}{"file": path, "line": 1, "expect": TaskFingerprint("open", "Other")}) != true {
+		t.Error("check with a moved task should succeed")
+	}
+}
@@ -15,6 +15,7 @@ type HorizonSpec struct {
 	Label   string      `json:"label"`
 	After   interface{} `json:"after,omitempty"` // float64 (JSON int), string, or nil
 	Undated bool        `json:"undated,omitempty"`
+	Untimed bool        `json:"untimed,omitempty"` // collects today's untimed tasks when the day is split by time
 	Order   *int        `json:"order,omitempty"`
 }
 
@@ -23,6 +24,7 @@ type ResolvedHorizon struct {
 	Label   string
 	Cutoff  time.Time
 	Undated bool
+	Untimed bool
 	Order   int
 }
 
@@ -41,37 +43,110 @@ func defaultHorizonSpecs() []HorizonSpec {
 	}
 }
 
-var horizonDurationRe = regexp.MustCompile(`^(-?\d+)([dwmy])$`)
+var (
+	horizonDurationRe = regexp.MustCompile(`^(-?\d+)(bd|[dwmy])$`)
+	horizonHoursRe    = regexp.MustCompile(`^(-?\d+)h$`)
+)
+
+// dateOffset is a parsed duration string: a count of days, weeks, months,
+// years or business days.
+type dateOffset struct {
+	n    int
+	unit string
+}
 
-// parseDuration parses a duration string like "2d", "1w", "1m", "1y" into a
-// day count. Units: d=1, w=7, m=30, y=365.
-func parseDuration(s string) (int, error) {
+// parseDuration parses a duration string like "2d", "1w", "1m", "1y" or "5bd"
+// into a dateOffset.
+func parseDuration(s string) (dateOffset, error) {
 	m := horizonDurationRe.FindStringSubmatch(s)
 	if m == nil {
-		return 0, fmt.Errorf("invalid duration string: %q", s)
+		return dateOffset{}, fmt.Errorf("invalid duration string: %q", s)
 	}
 	n, _ := strconv.Atoi(m[1])
-	switch m[2] {
-	case "d":
-		return n, nil
+	return dateOffset{n, m[2]}, nil
+}
+
+// addTo moves d by the offset. Months and years follow the calendar, clamping
+// the day to the end of a shorter month (Jan 31 + 1m = Feb 28); business days
+// skip Saturdays and Sundays.
+func (o dateOffset) addTo(d time.Time) time.Time {
+	switch o.unit {
 	case "w":
-		return n * 7, nil
+		return d.AddDate(0, 0, 7*o.n)
 	case "m":
-		return n * 30, nil
+		return addMonthsClamped(d, o.n)
 	case "y":
-		return n * 365, nil
+		return addMonthsClamped(d, 12*o.n)
+	case "bd":
+		return addBusinessDays(d, o.n)
 	}
-	return 0, fmt.Errorf("unknown duration unit: %s", m[2])
+	return d.AddDate(0, 0, o.n)
 }
 
-// resolveCalendarKeyword resolves a calendar keyword to a cutoff time.
-// Cutoffs are start-of-next-period (exclusive upper boundary).
-func resolveCalendarKeyword(kw string, today time.Time, weekStart time.Weekday) (time.Time, error) {
+// addBusinessDays moves d by n weekdays, forward or back.
+func addBusinessDays(d time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step = -1
+	}
+	for n != 0 {
+		d = d.AddDate(0, 0, step)
+		if wd := d.Weekday(); wd != time.Saturday && wd != time.Sunday {
+			n -= step
+		}
+	}
+	return d
+}
+
+// resolveCalendarKeyword resolves a calendar expression to a cutoff time: a
+// keyword or duration, optionally followed by offsets ("end_of_week+1w",
+// "start_of_month-5bd", "1m+2d"). end_of_* cutoffs are start-of-next-period
+// (exclusive upper boundary); start_of_* are the first day of the period.
+// Errors give the column of the offending term.
+func resolveCalendarKeyword(expr string, today time.Time, weekStart time.Weekday) (time.Time, error) {
+	// Split before every sign but a leading one
+	var terms []string
+	start := 0
+	for i := 1; i < len(expr); i++ {
+		if expr[i] == '+' || expr[i] == '-' {
+			terms = append(terms, expr[start:i])
+			start = i
+		}
+	}
+	terms = append(terms, expr[start:])
+
+	d, ok := calendarKeyword(terms[0], today, weekStart)
+	if o, err := parseDuration(strings.TrimPrefix(terms[0], "+")); err == nil {
+		d = o.addTo(today)
+	} else if !ok {
+		return time.Time{}, fmt.Errorf("unknown calendar keyword %q at column 1 of %q", terms[0], expr)
+	}
+	col := len(terms[0]) + 1
+	for _, term := range terms[1:] {
+		o, err := parseDuration(strings.TrimPrefix(term, "+"))
+		if err != nil {
+			return time.Time{}, fmt.Errorf("invalid offset %q at column %d of %q (want +N or -N with d, w, m, y or bd)", term, col, expr)
+		}
+		d = o.addTo(d)
+		col += len(term)
+	}
+	return d, nil
+}
+
+// calendarKeyword resolves a single calendar keyword. ok is false for an
+// unknown one.
+func calendarKeyword(kw string, today time.Time, weekStart time.Weekday) (cutoff time.Time, ok bool) {
+	y, m, _ := today.Date()
+	qMonth := ((m-1)/3)*3 + 1 // first month of this quarter
 	switch kw {
 	case "past":
-		return today.AddDate(-100, 0, 0), nil
+		return today.AddDate(-100, 0, 0), true
 	case "yesterday":
-		return today.AddDate(0, 0, -1), nil
+		return today.AddDate(0, 0, -1), true
+	case "today":
+		return today, true
+	case "start_of_week":
+		return today.AddDate(0, 0, -(int(today.Weekday()-weekStart+7) % 7)), true
 	case "end_of_week":
 		// Find the day after the last day of the current week.
 		// If weekStart is Monday, the week ends on Sunday.
@@ -84,18 +159,21 @@ func resolveCalendarKeyword(kw string, today time.Time, weekStart time.Weekday)
 		if daysUntilEnd == 0 {
 			daysUntilEnd = 7
 		}
-		return today.AddDate(0, 0, daysUntilEnd+1), nil
+		return today.AddDate(0, 0, daysUntilEnd+1), true
+	case "start_of_month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, today.Location()), true
 	case "end_of_month":
-		y, m, _ := today.Date()
-		return time.Date(y, m+1, 1, 0, 0, 0, 0, today.Location()), nil
+		return time.Date(y, m+1, 1, 0, 0, 0, 0, today.Location()), true
+	case "start_of_quarter":
+		return time.Date(y, qMonth, 1, 0, 0, 0, 0, today.Location()), true
 	case "end_of_quarter":
-		y, m, _ := today.Date()
-		qMonth := ((m-1)/3)*3 + 4 // first month of next quarter
-		return time.Date(y, qMonth, 1, 0, 0, 0, 0, today.Location()), nil
+		return time.Date(y, qMonth+3, 1, 0, 0, 0, 0, today.Location()), true
+	case "start_of_year":
+		return time.Date(y, 1, 1, 0, 0, 0, 0, today.Location()), true
 	case "end_of_year":
-		return time.Date(today.Year()+1, 1, 1, 0, 0, 0, 0, today.Location()), nil
+		return time.Date(y+1, 1, 1, 0, 0, 0, 0, today.Location()), true
 	default:
-		return time.Time{}, fmt.Errorf("unknown calendar keyword: %q", kw)
+		return time.Time{}, false
 	}
 }
 
@@ -121,8 +199,31 @@ func parseWeekday(s string) time.Weekday {
 	}
 }
 
+// parseTimeOfDay resolves the cutoffs that fall within a day: "now", a number
+// of hours from now ("4h", "-2h"), or a time today ("at:12:00", "at:6pm").
+// ok is false for any other value.
+func parseTimeOfDay(v string, now time.Time) (cutoff time.Time, ok bool, err error) {
+	now = now.Truncate(time.Minute)
+	switch {
+	case v == "now":
+		return now, true, nil
+	case horizonHoursRe.MatchString(v):
+		n, _ := strconv.Atoi(horizonHoursRe.FindStringSubmatch(v)[1])
+		return now.Add(time.Duration(n) * time.Hour), true, nil
+	case strings.HasPrefix(v, "at:"):
+		m, valid := clockMinutes(v[3:])
+		if !valid {
+			return time.Time{}, true, fmt.Errorf("invalid time of day %q (want at:HH:MM or at:3pm)", v)
+		}
+		y, mo, d := now.Date()
+		return time.Date(y, mo, d, m/60, m%60, 0, 0, now.Location()), true, nil
+	}
+	return time.Time{}, false, nil
+}
+
 // parseAfterValue resolves the polymorphic "after" field to a cutoff time.
-// Accepts float64 (day offset), string (duration or calendar keyword), or nil.
+// Accepts float64 (day offset), string (duration, time of day or calendar
+// expression), or nil.
 func parseAfterValue(val interface{}, now time.Time, weekStart time.Weekday) (time.Time, error) {
 	today := extractDate(now)
 	switch v := val.(type) {
@@ -133,8 +234,12 @@ func parseAfterValue(val interface{}, now time.Time, weekStart time.Weekday) (ti
 		return today.AddDate(0, 0, v), nil
 	case string:
 		// Try duration string first
-		if days, err := parseDuration(v); err == nil {
-			return today.AddDate(0, 0, days), nil
+		if o, err := parseDuration(v); err == nil {
+			return o.addTo(today), nil
+		}
+		// Then a cutoff within the day
+		if cutoff, ok, err := parseTimeOfDay(v, now); ok {
+			return cutoff, err
 		}
 		// Try calendar keyword
 		return resolveCalendarKeyword(v, today, weekStart)
@@ -154,10 +259,22 @@ func ResolveHorizons(specs []HorizonSpec, now time.Time, weekStart time.Weekday,
 	}
 
 	var dated []ResolvedHorizon
-	var undated []ResolvedHorizon
+	var undated []ResolvedHorizon // undated and untimed horizons, which have no cutoff
 	var parseErrors []string
 
 	for i, s := range specs {
+		if s.Untimed {
+			order := len(specs) + i
+			if s.Order != nil {
+				order = *s.Order
+			}
+			undated = append(undated, ResolvedHorizon{
+				Label:   s.Label,
+				Untimed: true,
+				Order:   order,
+			})
+			continue
+		}
 		if s.Undated {
 			order := len(specs) + i // undated sorts after dated by default
 			if s.Order != nil {
@@ -40,7 +40,7 @@ func TestParseAfterValue_DurationString(t *testing.T) {
 	}{
 		{"2d", "2d", "2026-02-19"},
 		{"1w", "1w", "2026-02-24"},
-		{"1m", "1m", "2026-03-19"},
+		{"1m", "1m", "2026-03-17"},
 		{"1y", "1y", "2027-02-17"},
 		{"-1w", "-1w", "2026-02-10"},
 	}
@@ -238,17 +238,19 @@ func TestParseWeekday_Default(t *testing.T) {
 func TestParseDuration(t *testing.T) {
 	tests := []struct {
 		input string
-		want  int
+		want  string
 		err   bool
 	}{
-		{"2d", 2, false},
-		{"1w", 7, false},
-		{"1m", 30, false},
-		{"1y", 365, false},
-		{"-1w", -7, false},
-		{"3d", 3, false},
-		{"bogus", 0, true},
-		{"", 0, true},
+		{"2d", "2026-02-19", false},
+		{"1w", "2026-02-24", false},
+		{"1m", "2026-03-17", false},
+		{"1y", "2027-02-17", false},
+		{"-1w", "2026-02-10", false},
+		{"3d", "2026-02-20", false},
+		{"5bd", "2026-02-24", false},
+		{"-2bd", "2026-02-13", false},
+		{"bogus", "", true},
+		{"", "", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.input, func(t *testing.T) {
@@ -256,8 +258,8 @@ func TestParseDuration(t *testing.T) {
 			if (err != nil) != tt.err {
 				t.Fatalf("parseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.err)
 			}
-			if got != tt.want {
-				t.Errorf("parseDuration(%q) = %d, want %d", tt.input, got, tt.want)
+			if !tt.err && got.addTo(testToday).Format("2006-01-02") != tt.want {
+				t.Errorf("parseDuration(%q) from %s = %s, want %s", tt.input, testToday.Format("2006-01-02"), got.addTo(testToday).Format("2006-01-02"), tt.want)
 			}
 		})
 	}
@@ -269,19 +271,20 @@ func TestParseDuration_EdgeCases(t *testing.T) {
 	tests := []struct {
 		name  string
 		input string
-		want  int
+		want  string
 		err   bool
 	}{
-		{"zero days", "0d", 0, false},
-		{"zero weeks", "0w", 0, false},
-		{"large year", "999y", 364635, false},
-		{"invalid unit x", "2x", 0, true},
-		{"double unit dd", "1dd", 0, true},
-		{"unit before number", "d2", 0, true},
-		{"space in middle", "2 d", 0, true},
-		{"leading space", " 1d", 0, true},
-		{"trailing space", "1d ", 0, true},
-		{"uppercase unit", "1D", 0, true},
+		{"zero days", "0d", "2026-02-17", false},
+		{"zero weeks", "0w", "2026-02-17", false},
+		{"large year", "999y", "3025-02-17", false},
+		{"invalid unit x", "2x", "", true},
+		{"double unit dd", "1dd", "", true},
+		{"unit before number", "d2", "", true},
+		{"space in middle", "2 d", "", true},
+		{"leading space", " 1d", "", true},
+		{"trailing space", "1d ", "", true},
+		{"uppercase unit", "1D", "", true},
+		{"business days without count", "bd", "", true},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -289,13 +292,90 @@ func TestParseDuration_EdgeCases(t *testing.T) {
 			if (err != nil) != tt.err {
 				t.Fatalf("parseDuration(%q) error = %v, wantErr %v", tt.input, err, tt.err)
 			}
-			if !tt.err && got != tt.want {
-				t.Errorf("parseDuration(%q) = %d, want %d", tt.input, got, tt.want)
+			if !tt.err && got.addTo(testToday).Format("2006-01-02") != tt.want {
+				t.Errorf("parseDuration(%q) = %s, want %s", tt.input, got.addTo(testToday).Format("2006-01-02"), tt.want)
 			}
 		})
 	}
 }
 
+func TestDateOffset_Calendar(t *testing.T) {
+	tests := []struct {
+		from, offset, want string
+	}{
+		{"2026-01-31", "1m", "2026-02-28"},
+		{"2028-01-31", "1m", "2028-02-29"},
+		{"2026-03-31", "-1m", "2026-02-28"},
+		{"2028-02-29", "1y", "2029-02-28"},
+		{"2026-12-15", "2m", "2027-02-15"},
+		{"2026-02-20", "1bd", "2026-02-23"}, // Friday to Monday
+		{"2026-02-21", "1bd", "2026-02-23"}, // Saturday to Monday
+		{"2026-02-23", "-1bd", "2026-02-20"},
+		{"2026-02-21", "0bd", "2026-02-21"},
+	}
+	for _, tt := range tests {
+		from, _ := time.ParseInLocation("2006-01-02", tt.from, time.Local)
+		o, err := parseDuration(tt.offset)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.offset, err)
+		}
+		if got := o.addTo(from).Format("2006-01-02"); got != tt.want {
+			t.Errorf("%s %s: got %s, want %s", tt.from, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestResolveCalendarKeyword_Expressions(t *testing.T) {
+	// testToday is Tuesday 2026-02-17
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"start_of_week", "2026-02-16"},
+		{"start_of_month", "2026-02-01"},
+		{"start_of_quarter", "2026-01-01"},
+		{"start_of_year", "2026-01-01"},
+		{"today", "2026-02-17"},
+		{"end_of_week+1w", "2026-03-02"},
+		{"end_of_month-1d", "2026-02-28"},
+		{"end_of_month+1m", "2026-04-01"},
+		{"start_of_month+1m+2bd", "2026-03-03"},
+		{"today+5bd", "2026-02-24"},
+		{"1m+2d", "2026-03-19"},
+		{"-1w-1d", "2026-02-09"},
+	}
+	for _, tt := range tests {
+		got, err := parseAfterValue(tt.expr, testToday, time.Monday)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expr, err)
+			continue
+		}
+		if got.Format("2006-01-02") != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.expr, got.Format("2006-01-02"), tt.want)
+		}
+	}
+
+	if got, _ := resolveCalendarKeyword("start_of_week", testToday, time.Sunday); got.Format("2006-01-02") != "2026-02-15" {
+		t.Errorf("start_of_week with Sunday start: got %s", got.Format("2006-01-02"))
+	}
+
+	errs := []struct {
+		expr string
+		want string
+	}{
+		{"end_of_wek+1w", `unknown calendar keyword "end_of_wek" at column 1`},
+		{"end_of_week+1x", `invalid offset "+1x" at column 12`},
+		{"end_of_week+1w+", `invalid offset "+" at column 15`},
+		{"start_of_month+1m-2", `invalid offset "-2" at column 18`},
+	}
+	for _, tt := range errs {
+		_, err := parseAfterValue(tt.expr, testToday, time.Monday)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("%s: got error %v, want %q", tt.expr, err, tt.want)
+		}
+	}
+}
+
 func TestParseAfterValue_UnsupportedTypes(t *testing.T) {
 	t.Run("bool is unsupported", func(t *testing.T) {
 		_, err := parseAfterValue(true, testToday, time.Monday)
@@ -538,3 +618,34 @@ func TestResolveHorizons_MultipleUndated(t *testing.T) {
 		t.Errorf("expected 2 undated horizons, got %d", undatedCount)
 	}
 }
+
+func TestParseAfterValue_TimeOfDay(t *testing.T) {
+	now := time.Date(2026, 2, 17, 10, 25, 42, 0, time.Local)
+	tests := []struct {
+		after string
+		want  string
+	}{
+		{"now", "2026-02-17 10:25"},
+		{"4h", "2026-02-17 14:25"},
+		{"-2h", "2026-02-17 08:25"},
+		{"16h", "2026-02-18 02:25"},
+		{"at:12:00", "2026-02-17 12:00"},
+		{"at:6pm", "2026-02-17 18:00"},
+		{"at:6:30 PM", "2026-02-17 18:30"},
+	}
+	for _, tt := range tests {
+		got, err := parseAfterValue(tt.after, now, time.Monday)
+		if err != nil {
+			t.Errorf("%s: %v", tt.after, err)
+			continue
+		}
+		if got.Format("2006-01-02 15:04") != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.after, got.Format("2006-01-02 15:04"), tt.want)
+		}
+	}
+	for _, bad := range []string{"at:25:00", "at:noon", "4hours", "at:"} {
+		if _, err := parseAfterValue(bad, now, time.Monday); err == nil {
+			t.Errorf("%s: want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,500 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+const indexFile = "index.gob"
+
+// indexVersion is bumped whenever the on-disk layout or the meaning of a
+// cached field changes, forcing a full rebuild.
+const indexVersion = 6
+
+// projectLineRe matches the frontmatter tag line ScanProjects searches for.
+var projectLineRe = regexp.MustCompile(`- project`)
+
+func init() {
+	// Frontmatter.Raw holds YAML-decoded values behind interface{}.
+	gob.Register(map[string]interface{}{})
+	gob.Register(map[interface{}]interface{}{})
+	gob.Register([]interface{}{})
+	gob.Register(time.Time{})
+}
+
+// IndexedDateError is the serializable form of a DateError.
+type IndexedDateError struct {
+	LineNumber int
+	DateStr    string
+	Context    string
+	Message    string
+}
+
+// IndexedFile is the cached scan result for a single file.
+type IndexedFile struct {
+	ModTime     int64 // unix nanoseconds
+	Size        int64
+	Tasks       []Task             // ParseTasks output, before any frontmatter merging
+	DateErrors  []IndexedDateError // strict-mode errors collected while parsing Tasks
+	Frontmatter *Frontmatter       // parsed only for files with tasks or a project tag
+	HasProject  bool               // markdown file containing a "- project" line
+}
+
+// TaskIndex is the persistent, incremental scan cache stored in the state
+// directory. Entries are keyed by absolute file path and reused as long as
+// the file's mtime and size are unchanged.
+type TaskIndex struct {
+	Version     int
+	Fingerprint string // parseFingerprint of the ParseContext that built the entries
+	Updated     int64  // unix seconds of the last refresh
+	Files       map[string]*IndexedFile
+}
+
+// IndexRefresh summarizes what a Refresh did.
+type IndexRefresh struct {
+	Files   int // files visited
+	Parsed  int // files (re)parsed because they were new or changed
+	Removed int // entries dropped because their file disappeared
+}
+
+func indexPathFor(stateDir string) string {
+	return filepath.Join(resolveStateDir(stateDir), indexFile)
+}
+
+// parseFingerprint hashes everything in ctx that influences ParseTasks output,
+// so a change of checkbox, wrapper, date/time format or prefixes invalidates the index.
+func parseFingerprint(ctx *ParseContext) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "v%d\n", indexVersion)
+	for _, s := range []string{
+		ctx.scanPattern,
+		ctx.statusRe.String(),
+		ctx.dateRe.String(),
+		ctx.tagRe.String(),
+		ctx.markerRe.String(),
+		ctx.markerStartRe.String(),
+		ctx.durationRe.String(),
+		ctx.recurMarkerRe.String(),
+		blockIDRe.String(),
+		ctx.markerPrefix,
+		ctx.formats.GoDate,
+		ctx.formats.GoTime,
+	} {
+		fmt.Fprintf(h, "%s\n", s)
+	}
+	statuses := make([]string, 0, len(ctx.statusMap))
+	for cb, name := range ctx.statusMap {
+		statuses = append(statuses, cb+"="+name)
+	}
+	sort.Strings(statuses)
+	fmt.Fprintf(h, "%s\nstrict=%t\n", strings.Join(statuses, "\n"), ctx.strict)
+	skip := make([]string, 0, len(ctx.skipBlocks))
+	for kind := range ctx.skipBlocks {
+		skip = append(skip, kind)
+	}
+	sort.Strings(skip)
+	fmt.Fprintf(h, "skip=%s\n", strings.Join(skip, ","))
+	if ctx.priorityRe != nil {
+		fmt.Fprintf(h, "priority=%s\n", ctx.priorityRe)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func newTaskIndex(fingerprint string) *TaskIndex {
+	return &TaskIndex{
+		Version:     indexVersion,
+		Fingerprint: fingerprint,
+		Files:       make(map[string]*IndexedFile),
+	}
+}
+
+// readTaskIndex decodes the index file at path as-is, without validating it.
+func readTaskIndex(path string) (*TaskIndex, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var ix TaskIndex
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&ix); err != nil {
+		return nil, fmt.Errorf("decoding %s: %w", path, err)
+	}
+	if ix.Files == nil {
+		ix.Files = make(map[string]*IndexedFile)
+	}
+	return &ix, nil
+}
+
+// LoadTaskIndex reads the index from stateDir. A missing, corrupt or outdated
+// index (different version or parse fingerprint) yields an empty index.
+func LoadTaskIndex(stateDir string, ctx *ParseContext) (*TaskIndex, error) {
+	fingerprint := parseFingerprint(ctx)
+	ix, err := readTaskIndex(indexPathFor(stateDir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return newTaskIndex(fingerprint), nil
+		}
+		var pathErr *os.PathError
+		if errors.As(err, &pathErr) {
+			return nil, err
+		}
+		if Verbose {
+			fmt.Fprintf(os.Stderr, "taskbuffer: rebuilding index: %v\n", err)
+		}
+		return newTaskIndex(fingerprint), nil
+	}
+	if ix.Version != indexVersion || ix.Fingerprint != fingerprint {
+		return newTaskIndex(fingerprint), nil
+	}
+	return ix, nil
+}
+
+// Save writes the index to stateDir, replacing the previous file atomically.
+func (ix *TaskIndex) Save(stateDir string) error {
+	path := indexPathFor(stateDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(ix); err != nil {
+		return fmt.Errorf("encoding index: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), indexFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(buf.Bytes()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Refresh brings the index up to date with the files under notesPaths, as
+// listed by the configured scan backend. Only files whose mtime or size
+// changed are read and re-parsed; entries for files that disappeared from the
+// scanned roots are dropped.
+func (ix *TaskIndex) Refresh(ctx *ParseContext, notesPaths ...string) (IndexRefresh, error) {
+	var stats IndexRefresh
+	paths := expandGlobs(notesPaths)
+	if len(paths) == 0 {
+		return stats, nil
+	}
+
+	scanRe, err := regexp.Compile(ctx.scanPattern)
+	if err != nil {
+		return stats, fmt.Errorf("compiling scan pattern: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	err = eachScannedFile(ctx.scanBackend, paths, func(p string) error {
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil
+		}
+		stats.Files++
+		seen[p] = true
+		if e, ok := ix.Files[p]; ok && e.ModTime == info.ModTime().UnixNano() && e.Size == info.Size() {
+			return nil
+		}
+		entry := indexFileEntry(p, info, ctx, scanRe)
+		if entry == nil {
+			delete(ix.Files, p)
+			return nil
+		}
+		ix.Files[p] = entry
+		stats.Parsed++
+		return nil
+	})
+	if err != nil {
+		return stats, fmt.Errorf("listing notes: %w", err)
+	}
+
+	for p := range ix.Files {
+		if seen[p] || !underAnyRoot(p, paths) {
+			continue
+		}
+		delete(ix.Files, p)
+		stats.Removed++
+	}
+
+	ix.Updated = time.Now().Unix()
+	return stats, nil
+}
+
+// eachScannedFile calls fn for every file the scan backend searches under
+// paths: the files `rg --files` lists, or those the built-in walker visits.
+func eachScannedFile(backendName string, paths []string, fn func(path string) error) error {
+	backend, err := resolveScanBackend(backendName)
+	if err != nil {
+		return err
+	}
+	if backend == scanBackendGo {
+		return walkFiles(paths, nil, fn)
+	}
+	files, err := listAllFilesRg(paths)
+	if err != nil {
+		return err
+	}
+	for _, p := range files {
+		if err := fn(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// underAnyRoot reports whether p is one of roots or lies below one of them.
+func underAnyRoot(p string, roots []string) bool {
+	for _, r := range roots {
+		if p == r || strings.HasPrefix(p, r+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexFileEntry reads and parses a single file. Returns nil if it cannot be read.
+func indexFileEntry(p string, info os.FileInfo, ctx *ParseContext, scanRe *regexp.Regexp) *IndexedFile {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil
+	}
+	entry := &IndexedFile{
+		ModTime: info.ModTime().UnixNano(),
+		Size:    info.Size(),
+	}
+
+	var dateErrors []DateError
+	saved := ctx.dateErrors
+	ctx.dateErrors = &dateErrors
+	entry.Tasks = ParseTasks(annotateMatches(ctx, p, data, grepData(p, data, scanRe)), ctx)
+	ctx.dateErrors = saved
+	for _, e := range dateErrors {
+		entry.DateErrors = append(entry.DateErrors, IndexedDateError{
+			LineNumber: e.LineNumber,
+			DateStr:    e.DateStr,
+			Context:    e.Context,
+			Message:    e.Err.Error(),
+		})
+	}
+
+	isMarkdown := strings.HasSuffix(filepath.Base(p), ".md")
+	entry.HasProject = isMarkdown && bytes.IndexByte(data, 0) < 0 && projectLineRe.Match(data)
+
+	if len(entry.Tasks) > 0 || entry.HasProject {
+		if fm, err := parseFrontmatterFrom(bytes.NewReader(data)); err == nil {
+			entry.Frontmatter = fm
+		}
+	}
+	return entry
+}
+
+// sortedPaths returns the indexed file paths in lexical order.
+func (ix *TaskIndex) sortedPaths() []string {
+	paths := make([]string, 0, len(ix.Files))
+	for p := range ix.Files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Tasks returns the cached tasks for the files under notesPaths, re-reporting
+// cached strict-mode date errors into ctx and priming the frontmatter cache so
+// later merge steps do not touch the disk.
+func (ix *TaskIndex) Tasks(ctx *ParseContext, notesPaths ...string) []Task {
+	roots := expandGlobs(notesPaths)
+	var tasks []Task
+	for _, p := range ix.sortedPaths() {
+		if !underAnyRoot(p, roots) {
+			continue
+		}
+		e := ix.Files[p]
+		if len(e.Tasks) > 0 || e.HasProject {
+			primeFrontmatterCache(p, e.Frontmatter, e.ModTime, e.Size)
+		}
+		for _, de := range e.DateErrors {
+			collectDateError(ctx.dateErrors, DateError{
+				FilePath:   p,
+				LineNumber: de.LineNumber,
+				DateStr:    de.DateStr,
+				Context:    de.Context,
+				Err:        errors.New(de.Message),
+			})
+		}
+		for _, t := range e.Tasks {
+			t.Tags = append([]string(nil), t.Tags...)
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// Projects returns the synthetic project tasks for indexed files under notesPaths.
+func (ix *TaskIndex) Projects(goDateFmt string, fmCfg FrontmatterConfig, dateErrors *[]DateError, notesPaths ...string) []Task {
+	roots := expandGlobs(notesPaths)
+	var tasks []Task
+	for _, p := range ix.sortedPaths() {
+		e := ix.Files[p]
+		if !e.HasProject || e.Frontmatter == nil || !underAnyRoot(p, roots) {
+			continue
+		}
+		if t, ok := projectTask(p, e.Frontmatter, goDateFmt, fmCfg, dateErrors); ok {
+			tasks = append(tasks, t)
+		}
+	}
+	return tasks
+}
+
+// Invalidate drops the entry for filePath so the next Refresh re-parses it
+// even if its mtime and size did not change.
+func (ix *TaskIndex) Invalidate(filePath string) {
+	delete(ix.Files, filePath)
+}
+
+// TaskCount returns the total number of cached tasks.
+func (ix *TaskIndex) TaskCount() int {
+	n := 0
+	for _, e := range ix.Files {
+		n += len(e.Tasks)
+	}
+	return n
+}
+
+// warmIndex, when set, is an in-memory index kept alive across calls by a
+// long-running process (task serve); scanVault refreshes it instead of
+// loading the on-disk index or rescanning.
+var warmIndex *TaskIndex
+
+// useWarmIndex installs warmIndex for the lifetime of a long-running command,
+// seeding it from the on-disk index. The returned function saves the index
+// (when cfg.Index is set) and uninstalls it; call it when the command exits.
+func useWarmIndex(ctx *ParseContext, cfg Config) func() {
+	if warmIndex != nil {
+		return func() {}
+	}
+	ix, err := LoadTaskIndex(cfg.StateDir, ctx)
+	if err != nil {
+		ix = newTaskIndex(parseFingerprint(ctx))
+	}
+	warmIndex = ix
+	return func() {
+		if cfg.Index {
+			if err := warmIndex.Save(cfg.StateDir); err != nil && Verbose {
+				fmt.Fprintf(os.Stderr, "taskbuffer: saving index: %v\n", err)
+			}
+		}
+		warmIndex = nil
+	}
+}
+
+// scanVault returns the parsed tasks (and, if withProjects, the project tasks)
+// for notesPaths. When cfg.Index is set the on-disk index is refreshed and
+// used; otherwise the vault is scanned from scratch.
+func scanVault(notesPaths []string, ctx *ParseContext, cfg Config, withProjects bool) ([]Task, []Task, error) {
+	if !cfg.Index && warmIndex == nil {
+		matches, err := Scan(ctx, notesPaths...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan: %w", err)
+		}
+		tasks := ParseTasks(AnnotateMatches(ctx, matches), ctx)
+		if !withProjects {
+			return tasks, nil, nil
+		}
+		projects, err := ScanProjectsWith(ctx.scanBackend, ctx.formats.GoDate, cfg.Frontmatter, ctx.dateErrors, notesPaths...)
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan projects: %w", err)
+		}
+		return tasks, projects, nil
+	}
+
+	ix := warmIndex
+	if ix == nil {
+		var err error
+		ix, err = LoadTaskIndex(cfg.StateDir, ctx)
+		if err != nil {
+			return nil, nil, fmt.Errorf("loading index: %w", err)
+		}
+	}
+	stats, err := ix.Refresh(ctx, notesPaths...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("scan: %w", err)
+	}
+	if cfg.Index && (stats.Parsed > 0 || stats.Removed > 0) {
+		if err := ix.Save(cfg.StateDir); err != nil && Verbose {
+			fmt.Fprintf(os.Stderr, "taskbuffer: saving index: %v\n", err)
+		}
+	}
+	tasks := ix.Tasks(ctx, notesPaths...)
+	var projects []Task
+	if withProjects {
+		projects = ix.Projects(ctx.formats.GoDate, cfg.Frontmatter, ctx.dateErrors, notesPaths...)
+	}
+	return tasks, projects, nil
+}
+
+// cmdIndex manages the on-disk task index: `task index rebuild|stats`.
+func cmdIndex(notesPaths []string, ctx *ParseContext, cfg Config, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: task index rebuild|stats")
+	}
+	path := indexPathFor(cfg.StateDir)
+
+	switch args[0] {
+	case "rebuild":
+		ix := newTaskIndex(parseFingerprint(ctx))
+		stats, err := ix.Refresh(ctx, notesPaths...)
+		if err != nil {
+			return err
+		}
+		if err := ix.Save(cfg.StateDir); err != nil {
+			return fmt.Errorf("saving index: %w", err)
+		}
+		fmt.Printf("Indexed %d files, %d tasks\n", stats.Files, ix.TaskCount())
+		return nil
+
+	case "stats":
+		ix, err := readTaskIndex(path)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				fmt.Printf("No index at %s\n", path)
+				return nil
+			}
+			return err
+		}
+		size := int64(0)
+		if info, err := os.Stat(path); err == nil {
+			size = info.Size()
+		}
+		status := "current"
+		if ix.Version != indexVersion || ix.Fingerprint != parseFingerprint(ctx) {
+			status = "stale (rebuilt on next use)"
+		}
+		fmt.Printf("path:    %s\n", path)
+		fmt.Printf("files:   %d\n", len(ix.Files))
+		fmt.Printf("tasks:   %d\n", ix.TaskCount())
+		fmt.Printf("size:    %d bytes\n", size)
+		if ix.Updated > 0 {
+			fmt.Printf("updated: %s\n", time.Unix(ix.Updated, 0).In(time.Local).Format("2006-01-02 15:04:05"))
+		}
+		fmt.Printf("config:  %s\n", status)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown index command %q (want rebuild or stats)", args[0])
+	}
+}
@@ -0,0 +1,302 @@
+package main
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// captureStdout runs fn with os.Stdout redirected and returns what it printed.
+func captureStdout(t *testing.T, fn func() error) (string, error) {
+	t.Helper()
+	old := os.Stdout
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+	done := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		done <- string(data)
+	}()
+	fnErr := fn()
+	w.Close()
+	os.Stdout = old
+	return <-done, fnErr
+}
+
+// taskKeys renders tasks as sorted "path:line:body" strings for comparison.
+func taskKeys(tasks []Task) []string {
+	keys := make([]string, len(tasks))
+	for i, t := range tasks {
+		keys[i] = t.FilePath + ":" + strconv.Itoa(t.LineNumber) + ":" + t.Body + ":" + strings.Join(t.Tags, ",")
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestIndex_ReparsesOnlyChangedFiles(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Task A (@[[2026-02-17]])\n",
+		"b.md": "- [ ] Task B\n",
+	})
+	ctx := DefaultParseContext()
+
+	ix, err := LoadTaskIndex(state, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, err := ix.Refresh(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Files != 2 || stats.Parsed != 2 {
+		t.Fatalf("first refresh = %+v, want 2 files parsed", stats)
+	}
+	if err := ix.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	ix, err = LoadTaskIndex(state, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stats, _ = ix.Refresh(ctx, dir)
+	if stats.Parsed != 0 {
+		t.Errorf("unchanged refresh parsed %d files, want 0", stats.Parsed)
+	}
+
+	os.WriteFile(filepath.Join(dir, "b.md"), []byte("- [ ] Task B changed #new\n"), 0644)
+	stats, _ = ix.Refresh(ctx, dir)
+	if stats.Parsed != 1 {
+		t.Errorf("after edit parsed %d files, want 1", stats.Parsed)
+	}
+	if findTask(ix.Tasks(ctx, dir), "Task B changed") == nil {
+		t.Error("edited task not picked up")
+	}
+}
+
+func TestIndex_DropsRemovedFiles(t *testing.T) {
+	dir := t.TempDir()
+	other := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] A\n", "b.md": "- [ ] B\n"})
+	writeTree(t, other, map[string]string{"c.md": "- [ ] C\n"})
+	ctx := DefaultParseContext()
+	ix := newTaskIndex(parseFingerprint(ctx))
+	ix.Refresh(ctx, dir)
+	ix.Refresh(ctx, other)
+
+	os.Remove(filepath.Join(dir, "b.md"))
+	stats, _ := ix.Refresh(ctx, dir)
+	if stats.Removed != 1 {
+		t.Errorf("removed = %d, want 1", stats.Removed)
+	}
+	// Entries outside the refreshed roots are kept for other source sets
+	if _, ok := ix.Files[filepath.Join(other, "c.md")]; !ok {
+		t.Error("entry outside refreshed root was dropped")
+	}
+	if got := taskBodies(ix.Tasks(ctx, dir)); strings.Join(got, ",") != "A" {
+		t.Errorf("tasks = %v, want [A]", got)
+	}
+}
+
+func TestIndex_UsesScanBackend(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] A\n", "sub/b.md": "- [ ] B\n"})
+	refresh := func(backend string) ([]string, error) {
+		ctx := NewParseContext(Config{ScanBackend: backend})
+		ix := newTaskIndex(parseFingerprint(ctx))
+		if _, err := ix.Refresh(ctx, dir); err != nil {
+			return nil, err
+		}
+		return taskKeys(ix.Tasks(ctx, dir)), nil
+	}
+
+	if _, err := refresh("bogus"); err == nil {
+		t.Error("unknown scan backend should fail")
+	}
+	goKeys, err := refresh("go")
+	if err != nil || len(goKeys) != 2 {
+		t.Fatalf("go backend: %v, %v", goKeys, err)
+	}
+	rgKeys, err := refresh("rg")
+	if !rgAvailable() {
+		if err == nil {
+			t.Error("rg backend should fail without rg on PATH")
+		}
+		return
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(rgKeys, "\n") != strings.Join(goKeys, "\n") {
+		t.Errorf("backends differ\nrg: %v\ngo: %v", rgKeys, goKeys)
+	}
+}
+
+func TestIndex_InvalidatedByParseConfig(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] A (@[[2026-02-17]])\n"})
+
+	ctx := DefaultParseContext()
+	ix, _ := LoadTaskIndex(state, ctx)
+	ix.Refresh(ctx, dir)
+	if err := ix.Save(state); err != nil {
+		t.Fatal(err)
+	}
+
+	same, _ := LoadTaskIndex(state, DefaultParseContext())
+	if len(same.Files) != 1 {
+		t.Errorf("same config: %d files, want 1", len(same.Files))
+	}
+
+	for name, cfg := range map[string]Config{
+		"date format": {DateFormat: "%d/%m/%Y"},
+		"checkbox":    {Checkbox: map[string]string{"open": "* [ ]", "done": "* [x]"}},
+		"wrapper":     {DateWrapper: []string{"<", ">"}},
+		"strict":      {Strict: true},
+	} {
+		got, err := LoadTaskIndex(state, NewParseContext(cfg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got.Files) != 0 {
+			t.Errorf("%s change: index not invalidated (%d files)", name, len(got.Files))
+		}
+	}
+}
+
+func TestIndex_CorruptFileStartsFresh(t *testing.T) {
+	state := t.TempDir()
+	os.WriteFile(filepath.Join(state, indexFile), []byte("not a gob"), 0644)
+	ix, err := LoadTaskIndex(state, DefaultParseContext())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ix.Files) != 0 {
+		t.Errorf("got %d files from corrupt index", len(ix.Files))
+	}
+}
+
+func TestIndex_MatchesFreshScanOnFixtures(t *testing.T) {
+	for _, name := range []string{"basic-vault", "fm-due-vault", "frontmatter-vault", "tagged-vault"} {
+		t.Run(name, func(t *testing.T) {
+			vault := vaultPath(t, name)
+			ctx := DefaultParseContext()
+
+			ResetFrontmatterCache()
+			scanned, scannedProjects, err := scanVault([]string{vault}, ctx, Config{}, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			MergeFrontmatterTags(scanned)
+
+			state := t.TempDir()
+			cfg := Config{Index: true, StateDir: state}
+			// First call builds the index, second call serves entirely from disk
+			if _, _, err := scanVault([]string{vault}, ctx, cfg, true); err != nil {
+				t.Fatal(err)
+			}
+			ResetFrontmatterCache()
+			indexed, indexedProjects, err := scanVault([]string{vault}, ctx, cfg, true)
+			if err != nil {
+				t.Fatal(err)
+			}
+			MergeFrontmatterTags(indexed)
+
+			if a, b := strings.Join(taskKeys(scanned), "\n"), strings.Join(taskKeys(indexed), "\n"); a != b {
+				t.Errorf("tasks differ\nscan:\n%s\nindex:\n%s", a, b)
+			}
+			if a, b := strings.Join(taskKeys(scannedProjects), "\n"), strings.Join(taskKeys(indexedProjects), "\n"); a != b {
+				t.Errorf("projects differ\nscan:\n%s\nindex:\n%s", a, b)
+			}
+		})
+	}
+}
+
+func TestIndex_ReplaysStrictDateErrors(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] Bad date (@[[2026-02-30]])\n"})
+	cfg := Config{Strict: true, Index: true, StateDir: state}
+
+	for i := 0; i < 2; i++ {
+		ctx := NewParseContext(cfg)
+		var dateErrors []DateError
+		ctx.dateErrors = &dateErrors
+		if _, _, err := scanVault([]string{dir}, ctx, cfg, false); err != nil {
+			t.Fatal(err)
+		}
+		if len(dateErrors) != 1 || dateErrors[0].LineNumber != 1 {
+			t.Errorf("run %d: date errors = %v, want one on line 1", i, dateErrors)
+		}
+	}
+}
+
+func TestCmdIndex_RebuildAndStats(t *testing.T) {
+	vault := vaultPath(t, "basic-vault")
+	state := t.TempDir()
+	cfg := Config{StateDir: state}
+	ctx := DefaultParseContext()
+
+	out, err := captureStdout(t, func() error { return cmdIndex([]string{vault}, ctx, cfg, []string{"stats"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "No index") {
+		t.Errorf("stats without index = %q", out)
+	}
+
+	out, err = captureStdout(t, func() error { return cmdIndex([]string{vault}, ctx, cfg, []string{"rebuild"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Indexed 2 files, 7 tasks") {
+		t.Errorf("rebuild output = %q", out)
+	}
+
+	out, err = captureStdout(t, func() error { return cmdIndex([]string{vault}, ctx, cfg, []string{"stats"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"files:   2", "tasks:   7", "config:  current"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("stats output missing %q:\n%s", want, out)
+		}
+	}
+
+	other := NewParseContext(Config{DateFormat: "%d/%m/%Y"})
+	out, _ = captureStdout(t, func() error { return cmdIndex([]string{vault}, other, cfg, []string{"stats"}) })
+	if !strings.Contains(out, "stale") {
+		t.Errorf("stats with changed config should report stale:\n%s", out)
+	}
+
+	if err := cmdIndex(nil, ctx, cfg, []string{"frobnicate"}); err == nil {
+		t.Error("expected error for unknown index command")
+	}
+}
+
+func TestCmdList_WithIndex(t *testing.T) {
+	vault := vaultPath(t, "basic-vault")
+	cfg := Config{Index: true, StateDir: t.TempDir()}
+	for i := 0; i < 2; i++ {
+		out, err := captureStdout(t, func() error { return cmdList([]string{vault}, DefaultParseContext(), nil, cfg) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(out, "Morning standup") || !strings.Contains(out, "Learn Rust") {
+			t.Errorf("run %d: output missing tasks:\n%s", i, out)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(cfg.StateDir, indexFile)); err != nil {
+		t.Errorf("index file not written: %v", err)
+	}
+}
@@ -5,9 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"os/exec"
 	"sort"
-	"strconv"
 	"strings"
 	"time"
 )
@@ -113,6 +111,14 @@ type Config struct {
 	WeekStart       string            `json:"week_start,omitempty"`
 	Frontmatter     FrontmatterConfig `json:"frontmatter,omitempty"`
 	Strict          bool              `json:"strict,omitempty"`
+	ScanBackend     string            `json:"scan_backend,omitempty"` // "auto" (default), "rg" or "go"
+	Index           bool              `json:"index,omitempty"`        // use the persistent task index in StateDir
+	RecurFrom       string            `json:"recur_from,omitempty"`   // "due" (default) or "completion"
+	Subtasks        SubtaskConfig     `json:"subtasks,omitempty"`
+	SkipBlocks      []string          `json:"skip_blocks,omitempty"`     // "fenced", "indented", "comment" (default all), or "none"
+	PrioritySyntax  []string          `json:"priority_syntax,omitempty"` // "bang", "letter", "field" (default all), or "none"
+	Sort            string            `json:"sort,omitempty"`            // order within a horizon: "date" (default) or "priority"
+	OverdueByTime   bool              `json:"overdue_by_time,omitempty"` // tasks due today at a past time count as overdue
 }
 
 // Verbose controls whether parse warnings are printed to stderr.
@@ -161,155 +167,166 @@ func parseConfig(configJSON string) Config {
 }
 
 func cmdList(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
-	var tags tagList
+	out, err := renderList(notesPaths, ctx, args, cfg)
+	if err != nil {
+		return err
+	}
+	fmt.Print(out)
+	return nil
+}
+
+// renderList runs the list pipeline and returns the formatted taskfile, or
+// JSON with --format json/ndjson.
+func renderList(notesPaths []string, ctx *ParseContext, args []string, cfg Config) (string, error) {
+	var tags, priorities tagList
 	var showMarkers bool
 	var ignoreUndated bool
+	var format string
+	var query string
+	var sortBy string
 
 	fs := flag.NewFlagSet("list", flag.ContinueOnError)
 	fs.Var(&tags, "tag", "filter by tag (repeatable, OR logic)")
+	fs.Var(&priorities, "priority", "filter by priority: high, medium, low or none (repeatable, OR logic)")
+	fs.StringVar(&query, "query", "", "filter expression, e.g. '#work AND due<+7d'")
+	fs.StringVar(&sortBy, "sort", cfg.Sort, "order within a horizon: date or priority")
 	fs.BoolVar(&showMarkers, "markers", false, "show :: markers")
 	fs.BoolVar(&ignoreUndated, "ignore-undated", false, "hide undated tasks")
+	fs.StringVar(&format, "format", "taskfile", "output format: taskfile, json or ndjson")
 	if err := fs.Parse(args); err != nil {
-		return err
+		return "", err
 	}
-
-	var dateErrors []DateError
-	if ctx.strict {
-		ctx.dateErrors = &dateErrors
+	if format != "taskfile" && format != "json" && format != "ndjson" {
+		return "", fmt.Errorf("unknown list format %q (want taskfile, json or ndjson)", format)
 	}
-
-	matches, err := Scan(ctx, notesPaths...)
-	if err != nil {
-		return fmt.Errorf("scan: %w", err)
+	if sortBy != "" && sortBy != "date" && sortBy != "priority" {
+		return "", fmt.Errorf("unknown sort %q (want date or priority)", sortBy)
 	}
-
-	allTasks := ParseTasks(matches, ctx)
-	MergeFrontmatterTags(allTasks)
-	allTasks = FilterCompletedFrontmatterTasks(allTasks, cfg.Frontmatter)
-	MergeFrontmatterDue(allTasks, cfg.Frontmatter, ctx.formats.GoDate, ctx.dateErrors)
-
-	projectTasks, err := ScanProjects(ctx.formats.GoDate, cfg.Frontmatter, ctx.dateErrors, notesPaths...)
+	now := time.Now().In(time.Local)
+	q, err := ParseQuery(query, ctx, now)
 	if err != nil {
-		return fmt.Errorf("scan projects: %w", err)
+		return "", err
 	}
-	allTasks = append(allTasks, projectTasks...)
-
-	var tasks []Task
-	for _, t := range allTasks {
-		if t.Status == "open" {
-			tasks = append(tasks, t)
-		}
-	}
-
-	if ctx.strict && len(dateErrors) > 0 {
-		for _, e := range dateErrors {
-			fmt.Fprintf(os.Stderr, "%s\n", e.Error())
-		}
-		return fmt.Errorf("%d invalid date(s) found", len(dateErrors))
+	pq, err := priorityQuery(priorities)
+	if err != nil {
+		return "", err
 	}
 
-	now := time.Now().In(time.Local)
-	weekStart := parseWeekday(cfg.WeekStart)
-	overlap := cfg.HorizonsOverlap
-	if overlap == "" {
-		overlap = "sorted"
+	tasks, err := loadOpenTasks(notesPaths, ctx, cfg)
+	if err != nil {
+		return "", err
 	}
 
-	horizons, _ := ResolveHorizons(cfg.Horizons, now, weekStart, overlap)
+	horizons, overlap := listHorizons(cfg, now)
 
 	opts := FormatOpts{
 		ShowMarkers:   showMarkers,
 		IgnoreUndated: ignoreUndated,
 		TagFilter:     tags,
+		Query:         andQueries(q, pq),
 		TagPrefix:     ctx.tagPrefix,
 		Horizons:      horizons,
 		Overlap:       overlap,
 		DateFormat:    ctx.formats.GoDate,
+		Sort:          sortBy,
+		OverdueByTime: cfg.OverdueByTime,
 	}
-	fmt.Print(FormatTaskfile(tasks, now, opts))
-	return nil
+	if format != "taskfile" {
+		return FormatJSON(tasks, now, opts, format == "ndjson")
+	}
+	return FormatTaskfile(tasks, now, opts), nil
 }
 
-func cmdDo(notesPaths []string, ctx *ParseContext, cfg Config) error {
-	now := time.Now().In(time.Local)
-	today := now.Format(ctx.formats.GoDate)
-
-	existing, err := ReadCurrentTaskFrom(cfg.StateDir)
-	if err != nil {
-		return err
+// listHorizons resolves the configured horizons at now, and returns them with
+// the overlap mode to group tasks by.
+func listHorizons(cfg Config, now time.Time) ([]ResolvedHorizon, string) {
+	overlap := cfg.HorizonsOverlap
+	if overlap == "" {
+		overlap = "sorted"
 	}
-	if existing != nil {
-		if err := cmdStopWithConfig(cfg); err != nil {
-			return fmt.Errorf("stopping current task: %w", err)
-		}
+	horizons, _ := ResolveHorizons(cfg.Horizons, now, parseWeekday(cfg.WeekStart), overlap)
+	return horizons, overlap
+}
+
+// loadOpenTasks runs the scan and frontmatter merge pipeline and returns the
+// open tasks, including synthetic project tasks. In strict mode any invalid
+// date is printed to stderr and reported as an error.
+func loadOpenTasks(notesPaths []string, ctx *ParseContext, cfg Config) ([]Task, error) {
+	var dateErrors []DateError
+	if ctx.strict {
+		ctx.dateErrors = &dateErrors
 	}
 
-	matches, err := Scan(ctx, notesPaths...)
+	allTasks, projectTasks, err := scanVault(notesPaths, ctx, cfg, true)
 	if err != nil {
-		return fmt.Errorf("scan: %w", err)
+		return nil, err
 	}
-	allTasks := ParseTasks(matches, ctx)
 	MergeFrontmatterTags(allTasks)
 	allTasks = FilterCompletedFrontmatterTasks(allTasks, cfg.Frontmatter)
-	MergeFrontmatterDue(allTasks, cfg.Frontmatter, ctx.formats.GoDate, nil)
-	var todayTasks []Task
+	InheritFromParents(allTasks, cfg.Subtasks)
+	MergeFrontmatterDue(allTasks, cfg.Frontmatter, ctx.formats.GoDate, ctx.dateErrors)
+	allTasks = append(allTasks, projectTasks...)
+
+	var tasks []Task
 	for _, t := range allTasks {
-		if t.Status == "open" && t.DueDate != nil && t.DueDate.Format(ctx.formats.GoDate) == today {
-			todayTasks = append(todayTasks, t)
+		if t.Status == "open" {
+			tasks = append(tasks, t)
 		}
 	}
-	if len(todayTasks) == 0 {
-		fmt.Println("No tasks due today.")
-		return nil
-	}
 
-	var fzfInput strings.Builder
-	for i, t := range todayTasks {
-		fmt.Fprintf(&fzfInput, "%d\t%s\n", i, t.Body)
-	}
-
-	cmd := exec.Command("fzf", "--with-nth=2..", "--delimiter=\t")
-	cmd.Stdin = strings.NewReader(fzfInput.String())
-	cmd.Stderr = os.Stderr
-	out, err := cmd.Output()
-	if err != nil {
-		fmt.Println("No task selected.")
-		return nil
-	}
-
-	selection := strings.TrimRight(string(out), "\n\r")
-	parts := strings.SplitN(selection, "\t", 2)
-	var idx int
-	fmt.Sscanf(parts[0], "%d", &idx)
-	if idx < 0 || idx >= len(todayTasks) {
-		return fmt.Errorf("invalid selection index: %d", idx)
+	if ctx.strict && len(dateErrors) > 0 {
+		for _, e := range dateErrors {
+			fmt.Fprintf(os.Stderr, "%s\n", e.Error())
+		}
+		return nil, fmt.Errorf("%d invalid date(s) found", len(dateErrors))
 	}
-	task := todayTasks[idx]
+	return tasks, nil
+}
 
-	marker := FormatMarker("start", now, ctx.formats)
+// startTask writes a start marker on the task's line and records it as the
+// current task, started at now.
+func startTask(ctx *ParseContext, cfg Config, task Task, now time.Time) error {
+	marker := FormatMarker("start", now, ctx)
 	if err := AppendToLine(task.FilePath, task.LineNumber, marker); err != nil {
 		return fmt.Errorf("writing start marker: %w", err)
 	}
 
 	ct := CurrentTask{
-		StartTime:  now.Unix(),
-		Name:       task.Body,
-		FilePath:   task.FilePath,
-		LineNumber: task.LineNumber,
+		StartTime:   now.Unix(),
+		Name:        task.Body,
+		FilePath:    task.FilePath,
+		LineNumber:  task.LineNumber,
+		ID:          task.ID,
+		Fingerprint: TaskFingerprint(task.Status, task.Body),
 	}
 	if err := WriteCurrentTaskTo(cfg.StateDir, ct); err != nil {
 		return fmt.Errorf("saving state: %w", err)
 	}
-
-	fmt.Printf("Started: %s\n", task.Body)
 	return nil
 }
 
-func cmdStopWithConfig(cfg Config) error {
-	now := time.Now().In(time.Local)
-	fmts := ResolveDateTimeFormats(cfg.DateFormat, cfg.TimeFormat)
+// readTaskAt parses the task on a specific line of a file.
+func readTaskAt(ctx *ParseContext, filePath string, lineNum int) (Task, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return Task{}, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if lineNum < 1 || lineNum > len(lines) {
+		return Task{}, fmt.Errorf("line %d out of range", lineNum)
+	}
+	return ParseTask(RawMatch{Path: filePath, LineNumber: lineNum, Text: lines[lineNum-1]}, ctx)
+}
 
-	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+// cmdStopWithConfig stops the running task, now or --at an earlier time.
+//
+//	task stop [--at TIME]
+func cmdStopWithConfig(ctx *ParseContext, cfg Config, args []string) error {
+	at, err := parseAtFlag("stop", args, ctx, time.Now().In(time.Local))
+	if err != nil {
+		return err
+	}
+	ct, err := stopCurrentTaskAt(cfg, at)
 	if err != nil {
 		return err
 	}
@@ -317,29 +334,62 @@ func cmdStopWithConfig(cfg Config) error {
 		fmt.Println("No task running.")
 		return nil
 	}
+	fmt.Printf("Stopped: %s\n", ct.Name)
+	return nil
+}
 
-	marker := FormatMarker("stop", now, fmts)
-	if err := AppendToLine(ct.FilePath, ct.LineNumber, marker); err != nil {
-		return fmt.Errorf("writing stop marker: %w", err)
+// stopCurrentTask writes a stop marker for the running task and clears the
+// state. Returns nil if no task is running.
+func stopCurrentTask(cfg Config) (*CurrentTask, error) {
+	return stopCurrentTaskAt(cfg, time.Time{})
+}
+
+// stopCurrentTaskAt is stopCurrentTask with the stop at a given time, which
+// may not precede the task's start or another timer marker on its line. A
+// zero at means now.
+func stopCurrentTaskAt(cfg Config, at time.Time) (*CurrentTask, error) {
+	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if ct == nil {
+		return nil, nil
+	}
+	ctx := NewParseContext(cfg)
+	if err := locateCurrentTask(ctx, ct); err != nil {
+		return nil, err
+	}
+	if at.IsZero() {
+		at = time.Now().In(time.Local)
+	} else if err := checkStopAt(ctx, ct, "stop", at); err != nil {
+		return nil, err
 	}
 
-	if err := ClearCurrentTaskFrom(cfg.StateDir); err != nil {
-		return err
+	marker := FormatMarker("stop", at, ctx)
+	if err := AppendToLine(ct.FilePath, ct.LineNumber, marker); err != nil {
+		return nil, fmt.Errorf("writing stop marker: %w", err)
 	}
 
-	fmt.Printf("Stopped: %s\n", ct.Name)
-	return nil
+	if err := FinishCurrentTaskFrom(cfg.StateDir, *ct, at, false); err != nil {
+		return nil, err
+	}
+	return ct, nil
 }
 
 func cmdStop() error {
-	return cmdStopWithConfig(Config{})
+	return cmdStopWithConfig(DefaultParseContext(), Config{}, nil)
 }
 
-func cmdCompleteWithConfig(cfg Config) error {
-	now := time.Now().In(time.Local)
-	fmts := ResolveDateTimeFormats(cfg.DateFormat, cfg.TimeFormat)
-
-	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+// cmdCompleteWithConfig completes the running task, now or --at an earlier
+// time.
+//
+//	task complete [--at TIME]
+func cmdCompleteWithConfig(ctx *ParseContext, cfg Config, args []string) error {
+	at, err := parseAtFlag("complete", args, ctx, time.Now().In(time.Local))
+	if err != nil {
+		return err
+	}
+	ct, err := completeCurrentTaskAt(cfg, at)
 	if err != nil {
 		return err
 	}
@@ -347,57 +397,96 @@ func cmdCompleteWithConfig(cfg Config) error {
 		fmt.Println("No task running.")
 		return nil
 	}
+	fmt.Printf("Completed: %s\n", ct.Name)
+	return nil
+}
+
+// completeCurrentTask writes a complete marker for the running task, checks
+// it off and clears the state. Returns nil if no task is running.
+func completeCurrentTask(cfg Config) (*CurrentTask, error) {
+	return completeCurrentTaskAt(cfg, time.Time{})
+}
+
+// completeCurrentTaskAt is completeCurrentTask at a given time, checked as
+// for stopCurrentTaskAt. A zero at means now.
+func completeCurrentTaskAt(cfg Config, at time.Time) (*CurrentTask, error) {
+	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+	if err != nil {
+		return nil, err
+	}
+	if ct == nil {
+		return nil, nil
+	}
+	ctx := NewParseContext(cfg)
+	if err := locateCurrentTask(ctx, ct); err != nil {
+		return nil, err
+	}
+	if at.IsZero() {
+		at = time.Now().In(time.Local)
+	} else if err := checkStopAt(ctx, ct, "complete", at); err != nil {
+		return nil, err
+	}
+	recurring, line, err := recurringTaskAt(ctx, ct.FilePath, ct.LineNumber)
+	if err != nil {
+		return nil, err
+	}
 
-	marker := FormatMarker("complete", now, fmts)
+	marker := FormatMarker("complete", at, ctx)
 	if err := AppendToLine(ct.FilePath, ct.LineNumber, marker); err != nil {
-		return fmt.Errorf("writing complete marker: %w", err)
+		return nil, fmt.Errorf("writing complete marker: %w", err)
 	}
 	if err := CheckOffTask(ct.FilePath, ct.LineNumber); err != nil {
-		return fmt.Errorf("checking off task: %w", err)
+		return nil, fmt.Errorf("checking off task: %w", err)
 	}
-
-	if err := ClearCurrentTaskFrom(cfg.StateDir); err != nil {
-		return err
+	if err := insertNextOccurrence(ctx, recurring, line, at); err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("Completed: %s\n", ct.Name)
-	return nil
+	if err := FinishCurrentTaskFrom(cfg.StateDir, *ct, at, true); err != nil {
+		return nil, err
+	}
+	return ct, nil
 }
 
 func cmdComplete() error {
-	return cmdCompleteWithConfig(Config{})
+	return cmdCompleteWithConfig(DefaultParseContext(), Config{}, nil)
 }
 
-func cmdCurrent(cfg Config) error {
-	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+func cmdTags(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
+	tags, err := collectTags(notesPaths, ctx, args, cfg)
 	if err != nil {
 		return err
 	}
-	if ct == nil {
-		return nil
+	for _, tag := range tags {
+		fmt.Println(tag)
 	}
-	fmt.Println(ct.Name)
 	return nil
 }
 
-func cmdTags(notesPaths []string, ctx *ParseContext, cfg Config) error {
-	matches, err := Scan(ctx, notesPaths...)
+// collectTags returns the sorted set of tags used by open tasks, limited to
+// tasks matching --query if given.
+func collectTags(notesPaths []string, ctx *ParseContext, args []string, cfg Config) ([]string, error) {
+	fs := flag.NewFlagSet("tags", flag.ContinueOnError)
+	query := fs.String("query", "", "only count tasks matching this filter expression")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	q, err := ParseQuery(*query, ctx, time.Now().In(time.Local))
 	if err != nil {
-		return fmt.Errorf("scan: %w", err)
+		return nil, err
 	}
 
-	allTasks := ParseTasks(matches, ctx)
-	MergeFrontmatterTags(allTasks)
-
-	projectTasks, err := ScanProjects(ctx.formats.GoDate, cfg.Frontmatter, nil, notesPaths...)
+	allTasks, projectTasks, err := scanVault(notesPaths, ctx, cfg, true)
 	if err != nil {
-		return fmt.Errorf("scan projects: %w", err)
+		return nil, err
 	}
+	MergeFrontmatterTags(allTasks)
+	InheritFromParents(allTasks, cfg.Subtasks)
 	allTasks = append(allTasks, projectTasks...)
 
 	seen := make(map[string]bool)
 	for _, t := range allTasks {
-		if t.Status != "open" {
+		if t.Status != "open" || !q.Match(t) {
 			continue
 		}
 		for _, tag := range t.Tags {
@@ -410,88 +499,75 @@ func cmdTags(notesPaths []string, ctx *ParseContext, cfg Config) error {
 		tags = append(tags, tag)
 	}
 	sort.Strings(tags)
-
-	for _, tag := range tags {
-		fmt.Println(tag)
-	}
-	return nil
+	return tags, nil
 }
 
-// cmdDefer adds a ::deferral marker and preserves the original date.
+// cmdDefer adds a ::deferral marker and preserves the original date. With
+// --to it also moves the due date (see deferLine).
 func cmdDefer(ctx *ParseContext, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: task defer <filepath> <linenum>")
-	}
-	filePath := args[0]
-	lineNum, err := strconv.Atoi(args[1])
+	fs := flag.NewFlagSet("defer", flag.ContinueOnError)
+	to := fs.String("to", "", "new due date: a date, today, tomorrow, +Nd, next-monday, end_of_week, ...")
+	filePath, lineNum, err := taskLocationWith(ctx, fs, args, "task defer <filepath> <linenum|^id> [--to DATE]")
 	if err != nil {
-		return fmt.Errorf("bad line number: %w", err)
+		return err
 	}
 
 	now := time.Now().In(time.Local)
-
-	// Read the line to check for existing ::original marker
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
-	lines := strings.Split(string(data), "\n")
-	idx := lineNum - 1
-	if idx < 0 || idx >= len(lines) {
-		return fmt.Errorf("line %d out of range", lineNum)
-	}
-
-	line := lines[idx]
-
-	// If no ::original marker, copy the current date as ::original
-	if !strings.Contains(line, "::original") {
-		// Extract the current due date from the line
-		dateMatch := ctx.dateRe.FindStringSubmatch(line)
-		if dateMatch != nil {
-			originalMarker := fmt.Sprintf(" ::original [[%s]]", dateMatch[1])
-			line = strings.TrimRight(line, " \t") + originalMarker
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNum - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range", lineNum)
 		}
-	}
-
-	// Append ::deferral marker
-	deferralMarker := FormatMarker("deferral", now, ctx.formats)
-	line = strings.TrimRight(line, " \t") + " " + deferralMarker
-	lines[idx] = line
-
-	return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644)
+		var target *time.Time
+		if *to != "" {
+			// Offsets like +3d count from the task's current due date
+			var due *time.Time
+			if task, err := ParseTask(RawMatch{Path: filePath, LineNumber: lineNum, Text: lines[idx]}, ctx); err == nil {
+				if due = task.DueDate; due == nil {
+					due = inheritedDue(task, ctx)
+				}
+			}
+			d, err := parseDeferTarget(*to, now, due, ctx)
+			if err != nil {
+				return nil, err
+			}
+			target = &d
+		}
+		if err := deferLine(lines, idx, target, now, ctx, filePath); err != nil {
+			return nil, err
+		}
+		return lines, nil
+	})
 }
 
-// cmdIrrelevant marks a task as irrelevant: changes checkbox to [-] and appends marker.
+// cmdIrrelevant marks a task as irrelevant: changes checkbox to [-] and appends
+// marker, in a single rewrite of the file.
 func cmdIrrelevant(ctx *ParseContext, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: task irrelevant <filepath> <linenum>")
-	}
-	filePath := args[0]
-	lineNum, err := strconv.Atoi(args[1])
+	filePath, lineNum, err := taskLocation(ctx, args, "task irrelevant <filepath> <linenum|^id>")
 	if err != nil {
-		return fmt.Errorf("bad line number: %w", err)
+		return err
 	}
 
 	now := time.Now().In(time.Local)
-	marker := FormatMarker("irrelevant", now, ctx.formats)
+	marker := FormatMarker("irrelevant", now, ctx)
 
 	openCb := ctx.checkbox["open"]
 	irrCb := ctx.checkbox["irrelevant"]
-	if err := ChangeCheckbox(filePath, lineNum, openCb, irrCb); err != nil {
-		return err
-	}
-	return AppendToLine(filePath, lineNum, marker)
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNum - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNum, len(lines))
+		}
+		lines[idx] = appendToTaskLine(strings.Replace(lines[idx], openCb, irrCb, 1), marker)
+		return lines, nil
+	})
 }
 
 // cmdUnset undoes an irrelevant marking: removes last marker and restores checkbox.
 func cmdUnset(ctx *ParseContext, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: task unset <filepath> <linenum>")
-	}
-	filePath := args[0]
-	lineNum, err := strconv.Atoi(args[1])
+	filePath, lineNum, err := taskLocation(ctx, args, "task unset <filepath> <linenum|^id>")
 	if err != nil {
-		return fmt.Errorf("bad line number: %w", err)
+		return err
 	}
 
 	// Read the line to determine which kind of marker to remove
@@ -509,7 +585,7 @@ func cmdUnset(ctx *ParseContext, args []string) error {
 	openCb := ctx.checkbox["open"]
 
 	if strings.Contains(line, ctx.markerPrefix+"irrelevant") {
-		if err := RemoveLastMarker(filePath, lineNum, "irrelevant", ctx.formats); err != nil {
+		if err := RemoveLastMarkerWith(filePath, lineNum, ctx.markerPrefix, "irrelevant", ctx.formats); err != nil {
 			return err
 		}
 		return ChangeCheckbox(filePath, lineNum, ctx.checkbox["irrelevant"], openCb)
@@ -518,38 +594,70 @@ func cmdUnset(ctx *ParseContext, args []string) error {
 	return nil
 }
 
-// cmdCheck quick check-off: changes [ ] to [x] without markers.
+// cmdCheck quick check-off: changes [ ] to [x] without markers. With
+// --cascade the open subtasks are checked off too.
 func cmdCheck(ctx *ParseContext, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: task check <filepath> <linenum>")
-	}
-	filePath := args[0]
-	lineNum, err := strconv.Atoi(args[1])
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	cascade := fs.Bool("cascade", false, "also check off the open subtasks")
+	filePath, lineNum, err := taskLocationWith(ctx, fs, args, "task check <filepath> <linenum|^id> [--cascade]")
 	if err != nil {
-		return fmt.Errorf("bad line number: %w", err)
+		return err
 	}
 
-	return CheckOffTaskWith(filePath, lineNum, ctx.checkbox["open"], ctx.checkbox["done"])
+	recurring, line, err := recurringTaskAt(ctx, filePath, lineNum)
+	if err != nil {
+		return err
+	}
+	if err := CheckOffTaskWith(filePath, lineNum, ctx.checkbox["open"], ctx.checkbox["done"]); err != nil {
+		return err
+	}
+	if err := completeSubtasks(ctx, filePath, lineNum, "", *cascade); err != nil {
+		return err
+	}
+	return insertNextOccurrence(ctx, recurring, line, time.Now().In(time.Local))
 }
 
-// cmdCompleteAt completes a specific task by filepath/line (not the "current" running task).
+// cmdCompleteAt completes a specific task by filepath/line (not the "current"
+// running task). With --cascade its open subtasks are completed as well.
 func cmdCompleteAt(ctx *ParseContext, args []string) error {
-	if len(args) < 2 {
-		return fmt.Errorf("usage: task complete-at <filepath> <linenum>")
-	}
-	filePath := args[0]
-	lineNum, err := strconv.Atoi(args[1])
+	fs := flag.NewFlagSet("complete-at", flag.ContinueOnError)
+	cascade := fs.Bool("cascade", false, "also complete the open subtasks")
+	atStr := fs.String("at", "", "when it was completed: HH:MM, -15m or a date and time")
+	filePath, lineNum, err := taskLocationWith(ctx, fs, args, "task complete-at <filepath> <linenum|^id> [--cascade] [--at TIME]")
 	if err != nil {
-		return fmt.Errorf("bad line number: %w", err)
+		return err
 	}
 
-	now := time.Now().In(time.Local)
-	marker := FormatMarker("complete", now, ctx.formats)
+	at := time.Now().In(time.Local)
+	if *atStr != "" {
+		if at, err = parseAtTime(*atStr, at, ctx); err != nil {
+			return err
+		}
+		task, err := readTaskAt(ctx, filePath, lineNum)
+		if err != nil {
+			return err
+		}
+		if err := checkMarkerOrder(task, "complete", at, ctx); err != nil {
+			return err
+		}
+	}
+
+	recurring, line, err := recurringTaskAt(ctx, filePath, lineNum)
+	if err != nil {
+		return err
+	}
+	marker := FormatMarker("complete", at, ctx)
 
 	if err := AppendToLine(filePath, lineNum, marker); err != nil {
 		return err
 	}
-	return CheckOffTaskWith(filePath, lineNum, ctx.checkbox["open"], ctx.checkbox["done"])
+	if err := CheckOffTaskWith(filePath, lineNum, ctx.checkbox["open"], ctx.checkbox["done"]); err != nil {
+		return err
+	}
+	if err := completeSubtasks(ctx, filePath, lineNum, marker, *cascade); err != nil {
+		return err
+	}
+	return insertNextOccurrence(ctx, recurring, line, at)
 }
 
 // cmdCreate creates a new task line in a file.
@@ -661,35 +769,57 @@ func main() {
 	case "list":
 		err = cmdList(notesPaths, ctx, subArgs, cfg)
 	case "do", "start":
-		err = cmdDo(notesPaths, ctx, cfg)
+		err = cmdDo(notesPaths, ctx, subArgs, cfg)
+	case "start-at":
+		if subArgs, err = expandIDArgs(notesPaths, ctx, cfg, subArgs); err == nil {
+			err = cmdStartAt(ctx, cfg, subArgs)
+		}
 	case "stop", "pause":
-		err = cmdStopWithConfig(cfg)
+		err = cmdStopWithConfig(ctx, cfg, subArgs)
 	case "complete", "done":
-		err = cmdCompleteWithConfig(cfg)
+		err = cmdCompleteWithConfig(ctx, cfg, subArgs)
+	case "resume":
+		err = cmdResume(ctx, cfg, subArgs)
+	case "recent":
+		err = cmdRecent(ctx, subArgs, cfg)
 	case "current":
-		err = cmdCurrent(cfg)
+		err = cmdCurrent(ctx, subArgs, cfg)
 	case "tags":
-		err = cmdTags(notesPaths, ctx, cfg)
-	case "defer":
-		err = cmdDefer(ctx, subArgs)
-	case "irrelevant":
-		err = cmdIrrelevant(ctx, subArgs)
-	case "unset":
-		err = cmdUnset(ctx, subArgs)
-	case "check":
-		err = cmdCheck(ctx, subArgs)
-	case "complete-at":
-		err = cmdCompleteAt(ctx, subArgs)
+		err = cmdTags(notesPaths, ctx, subArgs, cfg)
+	case "defer", "irrelevant", "unset", "check", "complete-at", "edit":
+		mutate := map[string]func(*ParseContext, []string) error{
+			"defer":       cmdDefer,
+			"edit":        cmdEdit,
+			"irrelevant":  cmdIrrelevant,
+			"unset":       cmdUnset,
+			"check":       cmdCheck,
+			"complete-at": cmdCompleteAt,
+		}[cmd]
+		if subArgs, err = expandIDArgs(notesPaths, ctx, cfg, subArgs); err == nil {
+			err = mutate(ctx, subArgs)
+		}
+	case "rollover":
+		err = cmdRollover(notesPaths, ctx, subArgs, cfg)
+	case "report":
+		err = cmdReport(notesPaths, ctx, subArgs, cfg)
 	case "create":
 		err = cmdCreate(ctx, subArgs)
+	case "id":
+		err = cmdID(notesPaths, ctx, cfg, subArgs)
+	case "index":
+		err = cmdIndex(notesPaths, ctx, cfg, subArgs)
+	case "serve":
+		err = cmdServe(notesPaths, ctx, cfg)
+	case "watch":
+		err = cmdWatch(notesPaths, ctx, subArgs, cfg)
 	default:
 		fmt.Fprintf(os.Stderr, "unknown command: %s\n", cmd)
-		fmt.Fprintf(os.Stderr, "usage: task [list|do|stop|complete|current|tags|defer|irrelevant|unset|check|complete-at|create]\n")
+		fmt.Fprintf(os.Stderr, "usage: task [list|do|start-at|stop|complete|resume|recent|current|tags|defer|irrelevant|unset|check|complete-at|edit|rollover|report|create|id|index|serve|watch]\n")
 		os.Exit(1)
 	}
 
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "error: %v\n", err)
-		os.Exit(1)
+		os.Exit(exitCode(err))
 	}
 }
@@ -2,24 +2,37 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 	"strings"
 )
 
 // AppendToLine appends text to the end of a specific line in a file.
 func AppendToLine(filePath string, lineNumber int, text string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
-	lines := strings.Split(string(data), "\n")
-	idx := lineNumber - 1
-	if idx < 0 || idx >= len(lines) {
-		return fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
-	}
-	lines[idx] = strings.TrimRight(lines[idx], " \t") + " " + text
-	return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644)
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNumber - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
+		}
+		lines[idx] = appendToTaskLine(lines[idx], text)
+		return lines, nil
+	})
+}
+
+// AddBlockIDs appends a " ^id" block ID to each given line (keyed by line
+// number) that does not already carry one.
+func AddBlockIDs(filePath string, ids map[int]string) error {
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		for lineNumber, id := range ids {
+			idx := lineNumber - 1
+			if idx < 0 || idx >= len(lines) {
+				return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
+			}
+			if parseBlockID(lines[idx]) == "" {
+				lines[idx] = strings.TrimRight(lines[idx], " \t") + " ^" + id
+			}
+		}
+		return lines, nil
+	})
 }
 
 // CheckOffTask changes `- [ ]` to `- [x]` on a specific line (uses default checkboxes).
@@ -40,102 +53,109 @@ func ChangeCheckbox(filePath string, lineNumber int, from, to string) error {
 	if to == "" {
 		return fmt.Errorf("ChangeCheckbox: empty 'to' checkbox string")
 	}
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
-	lines := strings.Split(string(data), "\n")
-	idx := lineNumber - 1
-	if idx < 0 || idx >= len(lines) {
-		return fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
-	}
-	lines[idx] = strings.Replace(lines[idx], from, to, 1)
-	return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644)
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNumber - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
+		}
+		lines[idx] = strings.Replace(lines[idx], from, to, 1)
+		return lines, nil
+	})
 }
 
 // RemoveLastMarker removes the last occurrence of a ::kind marker from a line.
 func RemoveLastMarker(filePath string, lineNumber int, kind string, fmts DateTimeFormats) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
-	lines := strings.Split(string(data), "\n")
-	idx := lineNumber - 1
-	if idx < 0 || idx >= len(lines) {
-		return fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
-	}
+	return RemoveLastMarkerWith(filePath, lineNumber, "::", kind, fmts)
+}
 
+// RemoveLastMarkerWith removes the last occurrence of a marker with the given
+// prefix and kind from a line.
+func RemoveLastMarkerWith(filePath string, lineNumber int, prefix, kind string, fmts DateTimeFormats) error {
 	// Match ::kind [[DATE]] TIME (time is optional)
-	pattern := fmt.Sprintf(`\s*::%s\s+\[\[%s\]\]\s*(%s)?`, regexp.QuoteMeta(kind), fmts.DateRe, fmts.TimeRe)
+	pattern := fmt.Sprintf(`\s*%s%s\s+\[\[%s\]\]\s*(%s)?`, regexp.QuoteMeta(prefix), regexp.QuoteMeta(kind), fmts.DateRe, fmts.TimeRe)
 	re := regexp.MustCompile(pattern)
 
-	line := lines[idx]
-	locs := re.FindAllStringIndex(line, -1)
-	if len(locs) == 0 {
-		return nil // no marker to remove
-	}
-	// Remove the last match
-	last := locs[len(locs)-1]
-	lines[idx] = line[:last[0]] + line[last[1]:]
-	lines[idx] = strings.TrimRight(lines[idx], " \t")
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNumber - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
+		}
+		line := lines[idx]
+		locs := re.FindAllStringIndex(line, -1)
+		if len(locs) == 0 {
+			return lines, nil // no marker to remove
+		}
+		// Remove the last match
+		last := locs[len(locs)-1]
+		lines[idx] = line[:last[0]] + line[last[1]:]
+		lines[idx] = strings.TrimRight(lines[idx], " \t")
+		return lines, nil
+	})
+}
 
-	return os.WriteFile(filePath, []byte(strings.Join(lines, "\n")), 0644)
+// InsertLineAfter inserts a new line of text directly below a specific line.
+func InsertLineAfter(filePath string, lineNumber int, text string) error {
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		idx := lineNumber - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", lineNumber, len(lines))
+		}
+		result := make([]string, 0, len(lines)+1)
+		result = append(result, lines[:idx+1]...)
+		result = append(result, text)
+		result = append(result, lines[idx+1:]...)
+		return result, nil
+	})
 }
 
 // InsertAfterHeader finds a markdown header line and inserts text on the next line.
 // If the header is not found, the text is appended to the end of the file.
 func InsertAfterHeader(filePath, header, text string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return os.WriteFile(filePath, []byte(header+"\n"+text+"\n"), 0644)
+	return rewriteFile(filePath, true, func(data []byte) ([]byte, error) {
+		if data == nil {
+			return []byte(header + "\n" + text + "\n"), nil
 		}
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
 
-	lines := strings.Split(string(data), "\n")
-	headerIdx := -1
-	for i, line := range lines {
-		if strings.TrimSpace(line) == strings.TrimSpace(header) {
-			headerIdx = i
-			break
+		lines := strings.Split(string(data), "\n")
+		headerIdx := -1
+		for i, line := range lines {
+			if strings.TrimSpace(line) == strings.TrimSpace(header) {
+				headerIdx = i
+				break
+			}
 		}
-	}
 
-	if headerIdx == -1 {
-		// Header not found, append header + text
-		content := string(data)
-		if !strings.HasSuffix(content, "\n") {
-			content += "\n"
+		if headerIdx == -1 {
+			// Header not found, append header + text
+			content := string(data)
+			if !strings.HasSuffix(content, "\n") {
+				content += "\n"
+			}
+			content += "\n" + header + "\n" + text + "\n"
+			return []byte(content), nil
 		}
-		content += "\n" + header + "\n" + text + "\n"
-		return os.WriteFile(filePath, []byte(content), 0644)
-	}
-
-	// Insert after header (and after any existing tasks below the header)
-	insertIdx := headerIdx + 1
-	result := make([]string, 0, len(lines)+1)
-	result = append(result, lines[:insertIdx]...)
-	result = append(result, text)
-	result = append(result, lines[insertIdx:]...)
 
-	return os.WriteFile(filePath, []byte(strings.Join(result, "\n")), 0644)
+		// Insert after header (and after any existing tasks below the header)
+		insertIdx := headerIdx + 1
+		result := make([]string, 0, len(lines)+1)
+		result = append(result, lines[:insertIdx]...)
+		result = append(result, text)
+		result = append(result, lines[insertIdx:]...)
+		return []byte(strings.Join(result, "\n")), nil
+	})
 }
 
 // AppendToFile appends a line of text to the end of a file, creating it if needed.
 func AppendToFile(filePath, text string) error {
-	data, err := os.ReadFile(filePath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return os.WriteFile(filePath, []byte(text+"\n"), 0644)
+	return rewriteFile(filePath, true, func(data []byte) ([]byte, error) {
+		if data == nil {
+			return []byte(text + "\n"), nil
 		}
-		return fmt.Errorf("reading %s: %w", filePath, err)
-	}
-
-	content := string(data)
-	if !strings.HasSuffix(content, "\n") {
-		content += "\n"
-	}
-	content += text + "\n"
-	return os.WriteFile(filePath, []byte(content), 0644)
+		content := string(data)
+		if !strings.HasSuffix(content, "\n") {
+			content += "\n"
+		}
+		content += text + "\n"
+		return []byte(content), nil
+	})
 }
@@ -202,6 +202,13 @@ func TestCmdIrrelevant(t *testing.T) {
 	if !strings.Contains(line, "::irrelevant") {
 		t.Errorf("should have irrelevant marker, got %q", line)
 	}
+
+	if err := cmdIrrelevant(DefaultParseContext(), []string{path, "3"}); err == nil {
+		t.Error("line out of range should fail")
+	}
+	if data2, _ := os.ReadFile(path); string(data2) != string(data) {
+		t.Errorf("failed call modified the file: %q", data2)
+	}
 }
 
 func TestCmdUnset_Irrelevant(t *testing.T) {
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// continuationLines returns the notes of the task on lines[idx]: the lines
+// below it that are indented further, up to the next task line or the first
+// non-blank line that is not. Blank lines between notes are kept as "", and
+// the indent the notes share is removed. kinds is from blockContexts; a
+// checkbox in a skipped block does not end the notes.
+func continuationLines(ctx *ParseContext, lines, kinds []string, idx int) []string {
+	indent := indentWidth(lines[idx])
+	var notes []string
+	for i := idx + 1; i < len(lines); i++ {
+		line := strings.TrimRight(lines[i], "\r\n")
+		if strings.TrimSpace(line) == "" {
+			notes = append(notes, "")
+			continue
+		}
+		if indentWidth(line) <= indent {
+			break
+		}
+		if ctx.statusRe.MatchString(line) && (i >= len(kinds) || !ctx.skipBlocks[kinds[i]]) {
+			break
+		}
+		notes = append(notes, line)
+	}
+	for len(notes) > 0 && notes[len(notes)-1] == "" {
+		notes = notes[:len(notes)-1]
+	}
+	if len(notes) == 0 {
+		return nil
+	}
+	return trimCommonIndent(notes)
+}
+
+// trimCommonIndent removes the leading whitespace that all non-blank lines
+// share.
+func trimCommonIndent(lines []string) []string {
+	prefix, first := "", true
+	for _, l := range lines {
+		if l == "" {
+			continue
+		}
+		lead := l[:len(l)-len(strings.TrimLeft(l, " \t"))]
+		if first {
+			prefix, first = lead, false
+			continue
+		}
+		n := 0
+		for n < len(prefix) && n < len(lead) && prefix[n] == lead[n] {
+			n++
+		}
+		prefix = prefix[:n]
+	}
+	for i, l := range lines {
+		lines[i] = strings.TrimPrefix(l, prefix)
+	}
+	return lines
+}
+
+// annotateMatches is the context-aware pass over one file's matches: it drops
+// the ones that sit in a block kind ctx skips and attaches each remaining
+// task's continuation lines as its Notes.
+func annotateMatches(ctx *ParseContext, filePath string, data []byte, matches []RawMatch) []RawMatch {
+	if len(matches) == 0 {
+		return matches
+	}
+	lines := strings.Split(string(data), "\n")
+	kinds, open := blockContexts(lines)
+	if open > 0 && ctx.skipBlocks[blockFenced] && Verbose {
+		fmt.Fprintf(os.Stderr, "taskbuffer: %s:%d: code fence is never closed; checkboxes below it are skipped\n", filePath, open)
+	}
+	var kept []RawMatch
+	for _, m := range matches {
+		i := m.LineNumber - 1
+		if i < 0 || i >= len(lines) {
+			kept = append(kept, m)
+			continue
+		}
+		if ctx.skipBlocks[kinds[i]] {
+			continue
+		}
+		m.Notes = continuationLines(ctx, lines, kinds, i)
+		kept = append(kept, m)
+	}
+	return kept
+}
+
+// AnnotateMatches runs annotateMatches after Scan, reading each file with
+// matches once. Files that cannot be read keep their matches as they are.
+func AnnotateMatches(ctx *ParseContext, matches []RawMatch) []RawMatch {
+	var out []RawMatch
+	for start := 0; start < len(matches); {
+		end := start + 1
+		for end < len(matches) && matches[end].Path == matches[start].Path {
+			end++
+		}
+		group := matches[start:end]
+		if data, err := os.ReadFile(group[0].Path); err == nil {
+			group = annotateMatches(ctx, group[0].Path, data, group)
+		}
+		out = append(out, group...)
+		start = end
+	}
+	return out
+}
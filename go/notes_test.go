@@ -0,0 +1,138 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const notesDoc = `# Trip
+- [ ] Book flights (@[[2026-02-17]])
+  Compare https://example.com/fares
+
+  - window seat
+    - not over the wing
+  ` + "```" + `
+  - [ ] not a task
+  ` + "```" + `
+  - [ ] Pick airline
+    Only direct flights
+- [ ] Pack
+Paragraph below the list
+- [ ] Call mom
+  - [x] Done subtask
+`
+
+func TestScan_Notes(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"trip.md": notesDoc})
+
+	want := map[string][]string{
+		"Book flights": {
+			"Compare https://example.com/fares",
+			"",
+			"- window seat",
+			"  - not over the wing",
+			"```",
+			"- [ ] not a task",
+			"```",
+		},
+		"Pick airline": {"Only direct flights"},
+		"Pack":         nil,
+		"Call mom":     nil,
+		"Done subtask": nil,
+	}
+	for _, cfg := range []Config{{}, {Index: true, StateDir: t.TempDir()}} {
+		cfg.ScanBackend = "go"
+		tasks, _, err := scanVault([]string{dir}, NewParseContext(cfg), cfg, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tasks) != len(want) {
+			t.Fatalf("index=%v: got %d tasks, want %d", cfg.Index, len(tasks), len(want))
+		}
+		for _, task := range tasks {
+			if !reflect.DeepEqual(task.Notes, want[task.Body]) {
+				t.Errorf("index=%v: %s notes = %q, want %q", cfg.Index, task.Body, task.Notes, want[task.Body])
+			}
+		}
+	}
+}
+
+func TestNotes_OutputAndQuery(t *testing.T) {
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Book flights", Status: "open",
+			Notes: []string{"Compare fares", "", "- window seat"}},
+		{FilePath: "/a.md", LineNumber: 5, Body: "Pack", Status: "open"},
+	}
+
+	q, err := ParseQuery(`fares`, defaultCtx, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Filter(tasks); len(got) != 1 || got[0].Body != "Book flights" {
+		t.Errorf("body filter should search notes, got %v", got)
+	}
+	q, _ = ParseQuery(`body:/^- window/`, defaultCtx, testNow)
+	if got := q.Filter(tasks); len(got) != 1 {
+		t.Errorf("regex filter should search notes, got %v", got)
+	}
+	q, _ = ParseQuery(`-has:notes`, defaultCtx, testNow)
+	if got := q.Filter(tasks); len(got) != 1 || got[0].Body != "Pack" {
+		t.Errorf("-has:notes got %v", got)
+	}
+
+	got := FormatTaskfile(tasks, testNow, markersOpts)
+	pad := "\t" + strings.Repeat(" ", 10) + "\t" + strings.Repeat(" ", 16) + "\t   "
+	want := "# Someday\n" +
+		"/a.md:1:1:\t          \t |       |     |\t Book flights \t\n" +
+		"/a.md:1:1:" + pad + "Compare fares\n" +
+		"/a.md:1:1:" + pad + "- window seat\n" +
+		"/a.md:5:1:\t          \t |       |     |\t Pack \t\n"
+	if got != want {
+		t.Errorf("taskfile with markers:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if got := FormatTaskfile(tasks, testNow, defaultOpts); strings.Contains(got, "fares") {
+		t.Errorf("notes shown without markers:\n%s", got)
+	}
+
+	out, err := FormatJSON(tasks, testNow, defaultOpts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(out)
+	if !strings.Contains(lines[0], `"notes":["Compare fares","","- window seat"]`) || strings.Contains(lines[1], "notes") {
+		t.Errorf("JSON notes:\n%s", out)
+	}
+}
+
+func TestMutations_LeaveNotesAlone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "trip.md")
+	os.WriteFile(path, []byte(notesDoc), 0644)
+	notes := splitLines(notesDoc)[2:9]
+
+	for _, args := range [][]string{
+		{"edit", path, "2", "--due", "2026-03-01", "--add-tag", "travel"},
+		{"defer", path, "2"},
+		{"complete-at", path, "2"},
+	} {
+		var err error
+		switch args[0] {
+		case "edit":
+			err = cmdEdit(defaultCtx, args[1:])
+		case "defer":
+			err = cmdDefer(defaultCtx, args[1:])
+		case "complete-at":
+			err = cmdCompleteAt(defaultCtx, args[1:])
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", args[0], err)
+		}
+		lines := splitLines(readFile(t, path))
+		if !reflect.DeepEqual(lines[2:9], notes) {
+			t.Errorf("%s changed the notes:\n%s", args[0], strings.Join(lines[2:9], "\n"))
+		}
+	}
+}
@@ -3,28 +3,40 @@ package main
 import (
 	"fmt"
 	"log"
+	"os"
 	"regexp"
 	"strings"
 	"time"
 )
 
 type Task struct {
-	FilePath   string
-	LineNumber int
-	Body       string
-	DueDate    *time.Time // nil means undated
-	DueTime    string     // "" or "HH:MM"
-	Duration   string     // "" or "30m", "90m", etc.
-	Tags       []string
-	Status     string // "open", "done", "irrelevant"
-	Markers    []Marker
-	SortLast   bool // synthetic tasks (projects) sort after real tasks
+	FilePath      string
+	LineNumber    int
+	Body          string
+	DueDate       *time.Time // nil means undated
+	DueFromParent bool       // DueDate is the parent task's (subtasks.inherit_due), not on the line
+	DueTime       string     // "" or "HH:MM"
+	Duration      string     // "" or "30m", "90m", etc.
+	Tags          []string
+	Status        string // "open", "done", "irrelevant"
+	Markers       []Marker
+	SortLast      bool     // synthetic tasks (projects) sort after real tasks
+	Recurrence    string   // "" or canonical spec: "1w", "3d", "1m", "weekday", "monday", ...
+	ID            string   // block ID without the caret ("tb-7f3a"), or ""
+	Priority      int      // 0 (none), priorityHigh, priorityMedium or priorityLow
+	Notes         []string // indented continuation lines below the task line
+
+	Indent       int   // leading whitespace width, tabs counting to the next multiple of 4
+	Parent       int   // line number of the parent task in the same file, 0 at the top level
+	Children     []int // line numbers of the direct subtasks
+	ChildrenDone int   // direct subtasks that are no longer open
+	Depth        int   // nesting under the parent listed above it; set by GroupTasks
 }
 
 type Marker struct {
-	Kind string // "start", "stop", "complete", "deferral", "original", "irrelevant"
-	Date string // "YYYY-MM-DD"
-	Time string // "HH:MM" or ""
+	Kind string `json:"kind"`           // "start", "stop", "complete", "deferral", "original", "irrelevant"
+	Date string `json:"date"`           // "YYYY-MM-DD"
+	Time string `json:"time,omitempty"` // "HH:MM" or ""
 }
 
 // ParseContext holds compiled regexes built from Config for config-driven parsing.
@@ -36,11 +48,21 @@ type ParseContext struct {
 	markerRe      *regexp.Regexp    // markers with configured prefix
 	markerStartRe *regexp.Regexp    // matches marker prefix + keyword + [[, for finding marker boundaries
 	durationRe    *regexp.Regexp    // unchanged: <Nm>
+	priorityRe    *regexp.Regexp    // priority token in any enabled syntax (group 1), or nil
+	prioritySyn   string            // syntax for priorities added to a line
+	recurMarkerRe *regexp.Regexp    // marker prefix + every [[spec]]
+	datedMarkerRe *regexp.Regexp    // a whole marker with its date and optional time, for stripping
 	markerPrefix  string            // for splitting marker segments
 	tagPrefix     string            // for output formatting
 	scanPattern   string            // rg pattern for scanning
+	scanBackend   string            // "auto", "rg" or "go" (see resolveScanBackend)
 	checkbox      map[string]string // status_name -> checkbox string (for mutations)
 	formats       DateTimeFormats   // resolved date/time formats
+	dateWrap      [3]string         // open, close-before-time, close-after-time (for writing dates)
+	recurFrom     string            // "due" or "completion": base date for the next occurrence
+	skipBlocks    map[string]bool   // markdown block kinds whose checkboxes are not tasks
+	weekStart     time.Weekday      // first day of the week, for end_of_week and friends
+	frontmatter   FrontmatterConfig // frontmatter keys, for mutations that fall back to the due key
 	strict        bool              // when true, collect date errors instead of skipping
 	dateErrors    *[]DateError      // collector for date validation errors (nil = ignore)
 }
@@ -49,8 +71,37 @@ type ParseContext struct {
 // for zero-valued fields.
 func NewParseContext(cfg Config) *ParseContext {
 	ctx := &ParseContext{
-		durationRe: regexp.MustCompile(`<(\d+)m>`),
-		strict:     cfg.Strict,
+		durationRe:  regexp.MustCompile(`<(\d+)m>`),
+		strict:      cfg.Strict,
+		scanBackend: cfg.ScanBackend,
+		recurFrom:   cfg.RecurFrom,
+		weekStart:   parseWeekday(cfg.WeekStart),
+		frontmatter: cfg.Frontmatter,
+	}
+	if ctx.recurFrom == "" {
+		ctx.recurFrom = recurFromDue
+	}
+
+	// Markdown blocks whose checkboxes are skipped
+	skipBlocks := cfg.SkipBlocks
+	if skipBlocks == nil {
+		skipBlocks = allBlockKinds
+	}
+	ctx.skipBlocks = make(map[string]bool)
+	for _, kind := range skipBlocks {
+		switch kind {
+		case blockFenced, blockIndented, blockComment:
+			ctx.skipBlocks[kind] = true
+		case "none":
+		default:
+			fmt.Fprintf(os.Stderr, "taskbuffer: warning: unknown skip_blocks kind %q (want fenced, indented, comment or none)\n", kind)
+		}
+	}
+
+	// Priority syntaxes
+	if syntaxes := resolvePrioritySyntax(cfg.PrioritySyntax); len(syntaxes) > 0 {
+		ctx.priorityRe = buildPriorityRe(syntaxes)
+		ctx.prioritySyn = syntaxes[0]
 	}
 
 	// Checkbox config
@@ -131,10 +182,13 @@ func NewParseContext(cfg Config) *ParseContext {
 	// Date wrapper
 	dateOpen := `\(@\[\[`
 	dateClose := `\]\]\s*(` + ctx.formats.TimeRe + `)?\)`
+	ctx.dateWrap = [3]string{"(@[[", "]]", ")"}
 	if len(cfg.DateWrapper) == 3 && cfg.DateWrapper[0] != "" && cfg.DateWrapper[1] != "" && cfg.DateWrapper[2] != "" {
+		ctx.dateWrap = [3]string{cfg.DateWrapper[0], cfg.DateWrapper[1], cfg.DateWrapper[2]}
 		dateOpen = regexp.QuoteMeta(cfg.DateWrapper[0])
 		dateClose = regexp.QuoteMeta(cfg.DateWrapper[1]) + `\s*(` + ctx.formats.TimeRe + `)?` + regexp.QuoteMeta(cfg.DateWrapper[2])
 	} else if len(cfg.DateWrapper) == 2 && cfg.DateWrapper[0] != "" && cfg.DateWrapper[1] != "" {
+		ctx.dateWrap = [3]string{cfg.DateWrapper[0], "", cfg.DateWrapper[1]}
 		dateOpen = regexp.QuoteMeta(cfg.DateWrapper[0])
 		dateClose = regexp.QuoteMeta(cfg.DateWrapper[1])
 		// Insert the time capture group before the closing wrapper
@@ -152,6 +206,8 @@ func NewParseContext(cfg Config) *ParseContext {
 	// markerStartRe matches the marker prefix followed by a keyword and [[ — used to find
 	// where markers begin in a line (avoids false positives from prefix appearing in body text).
 	ctx.markerStartRe = regexp.MustCompile(markerPrefixEscaped + `\s*\w+\s+\[\[`)
+	ctx.datedMarkerRe = regexp.MustCompile(`\s*` + markerPrefixEscaped + `\s*` + ctx.markerRe.String())
+	ctx.recurMarkerRe = regexp.MustCompile(`\s*` + markerPrefixEscaped + `\s*every\s+\[\[([^\]]+)\]\]`)
 
 	return ctx
 }
@@ -234,6 +290,14 @@ func ParseTask(match RawMatch, ctx *ParseContext) (Task, error) {
 		duration = durMatch[1] + "m"
 	}
 
+	// 3b. Extract priority
+	priority := 0
+	if ctx.priorityRe != nil {
+		if m := ctx.priorityRe.FindStringSubmatch(line); m != nil {
+			priority = priorityLevel(m[1])
+		}
+	}
+
 	// 4. Extract markers — split on marker prefix and parse each segment
 	var markers []Marker
 	afterDateGroup := ""
@@ -298,10 +362,25 @@ func ParseTask(match RawMatch, ctx *ParseContext) (Task, error) {
 	if durMatch != nil {
 		bodyPart = strings.Replace(bodyPart, "<"+durMatch[1]+"m>", "", 1)
 	}
+	// Remove priority from body
+	if ctx.priorityRe != nil {
+		if loc := ctx.priorityRe.FindStringSubmatchIndex(bodyPart); loc != nil {
+			bodyPart = strings.TrimRight(bodyPart[:loc[2]], " \t") + " " + strings.TrimLeft(bodyPart[loc[3]:], " \t")
+		}
+	}
 	// Remove tags from body
 	bodyPart = ctx.tagRe.ReplaceAllString(bodyPart, "")
 	body := strings.TrimSpace(bodyPart)
 
+	// 7. Extract recurrence (::every [[1w]] marker or "every monday" token)
+	recurrence, body := extractRecurrence(line, body, ctx)
+
+	// 8. Extract block ID (^tb-7f3a)
+	id := parseBlockID(line)
+	if id != "" {
+		body = stripBlockID(body)
+	}
+
 	return Task{
 		FilePath:   match.Path,
 		LineNumber: match.LineNumber,
@@ -312,9 +391,16 @@ func ParseTask(match RawMatch, ctx *ParseContext) (Task, error) {
 		Tags:       tags,
 		Status:     status,
 		Markers:    markers,
+		Recurrence: recurrence,
+		ID:         id,
+		Priority:   priority,
+		Indent:     indentWidth(match.Text),
+		Notes:      match.Notes,
 	}, nil
 }
 
+// ParseTasks parses each match, skipping lines that are not valid tasks, and
+// links subtasks to their parents (see linkSubtasks).
 func ParseTasks(matches []RawMatch, ctx *ParseContext) []Task {
 	var tasks []Task
 	for _, m := range matches {
@@ -327,5 +413,6 @@ func ParseTasks(matches []RawMatch, ctx *ParseContext) []Task {
 		}
 		tasks = append(tasks, t)
 	}
+	linkSubtasks(tasks)
 	return tasks
 }
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// Syntaxes a task's priority can be written in. Config.PrioritySyntax lists
+// the ones recognized; the first is used when a priority is added to a line.
+const (
+	prioritySyntaxBang   = "bang"   // !!!, !! or ! as a word of its own
+	prioritySyntaxLetter = "letter" // (A), (B) or (C), as in todo.txt
+	prioritySyntaxField  = "field"  // priority:: high, as a Dataview inline field
+)
+
+// allPrioritySyntaxes is the default for Config.PrioritySyntax.
+var allPrioritySyntaxes = []string{prioritySyntaxBang, prioritySyntaxLetter, prioritySyntaxField}
+
+// Priority levels, as stored in Task.Priority. Zero means no priority; lower
+// numbers are more urgent.
+const (
+	priorityHigh   = 1
+	priorityMedium = 2
+	priorityLow    = 3
+)
+
+var priorityNames = []string{"", "high", "medium", "low"}
+
+var prioritySyntaxPatterns = map[string]string{
+	prioritySyntaxBang:   `!{1,3}`,
+	prioritySyntaxLetter: `\([ABC]\)`,
+	prioritySyntaxField:  `(?i:priority)::[ \t]*(?i:high|medium|low)`,
+}
+
+// buildPriorityRe compiles the enabled syntaxes into one pattern whose first
+// group is the priority token; the token must stand as a word of its own.
+// It returns nil when no syntax is enabled.
+func buildPriorityRe(syntaxes []string) *regexp.Regexp {
+	var alts []string
+	for _, s := range syntaxes {
+		alts = append(alts, prioritySyntaxPatterns[s])
+	}
+	if len(alts) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?:^|\s)(` + strings.Join(alts, "|") + `)(?:\s|$)`)
+}
+
+// resolvePrioritySyntax validates Config.PrioritySyntax: nil means all
+// syntaxes, "none" disables priorities, and unknown names are warned about.
+func resolvePrioritySyntax(names []string) []string {
+	if names == nil {
+		return allPrioritySyntaxes
+	}
+	var out []string
+	for _, name := range names {
+		switch {
+		case prioritySyntaxPatterns[name] != "":
+			out = append(out, name)
+		case name == "none":
+		default:
+			fmt.Fprintf(os.Stderr, "taskbuffer: warning: unknown priority_syntax %q (want bang, letter, field or none)\n", name)
+		}
+	}
+	return out
+}
+
+// priorityLevel returns the level a priority token stands for.
+func priorityLevel(token string) int {
+	switch strings.ToLower(strings.Join(strings.Fields(token), "")) {
+	case "!!!", "(a)", "priority::high":
+		return priorityHigh
+	case "!!", "(b)", "priority::medium":
+		return priorityMedium
+	case "!", "(c)", "priority::low":
+		return priorityLow
+	}
+	return 0
+}
+
+// priorityToken writes level in the given syntax.
+func priorityToken(level int, syntax string) string {
+	switch syntax {
+	case prioritySyntaxLetter:
+		return "(" + string(rune('A'+level-1)) + ")"
+	case prioritySyntaxField:
+		return "priority:: " + priorityNames[level]
+	}
+	return strings.Repeat("!", 4-level)
+}
+
+// prioritySyntaxOf returns the syntax a priority token is written in.
+func prioritySyntaxOf(token string) string {
+	switch {
+	case strings.HasPrefix(token, "!"):
+		return prioritySyntaxBang
+	case strings.HasPrefix(token, "("):
+		return prioritySyntaxLetter
+	}
+	return prioritySyntaxField
+}
+
+// parsePriorityName accepts a level name ("high", "medium", "low"), its first
+// letter, or "none", which is 0.
+func parsePriorityName(s string) (int, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "none" {
+		return 0, nil
+	}
+	for level := priorityHigh; level <= priorityLow; level++ {
+		if s == priorityNames[level] || s == priorityNames[level][:1] {
+			return level, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid priority %q (want high, medium, low or none)", s)
+}
+
+// priorityRank orders tasks by priority, tasks without one last.
+func priorityRank(t Task) int {
+	if t.Priority == 0 {
+		return priorityLow + 1
+	}
+	return t.Priority
+}
+
+// priorityQuery builds the query equivalent of repeated --priority flags (OR
+// logic).
+func priorityQuery(names []string) (*Query, error) {
+	var root queryNode
+	for _, name := range names {
+		level, err := parsePriorityName(name)
+		if err != nil {
+			return nil, err
+		}
+		var n queryNode = priorityNode{"=", level}
+		if root == nil {
+			root = n
+		} else {
+			root = orNode{root, n}
+		}
+	}
+	if root == nil {
+		return nil, nil
+	}
+	return &Query{src: "priority:" + strings.Join(names, " OR priority:"), root: root}, nil
+}
+
+// priorityNode compares priorities with higher ones greater: priority>=medium
+// matches high and medium. Level 0 matches only tasks without a priority.
+type priorityNode struct {
+	op    string
+	level int
+}
+
+func (n priorityNode) match(t *Task) bool {
+	if n.level == 0 || t.Priority == 0 {
+		return n.op == "=" && t.Priority == n.level
+	}
+	return compare(n.op, n.level-t.Priority)
+}
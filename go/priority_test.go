@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseTask_Priority(t *testing.T) {
+	cases := []struct {
+		line     string
+		priority int
+		body     string
+	}{
+		{"- [ ] Fix leak !!! #work", priorityHigh, "Fix leak"},
+		{"- [ ] !! Fix leak", priorityMedium, "Fix leak"},
+		{"- [ ] Fix ! leak", priorityLow, "Fix leak"},
+		{"- [ ] (A) Call plumber (@[[2026-02-17]])", priorityHigh, "Call plumber"},
+		{"- [ ] Call plumber (C) <30m>", priorityLow, "Call plumber"},
+		{"- [ ] Call plumber priority:: medium", priorityMedium, "Call plumber"},
+		{"- [ ] Call plumber (@[[2026-02-17]]) Priority::High", priorityHigh, "Call plumber"},
+		{"- [ ] Wow!!! not a priority", 0, "Wow!!! not a priority"},
+		{"- [ ] !!!! is too many", 0, "!!!! is too many"},
+		{"- [ ] (D) is not a level", 0, "(D) is not a level"},
+		{"- [ ] (a) is lower case", 0, "(a) is lower case"},
+	}
+	for _, c := range cases {
+		task, err := ParseTask(RawMatch{Text: c.line}, defaultCtx)
+		if err != nil {
+			t.Fatalf("%q: %v", c.line, err)
+		}
+		if task.Priority != c.priority || task.Body != c.body {
+			t.Errorf("%q: priority %d body %q; want %d %q", c.line, task.Priority, task.Body, c.priority, c.body)
+		}
+	}
+
+	ctx := NewParseContext(Config{PrioritySyntax: []string{"letter"}})
+	task, _ := ParseTask(RawMatch{Text: "- [ ] Fix leak !!! (B)"}, ctx)
+	if task.Priority != priorityMedium || task.Body != "Fix leak !!!" {
+		t.Errorf("letter only: priority %d body %q", task.Priority, task.Body)
+	}
+	ctx = NewParseContext(Config{PrioritySyntax: []string{"none"}})
+	task, _ = ParseTask(RawMatch{Text: "- [ ] Fix leak !!!"}, ctx)
+	if task.Priority != 0 || task.Body != "Fix leak !!!" {
+		t.Errorf("none: priority %d body %q", task.Priority, task.Body)
+	}
+}
+
+func TestSerializeTask_Priority(t *testing.T) {
+	cases := []struct {
+		line     string
+		priority int
+		want     string
+	}{
+		{"- [ ] Fix leak #work", priorityHigh, "- [ ] Fix leak #work !!!"},
+		{"- [ ] Fix leak (@[[2026-02-17]])", priorityLow, "- [ ] Fix leak ! (@[[2026-02-17]])"},
+		{"- [ ] (A) Fix leak", priorityMedium, "- [ ] (B) Fix leak"},
+		{"- [ ] Fix leak priority:: high #work", priorityLow, "- [ ] Fix leak priority:: low #work"},
+		{"- [ ] Fix !! leak #work", 0, "- [ ] Fix leak #work"},
+	}
+	for _, c := range cases {
+		task, _ := ParseTask(RawMatch{Text: c.line}, defaultCtx)
+		task.Priority = c.priority
+		got, err := SerializeTask(c.line, task, defaultCtx)
+		if err != nil {
+			t.Errorf("%q: %v", c.line, err)
+		} else if got != c.want {
+			t.Errorf("%q: got %q, want %q", c.line, got, c.want)
+		}
+	}
+
+	// A new priority uses the first configured syntax
+	ctx := NewParseContext(Config{PrioritySyntax: []string{"field", "bang"}})
+	task, _ := ParseTask(RawMatch{Text: "- [ ] Fix leak"}, ctx)
+	task.Priority = priorityHigh
+	if got, _ := SerializeTask("- [ ] Fix leak", task, ctx); got != "- [ ] Fix leak priority:: high" {
+		t.Errorf("field syntax: got %q", got)
+	}
+
+	// Changing the body keeps the priority
+	task, _ = ParseTask(RawMatch{Text: "- [ ] (A) Fix leak #work"}, defaultCtx)
+	task.Body = "Fix the leak"
+	if got, _ := SerializeTask("- [ ] (A) Fix leak #work", task, defaultCtx); got != "- [ ] (A) Fix the leak #work" {
+		t.Errorf("body change: got %q", got)
+	}
+}
+
+func TestCmdEdit_Priority(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("- [ ] Fix leak #work\n"), 0644)
+
+	if err := cmdEdit(defaultCtx, []string{path, "1", "--priority", "high"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "- [ ] Fix leak #work !!!\n" {
+		t.Errorf("got %q", got)
+	}
+	if err := cmdEdit(defaultCtx, []string{path, "1", "--priority", "none"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "- [ ] Fix leak #work\n" {
+		t.Errorf("got %q", got)
+	}
+	if err := cmdEdit(defaultCtx, []string{path, "1", "--priority", "urgent"}); err == nil {
+		t.Error("want an error for an unknown priority")
+	}
+}
+
+func TestFormatTaskfile_Priority(t *testing.T) {
+	today := mustDatePtr("2026-02-17")
+	tasks := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Low", DueDate: today, Priority: priorityLow, Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "None", DueDate: today, Status: "open"},
+		{FilePath: "/a.md", LineNumber: 3, Body: "High", DueDate: today, Priority: priorityHigh, Status: "open"},
+		{FilePath: "/a.md", LineNumber: 4, Body: "Someday", Priority: priorityMedium, Status: "open"},
+	}
+
+	got := FormatTaskfile(tasks, testNow, defaultOpts)
+	want := "# Today\n" +
+		"/a.md:1:1:\t[[2026-02-17]]\t |       |     | !   |\t Low \t\n" +
+		"/a.md:2:1:\t[[2026-02-17]]\t |       |     |     |\t None \t\n" +
+		"/a.md:3:1:\t[[2026-02-17]]\t |       |     | !!! |\t High \t\n" +
+		"\n# Someday\n" +
+		"/a.md:4:1:\t          \t |       |     | !!  |\t Someday \t\n"
+	if got != want {
+		t.Errorf("date order:\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	got = FormatTaskfile(tasks, testNow, FormatOpts{Sort: "priority"})
+	if !strings.Contains(got, "High \t\n/a.md:1:1:\t[[2026-02-17]]\t |       |     | !   |\t Low \t\n/a.md:2:1:") {
+		t.Errorf("priority order:\n%s", got)
+	}
+
+	q, err := priorityQuery([]string{"high", "none"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := q.Filter(tasks); len(got) != 2 || got[0].Body != "None" || got[1].Body != "High" {
+		t.Errorf("--priority high --priority none: %v", got)
+	}
+	q, _ = ParseQuery("priority>=medium", defaultCtx, testNow)
+	if got := q.Filter(tasks); len(got) != 2 || got[0].Body != "High" || got[1].Body != "Someday" {
+		t.Errorf("priority>=medium: %v", got)
+	}
+	if _, err := ParseQuery("priority<none", defaultCtx, testNow); err == nil {
+		t.Error("priority<none should be an error")
+	}
+
+	// Without any priority the column is left out
+	if got := FormatTaskfile(tasks[1:2], testNow, defaultOpts); strings.Count(got, "|") != 3 {
+		t.Errorf("column shown without priorities:\n%s", got)
+	}
+
+	out, _ := FormatJSON(tasks, testNow, defaultOpts, true)
+	if lines := splitLines(out); !strings.Contains(lines[0], `"priority":"low"`) || strings.Contains(lines[1], "priority") {
+		t.Errorf("JSON priority:\n%s", out)
+	}
+}
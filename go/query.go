@@ -0,0 +1,814 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Query is a compiled --query filter expression. A nil *Query matches every
+// task.
+//
+// Grammar (keywords are case-insensitive, juxtaposition means AND):
+//
+//	expr    = and { ("OR" | "||") and }
+//	and     = unary { ["AND" | "&&"] unary }
+//	unary   = ("NOT" | "!" | "-") unary | "(" expr ")" | term
+//	term    = "#tag" | "/regex/" | "\"phrase\"" | word | field op value
+//	field   = tag | due | time | duration | priority | status | file | id | has | body
+//	op      = ":" | "=" | "!=" | "<" | "<=" | ">" | ">="
+type Query struct {
+	src  string
+	root queryNode
+}
+
+// Match reports whether t satisfies the query.
+func (q *Query) Match(t Task) bool {
+	if q == nil {
+		return true
+	}
+	return q.root.match(&t)
+}
+
+// String returns the source expression.
+func (q *Query) String() string {
+	if q == nil {
+		return ""
+	}
+	return q.src
+}
+
+// Filter returns the tasks matching q.
+func (q *Query) Filter(tasks []Task) []Task {
+	if q == nil {
+		return tasks
+	}
+	var out []Task
+	for _, t := range tasks {
+		if q.Match(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// QueryError reports a syntax or value error at a 1-based column of the query.
+type QueryError struct {
+	Query  string
+	Column int
+	Msg    string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query: %s at column %d\n  %s\n  %s^",
+		e.Msg, e.Column, e.Query, strings.Repeat(" ", e.Column-1))
+}
+
+type queryNode interface {
+	match(t *Task) bool
+}
+
+type andNode struct{ left, right queryNode }
+type orNode struct{ left, right queryNode }
+type notNode struct{ node queryNode }
+
+func (n andNode) match(t *Task) bool { return n.left.match(t) && n.right.match(t) }
+func (n orNode) match(t *Task) bool  { return n.left.match(t) || n.right.match(t) }
+func (n notNode) match(t *Task) bool { return !n.node.match(t) }
+
+// tagNode matches a tag exactly, or as a glob when the pattern contains
+// glob metacharacters.
+type tagNode struct{ pattern string }
+
+func (n tagNode) match(t *Task) bool {
+	glob := strings.ContainsAny(n.pattern, "*?[")
+	for _, tag := range t.Tags {
+		if tag == n.pattern {
+			return true
+		}
+		if glob {
+			if ok, _ := path.Match(n.pattern, tag); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// compare applies a comparison operator to the result of a three-way compare.
+func compare(op string, cmp int) bool {
+	switch op {
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return cmp == 0
+}
+
+type dueNode struct {
+	op   string
+	date time.Time
+}
+
+func (n dueNode) match(t *Task) bool {
+	if t.DueDate == nil {
+		return false
+	}
+	due := extractDate(*t.DueDate)
+	return compare(n.op, due.Compare(n.date))
+}
+
+type timeNode struct {
+	op      string
+	minutes int
+}
+
+func (n timeNode) match(t *Task) bool {
+	m, ok := clockMinutes(t.DueTime)
+	if !ok {
+		return false
+	}
+	return compare(n.op, m-n.minutes)
+}
+
+type durationNode struct {
+	op string
+	d  time.Duration
+}
+
+func (n durationNode) match(t *Task) bool {
+	if t.Duration == "" {
+		return false
+	}
+	d, err := time.ParseDuration(t.Duration)
+	if err != nil {
+		return false
+	}
+	return compare(n.op, int(d-n.d))
+}
+
+type statusNode struct{ status string }
+
+func (n statusNode) match(t *Task) bool { return t.Status == n.status }
+
+// fileNode matches the task's path against a glob. Patterns without a slash
+// match the base name; others may use "**" and match any trailing part of
+// the path unless they are absolute.
+type fileNode struct{ pattern string }
+
+func (n fileNode) match(t *Task) bool {
+	p := filepath.ToSlash(t.FilePath)
+	if !strings.Contains(n.pattern, "/") {
+		ok, _ := path.Match(n.pattern, path.Base(p))
+		return ok
+	}
+	if strings.HasPrefix(n.pattern, "/") {
+		return matchGlobPath(n.pattern, p)
+	}
+	return matchGlobPath("**/"+n.pattern, p)
+}
+
+type idNode struct{ id string }
+
+func (n idNode) match(t *Task) bool { return t.ID == n.id }
+
+// hasNode matches tasks that have a field set or carry a marker of a kind.
+type hasNode struct{ what string }
+
+func (n hasNode) match(t *Task) bool {
+	switch n.what {
+	case "due":
+		return t.DueDate != nil
+	case "time":
+		return t.DueTime != ""
+	case "duration":
+		return t.Duration != ""
+	case "tags":
+		return len(t.Tags) > 0
+	case "markers":
+		return len(t.Markers) > 0
+	case "recurrence":
+		return t.Recurrence != ""
+	case "notes":
+		return len(t.Notes) > 0
+	case "priority":
+		return t.Priority != 0
+	case "id":
+		return t.ID != ""
+	}
+	for _, m := range t.Markers {
+		if m.Kind == n.what {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyNode is a case-insensitive substring match on the task body or any
+// of its notes.
+type bodyNode struct{ text string }
+
+func (n bodyNode) match(t *Task) bool {
+	return anyText(t, func(s string) bool { return strings.Contains(strings.ToLower(s), n.text) })
+}
+
+type regexNode struct{ re *regexp.Regexp }
+
+func (n regexNode) match(t *Task) bool { return anyText(t, n.re.MatchString) }
+
+// anyText reports whether match holds for the body of t or one of its notes.
+func anyText(t *Task, match func(string) bool) bool {
+	if match(t.Body) {
+		return true
+	}
+	for _, note := range t.Notes {
+		if note != "" && match(note) {
+			return true
+		}
+	}
+	return false
+}
+
+// tagsQuery builds the query equivalent of repeated --tag flags (OR logic).
+func tagsQuery(tags []string) *Query {
+	if len(tags) == 0 {
+		return nil
+	}
+	var root queryNode = tagNode{tags[0]}
+	for _, tag := range tags[1:] {
+		root = orNode{root, tagNode{tag}}
+	}
+	return &Query{src: "#" + strings.Join(tags, " OR #"), root: root}
+}
+
+// andQueries combines queries with AND, ignoring nil ones.
+func andQueries(queries ...*Query) *Query {
+	var out *Query
+	for _, q := range queries {
+		switch {
+		case q == nil:
+		case out == nil:
+			out = q
+		default:
+			out = &Query{src: "(" + out.src + ") AND (" + q.src + ")", root: andNode{out.root, q.root}}
+		}
+	}
+	return out
+}
+
+// Query tokens.
+const (
+	qtEOF = iota
+	qtLParen
+	qtRParen
+	qtAnd
+	qtOr
+	qtNot
+	qtTerm
+)
+
+// queryValue kinds.
+const (
+	qvBare = iota
+	qvQuoted
+	qvRegex
+)
+
+type queryToken struct {
+	kind      int
+	pos       int // 0-based byte offset
+	field     string
+	op        string
+	value     string
+	valueKind int
+	valuePos  int
+}
+
+var queryOps = []string{"!=", "<=", ">=", ":", "=", "<", ">"}
+
+type queryLexer struct {
+	src string
+	pos int
+}
+
+func (l *queryLexer) errorf(pos int, format string, args ...interface{}) *QueryError {
+	return &QueryError{Query: l.src, Column: pos + 1, Msg: fmt.Sprintf(format, args...)}
+}
+
+func isQuerySpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' }
+
+func isQueryDelim(c byte) bool { return isQuerySpace(c) || c == '(' || c == ')' }
+
+// readQuoted reads a double-quoted string starting at l.pos, handling \" and
+// \\ escapes.
+func (l *queryLexer) readQuoted() (string, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src):
+			b.WriteByte(l.src[l.pos+1])
+			l.pos += 2
+		case c == '"':
+			l.pos++
+			return b.String(), nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(start, "unterminated quoted string")
+}
+
+// readRegex reads a /regex/ literal starting at l.pos. A trailing "i" makes
+// it case-insensitive.
+func (l *queryLexer) readRegex() (string, error) {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		c := l.src[l.pos]
+		switch {
+		case c == '\\' && l.pos+1 < len(l.src) && l.src[l.pos+1] == '/':
+			b.WriteByte('/')
+			l.pos += 2
+		case c == '/':
+			l.pos++
+			expr := b.String()
+			if l.pos < len(l.src) && l.src[l.pos] == 'i' &&
+				(l.pos+1 == len(l.src) || isQueryDelim(l.src[l.pos+1])) {
+				l.pos++
+				expr = "(?i)" + expr
+			}
+			return expr, nil
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+	return "", l.errorf(start, "unterminated regular expression")
+}
+
+func (l *queryLexer) readBare() string {
+	start := l.pos
+	for l.pos < len(l.src) && !isQueryDelim(l.src[l.pos]) {
+		l.pos++
+	}
+	return l.src[start:l.pos]
+}
+
+// readValue reads a field value: quoted, bare, or a regex for body fields.
+func (l *queryLexer) readValue(tok *queryToken) error {
+	tok.valuePos = l.pos
+	if l.pos >= len(l.src) || isQueryDelim(l.src[l.pos]) {
+		return l.errorf(l.pos, "missing value after %s%s", tok.field, tok.op)
+	}
+	var err error
+	switch l.src[l.pos] {
+	case '"':
+		tok.valueKind = qvQuoted
+		tok.value, err = l.readQuoted()
+	case '/':
+		if tok.field != "body" && tok.field != "text" {
+			tok.value = l.readBare() // e.g. an absolute path glob
+			break
+		}
+		tok.valueKind = qvRegex
+		tok.value, err = l.readRegex()
+	default:
+		tok.value = l.readBare()
+	}
+	return err
+}
+
+func (l *queryLexer) next() (queryToken, error) {
+	for l.pos < len(l.src) && isQuerySpace(l.src[l.pos]) {
+		l.pos++
+	}
+	tok := queryToken{pos: l.pos}
+	if l.pos >= len(l.src) {
+		return tok, nil
+	}
+	rest := l.src[l.pos:]
+	switch {
+	case rest[0] == '(':
+		l.pos++
+		tok.kind = qtLParen
+		return tok, nil
+	case rest[0] == ')':
+		l.pos++
+		tok.kind = qtRParen
+		return tok, nil
+	case strings.HasPrefix(rest, "&&"):
+		l.pos += 2
+		tok.kind = qtAnd
+		return tok, nil
+	case strings.HasPrefix(rest, "||"):
+		l.pos += 2
+		tok.kind = qtOr
+		return tok, nil
+	case (rest[0] == '!' || rest[0] == '-') && len(rest) > 1 && !isQuerySpace(rest[1]):
+		l.pos++
+		tok.kind = qtNot
+		return tok, nil
+	case rest[0] == '"':
+		tok.kind = qtTerm
+		tok.field, tok.op, tok.valueKind, tok.valuePos = "body", ":", qvQuoted, l.pos
+		v, err := l.readQuoted()
+		tok.value = v
+		return tok, err
+	case rest[0] == '/':
+		tok.kind = qtTerm
+		tok.field, tok.op, tok.valueKind, tok.valuePos = "body", ":", qvRegex, l.pos
+		v, err := l.readRegex()
+		tok.value = v
+		return tok, err
+	}
+
+	// field op value, or a bare word
+	i := 0
+	for i < len(rest) && (rest[i] == '_' || rest[i] >= 'a' && rest[i] <= 'z' || rest[i] >= 'A' && rest[i] <= 'Z') {
+		i++
+	}
+	if i > 0 {
+		for _, op := range queryOps {
+			if strings.HasPrefix(rest[i:], op) {
+				tok.kind = qtTerm
+				tok.field = strings.ToLower(rest[:i])
+				tok.op = op
+				l.pos += i + len(op)
+				return tok, l.readValue(&tok)
+			}
+		}
+	}
+
+	word := l.readBare()
+	switch strings.ToUpper(word) {
+	case "AND":
+		tok.kind = qtAnd
+	case "OR":
+		tok.kind = qtOr
+	case "NOT":
+		tok.kind = qtNot
+	default:
+		tok.kind = qtTerm
+		tok.value, tok.valuePos = word, tok.pos
+	}
+	return tok, nil
+}
+
+type queryParser struct {
+	lex  *queryLexer
+	tok  queryToken
+	ctx  *ParseContext
+	now  time.Time
+	opts []string // status names accepted by status:
+}
+
+func (p *queryParser) advance() error {
+	tok, err := p.lex.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+// ParseQuery compiles a query expression. Relative dates are resolved
+// against now. An empty expression yields a nil query, which matches
+// everything.
+func ParseQuery(src string, ctx *ParseContext, now time.Time) (*Query, error) {
+	if strings.TrimSpace(src) == "" {
+		return nil, nil
+	}
+	p := &queryParser{lex: &queryLexer{src: src}, ctx: ctx, now: now}
+	seen := map[string]bool{}
+	for _, name := range ctx.statusMap {
+		if !seen[name] {
+			seen[name] = true
+			p.opts = append(p.opts, name)
+		}
+	}
+	sort.Strings(p.opts)
+
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	switch p.tok.kind {
+	case qtEOF:
+	case qtRParen:
+		return nil, p.lex.errorf(p.tok.pos, "unexpected ')'")
+	default:
+		return nil, p.lex.errorf(p.tok.pos, "unexpected input")
+	}
+	return &Query{src: src, root: root}, nil
+}
+
+func (p *queryParser) parseOr() (queryNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok.kind == qtOr {
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *queryParser) parseAnd() (queryNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		switch p.tok.kind {
+		case qtAnd:
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+		case qtNot, qtLParen, qtTerm:
+			// implicit AND
+		default:
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *queryParser) parseUnary() (queryNode, error) {
+	tok := p.tok
+	switch tok.kind {
+	case qtNot:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{n}, nil
+	case qtLParen:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok.kind != qtRParen {
+			return nil, p.lex.errorf(tok.pos, "missing closing ')' for this '('")
+		}
+		return n, p.advance()
+	case qtTerm:
+		n, err := p.term(tok)
+		if err != nil {
+			return nil, err
+		}
+		return n, p.advance()
+	case qtEOF:
+		return nil, p.lex.errorf(tok.pos, "unexpected end of query, expected a term")
+	case qtRParen:
+		return nil, p.lex.errorf(tok.pos, "unexpected ')', expected a term")
+	}
+	return nil, p.lex.errorf(tok.pos, "unexpected %s, expected a term", p.lex.src[tok.pos:p.lex.pos])
+}
+
+// term compiles a single term token.
+func (p *queryParser) term(tok queryToken) (queryNode, error) {
+	errAt := func(format string, args ...interface{}) error {
+		return p.lex.errorf(tok.valuePos, format, args...)
+	}
+
+	if tok.field == "" {
+		word := tok.value
+		for _, prefix := range []string{p.ctx.tagPrefix, "#"} {
+			if prefix != "" && strings.HasPrefix(word, prefix) && len(word) > len(prefix) {
+				return tagNode{strings.TrimPrefix(word, prefix)}, nil
+			}
+		}
+		return bodyNode{strings.ToLower(word)}, nil
+	}
+
+	equality := tok.op == ":" || tok.op == "=" || tok.op == "!="
+	negate := func(n queryNode) queryNode {
+		if tok.op == "!=" {
+			return notNode{n}
+		}
+		return n
+	}
+	requireEquality := func() error {
+		if !equality {
+			return p.lex.errorf(tok.valuePos-len(tok.op), "operator %s is not supported for %s", tok.op, tok.field)
+		}
+		return nil
+	}
+	op := tok.op
+	if op == ":" || op == "!=" {
+		op = "="
+	}
+
+	switch tok.field {
+	case "tag", "tags":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		v := tok.value
+		for _, prefix := range []string{p.ctx.tagPrefix, "#"} {
+			if prefix != "" {
+				v = strings.TrimPrefix(v, prefix)
+			}
+		}
+		if _, err := path.Match(v, ""); err != nil {
+			return nil, errAt("invalid tag pattern %q", tok.value)
+		}
+		return negate(tagNode{v}), nil
+
+	case "due":
+		if strings.EqualFold(tok.value, "none") {
+			if err := requireEquality(); err != nil {
+				return nil, err
+			}
+			if tok.op == "!=" {
+				return hasNode{"due"}, nil
+			}
+			return notNode{hasNode{"due"}}, nil
+		}
+		date, err := parseQueryDate(tok.value, p.now, p.ctx.formats.GoDate)
+		if err != nil {
+			return nil, errAt("%v", err)
+		}
+		return negate(dueNode{op, date}), nil
+
+	case "time":
+		m, ok := clockMinutes(tok.value)
+		if !ok {
+			return nil, errAt("invalid time %q (want HH:MM or 3:04pm)", tok.value)
+		}
+		return negate(timeNode{op, m}), nil
+
+	case "duration", "dur":
+		d, err := parseQueryDuration(tok.value)
+		if err != nil {
+			return nil, errAt("invalid duration %q (want e.g. 30m, 1h, 1h30m)", tok.value)
+		}
+		return negate(durationNode{op, d}), nil
+
+	case "priority":
+		level, err := parsePriorityName(tok.value)
+		if err != nil {
+			return nil, errAt("%v", err)
+		}
+		if level == 0 {
+			if err := requireEquality(); err != nil {
+				return nil, err
+			}
+		}
+		return negate(priorityNode{op, level}), nil
+
+	case "status", "is":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		v := strings.ToLower(tok.value)
+		for _, s := range p.opts {
+			if s == v {
+				return negate(statusNode{v}), nil
+			}
+		}
+		return nil, errAt("unknown status %q (want %s)", tok.value, strings.Join(p.opts, ", "))
+
+	case "file", "path":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		if _, err := path.Match(tok.value, ""); err != nil {
+			return nil, errAt("invalid file pattern %q", tok.value)
+		}
+		return negate(fileNode{tok.value}), nil
+
+	case "id":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		return negate(idNode{strings.TrimPrefix(tok.value, "^")}), nil
+
+	case "has":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		return negate(hasNode{strings.ToLower(tok.value)}), nil
+
+	case "body", "text":
+		if err := requireEquality(); err != nil {
+			return nil, err
+		}
+		if tok.valueKind == qvRegex {
+			re, err := regexp.Compile(tok.value)
+			if err != nil {
+				return nil, errAt("invalid regular expression: %v", err)
+			}
+			return negate(regexNode{re}), nil
+		}
+		return negate(bodyNode{strings.ToLower(tok.value)}), nil
+	}
+
+	return nil, p.lex.errorf(tok.pos, "unknown field %q (want tag, due, time, duration, priority, status, file, id, has or body; quote text to search for it)", tok.field)
+}
+
+var queryRelDateRe = regexp.MustCompile(`^([+-]\d+)(bd|[dwmy])$`)
+
+// parseQueryDate resolves today/tomorrow/yesterday, relative offsets such as
+// +7d, -2w or +5bd, and absolute dates in the configured or ISO format.
+func parseQueryDate(s string, now time.Time, goDateFmt string) (time.Time, error) {
+	today := extractDate(now)
+	switch strings.ToLower(s) {
+	case "today":
+		return today, nil
+	case "tomorrow":
+		return today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), nil
+	}
+	if m := queryRelDateRe.FindStringSubmatch(s); m != nil {
+		o, err := parseDuration(strings.TrimPrefix(m[1], "+") + m[2])
+		if err != nil {
+			return time.Time{}, err
+		}
+		return o.addTo(today), nil
+	}
+	for _, layout := range []string{goDateFmt, "2006-01-02"} {
+		if layout == "" {
+			continue
+		}
+		if d, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return d, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q (want a date, today, tomorrow, yesterday or an offset like +7d)", s)
+}
+
+// parseQueryDuration accepts Go durations (30m, 1h30m) and bare minute counts.
+func parseQueryDuration(s string) (time.Duration, error) {
+	if n, err := strconv.Atoi(s); err == nil {
+		return time.Duration(n) * time.Minute, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// clockRe matches a time of day in 24-hour ("09:30", "17:05") or 12-hour
+// ("9:30 AM", "5:05pm", "5 PM") form, as any configured TimeFormat writes it.
+var clockRe = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?\s*([AaPp][Mm])?$`)
+
+// clockMinutes converts a time of day to minutes after midnight.
+func clockMinutes(s string) (int, bool) {
+	m := clockRe.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil || m[2] == "" && m[3] == "" {
+		return 0, false
+	}
+	h, _ := strconv.Atoi(m[1])
+	min, _ := strconv.Atoi(m[2])
+	if min > 59 {
+		return 0, false
+	}
+	if m[3] == "" {
+		if h > 23 {
+			return 0, false
+		}
+		return h*60 + min, true
+	}
+	if h < 1 || h > 12 {
+		return 0, false
+	}
+	h %= 12
+	if strings.EqualFold(m[3], "pm") {
+		h += 12
+	}
+	return h*60 + min, true
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// queryTasks is a small fixture covering every field the query language reads.
+// testNow is 2026-02-17.
+var queryTasks = []Task{
+	{FilePath: "/notes/work/plan.md", LineNumber: 1, Body: "Write quarterly plan", DueDate: mustDatePtr("2026-02-17"),
+		DueTime: "09:30", Duration: "90m", Tags: []string{"work", "planning"}, Status: "open"},
+	{FilePath: "/notes/work/review.md", LineNumber: 3, Body: "Review PR #42", DueDate: mustDatePtr("2026-02-20"),
+		Duration: "30m", Tags: []string{"work"}, Status: "open",
+		Markers: []Marker{{Kind: "deferral", Date: "2026-02-16"}, {Kind: "original", Date: "2026-02-10"}}},
+	{FilePath: "/notes/home.md", LineNumber: 5, Body: "Call plumber", DueDate: mustDatePtr("2026-03-30"),
+		Tags: []string{"home/errands"}, Status: "open"},
+	{FilePath: "/notes/home.md", LineNumber: 8, Body: "Read a book", Status: "done"},
+}
+
+func queryBodies(t *testing.T, src string) []string {
+	t.Helper()
+	q, err := ParseQuery(src, DefaultParseContext(), testNow)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", src, err)
+	}
+	var bodies []string
+	for _, task := range q.Filter(queryTasks) {
+		bodies = append(bodies, task.Body)
+	}
+	return bodies
+}
+
+func TestParseQuery_Matching(t *testing.T) {
+	cases := []struct {
+		query string
+		want  []string
+	}{
+		{"#work", []string{"Write quarterly plan", "Review PR #42"}},
+		{"tag:planning", []string{"Write quarterly plan"}},
+		{"#home/*", []string{"Call plumber"}},
+		{"#work AND NOT #planning", []string{"Review PR #42"}},
+		{"#work -#planning", []string{"Review PR #42"}},
+		{"#planning OR #home/errands", []string{"Write quarterly plan", "Call plumber"}},
+		{"#work && (due:today || has:deferral)", []string{"Write quarterly plan", "Review PR #42"}},
+		{"due<+7d", []string{"Write quarterly plan", "Review PR #42"}},
+		{"due>=+7d", []string{"Call plumber"}},
+		{"due=2026-02-20", []string{"Review PR #42"}},
+		{"due>tomorrow due<=+6w", []string{"Review PR #42", "Call plumber"}},
+		{"due:none", []string{"Read a book"}},
+		{"due!=none status:done", nil},
+		{"status:done", []string{"Read a book"}},
+		{"status!=open", []string{"Read a book"}},
+		{"file:home.md", []string{"Call plumber", "Read a book"}},
+		{"file:work/*.md", []string{"Write quarterly plan", "Review PR #42"}},
+		{"path:/notes/**/review.md", []string{"Review PR #42"}},
+		{"duration>=1h", []string{"Write quarterly plan"}},
+		{"duration<60", []string{"Review PR #42"}},
+		{"time<12:00", []string{"Write quarterly plan"}},
+		{"has:deferral", []string{"Review PR #42"}},
+		{"has:time OR has:tags -has:duration", []string{"Write quarterly plan", "Call plumber"}},
+		{"plumber", []string{"Call plumber"}},
+		{`"pr #42"`, []string{"Review PR #42"}},
+		{`body:"quarterly plan"`, []string{"Write quarterly plan"}},
+		{"/^(Call|Read) /", []string{"Call plumber", "Read a book"}},
+		{"/^review/i", []string{"Review PR #42"}},
+		{"body:/\\d+$/", []string{"Review PR #42"}},
+		{"not (#work or #home/errands)", []string{"Read a book"}},
+	}
+	for _, c := range cases {
+		got := queryBodies(t, c.query)
+		if strings.Join(got, "|") != strings.Join(c.want, "|") {
+			t.Errorf("%q matched %q, want %q", c.query, got, c.want)
+		}
+	}
+}
+
+func TestParseQuery_Precedence(t *testing.T) {
+	// AND binds tighter than OR.
+	got := queryBodies(t, "#planning OR #work has:deferral")
+	want := []string{"Write quarterly plan", "Review PR #42"}
+	if strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	got = queryBodies(t, "(#planning OR #work) has:deferral")
+	if strings.Join(got, "|") != "Review PR #42" {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestParseQuery_Empty(t *testing.T) {
+	q, err := ParseQuery("  ", DefaultParseContext(), testNow)
+	if err != nil || q != nil {
+		t.Fatalf("got %v, %v; want nil query", q, err)
+	}
+	if !q.Match(queryTasks[0]) {
+		t.Error("nil query should match everything")
+	}
+}
+
+func TestParseQuery_CustomTagPrefix(t *testing.T) {
+	ctx := NewParseContext(Config{TagPrefix: "+"})
+	q, err := ParseQuery("+work", ctx, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !q.Match(queryTasks[1]) || q.Match(queryTasks[2]) {
+		t.Error("+work should match tag work only")
+	}
+}
+
+func TestParseQuery_Errors(t *testing.T) {
+	cases := []struct {
+		query  string
+		column int
+		msg    string
+	}{
+		{"#work AND", 10, "unexpected end of query"},
+		{"(#work OR #home", 1, "missing closing ')'"},
+		{"#work)", 6, "unexpected ')'"},
+		{"OR #work", 1, "unexpected OR"},
+		{"prio:high", 1, `unknown field "prio"`},
+		{"due<soon", 5, `invalid date "soon"`},
+		{"due:", 5, "missing value after due:"},
+		{"status:opn", 8, `unknown status "opn" (want done, irrelevant, open)`},
+		{"tag<work", 4, "operator < is not supported for tag"},
+		{"duration>long", 10, `invalid duration "long"`},
+		{"time>=noon", 7, `invalid time "noon"`},
+		{`"unterminated`, 1, "unterminated quoted string"},
+		{"/abc", 1, "unterminated regular expression"},
+		{"body:/(/", 6, "invalid regular expression"},
+		{"due:/x/", 5, `invalid date "/x/"`},
+	}
+	for _, c := range cases {
+		_, err := ParseQuery(c.query, DefaultParseContext(), testNow)
+		var qe *QueryError
+		if !errors.As(err, &qe) {
+			t.Errorf("%q: got %v, want QueryError", c.query, err)
+			continue
+		}
+		if qe.Column != c.column || !strings.Contains(qe.Msg, c.msg) {
+			t.Errorf("%q: got %q at column %d, want %q at column %d", c.query, qe.Msg, qe.Column, c.msg, c.column)
+		}
+	}
+}
+
+func TestQueryError_PointsAtColumn(t *testing.T) {
+	_, err := ParseQuery("#work prio:high", DefaultParseContext(), testNow)
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("error = %q", err)
+	}
+	if strings.Index(lines[2], "^") != strings.Index(lines[1], "prio") {
+		t.Errorf("caret misplaced:\n%s", err)
+	}
+}
+
+func TestFormatTaskfile_QueryAndTagFilter(t *testing.T) {
+	q, _ := ParseQuery("due<+7d", DefaultParseContext(), testNow)
+	got := FormatTaskfile(queryTasks, testNow, FormatOpts{TagFilter: []string{"work", "home/errands"}, Query: q})
+	if !strings.Contains(got, "Write quarterly plan") || !strings.Contains(got, "Review PR") {
+		t.Errorf("missing work tasks:\n%s", got)
+	}
+	if strings.Contains(got, "plumber") {
+		t.Errorf("query should exclude far-off task:\n%s", got)
+	}
+}
+
+func TestRenderListAndTags_Query(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Ship release #work #urgent\n- [ ] Fix bike #home\n",
+		"b.md": "- [ ] Draft memo #work\n",
+	})
+	ctx := DefaultParseContext()
+
+	out, err := renderList([]string{dir}, ctx, []string{"--query", "#work -#urgent"}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out, "Draft memo") || strings.Contains(out, "Ship release") || strings.Contains(out, "Fix bike") {
+		t.Errorf("unexpected list:\n%s", out)
+	}
+
+	tags, err := collectTags([]string{dir}, ctx, []string{"--query", "file:a.md #work"}, Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(tags, ",") != "urgent,work" {
+		t.Errorf("tags = %v", tags)
+	}
+
+	if _, err := renderList([]string{dir}, ctx, []string{"--query", "due<"}, Config{}); err == nil {
+		t.Error("expected error for malformed query")
+	}
+}
+
+func TestClockMinutes(t *testing.T) {
+	cases := map[string]int{
+		"00:00": 0, "09:30": 570, "17:05": 1025, "9:30": 570,
+		"12:00 AM": 0, "12:15 PM": 735, "9:30 AM": 570, "5:05pm": 1025, "5 PM": 1020,
+	}
+	for s, want := range cases {
+		if got, ok := clockMinutes(s); !ok || got != want {
+			t.Errorf("clockMinutes(%q) = %d, %t; want %d", s, got, ok, want)
+		}
+	}
+	for _, s := range []string{"", "noon", "24:00", "13:00 PM", "0:30 AM", "9", "12:60"} {
+		if _, ok := clockMinutes(s); ok {
+			t.Errorf("clockMinutes(%q) should fail", s)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// recentEntry is a line of `task recent`: the running task or one of the
+// recently worked ones.
+type recentEntry struct {
+	Status string `json:"status"` // running, stopped or completed
+	CurrentTask
+	StopTime int64 `json:"stop_time,omitempty"`
+}
+
+// recentEntries lists the running task, if any, then the recent tasks, most
+// recent first, each task once.
+func recentEntries(st *State) []recentEntry {
+	entries := []recentEntry{}
+	if st.Current != nil {
+		entries = append(entries, recentEntry{Status: "running", CurrentTask: *st.Current})
+	}
+	for _, r := range st.Recent {
+		if st.Current != nil && r.sameTask(*st.Current) {
+			continue
+		}
+		status := "stopped"
+		if r.Completed {
+			status = "completed"
+		}
+		entries = append(entries, recentEntry{Status: status, CurrentTask: r.CurrentTask, StopTime: r.StopTime})
+	}
+	return entries
+}
+
+// cmdRecent lists the running task and the recently worked ones.
+//
+//	task recent [--limit N] [--json]
+func cmdRecent(ctx *ParseContext, args []string, cfg Config) error {
+	return recent(os.Stdout, ctx, args, cfg)
+}
+
+// recent is cmdRecent writing to out.
+func recent(out io.Writer, ctx *ParseContext, args []string, cfg Config) error {
+	fs := flag.NewFlagSet("recent", flag.ContinueOnError)
+	limit := fs.Int("limit", 0, "list at most this many tasks")
+	asJSON := fs.Bool("json", false, "print the tasks as a JSON array")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: task recent [--limit N] [--json]")
+	}
+	st, err := ReadStateFrom(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+	entries := recentEntries(st)
+	if *limit > 0 && len(entries) > *limit {
+		entries = entries[:*limit]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(entries)
+	}
+	layout := ctx.formats.GoDate + " " + ctx.formats.GoTime
+	for _, e := range entries {
+		when := e.StartTime
+		if e.Status != "running" {
+			when = e.StopTime
+		}
+		if _, err := fmt.Fprintf(out, "%-9s  %s  %s  (%s:%d)\n", e.Status,
+			time.Unix(when, 0).In(time.Local).Format(layout), e.Name, e.FilePath, e.LineNumber); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resumeTask restarts the most recently stopped task that is not running,
+// stopping the running one, both at at or now if at is zero. Completed tasks
+// are passed over. It returns the task started, nil if there is none to
+// resume, and the task stopped, if any.
+func resumeTask(ctx *ParseContext, cfg Config, at time.Time) (*Task, *CurrentTask, error) {
+	st, err := ReadStateFrom(cfg.StateDir)
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, r := range st.Recent {
+		if r.Completed || (st.Current != nil && r.sameTask(*st.Current)) {
+			continue
+		}
+		ct := r.CurrentTask
+		if err := locateCurrentTask(ctx, &ct); err != nil {
+			return nil, nil, fmt.Errorf("resuming %q: %w", r.Name, err)
+		}
+		task, err := readTaskAt(ctx, ct.FilePath, ct.LineNumber)
+		if err != nil {
+			return nil, nil, fmt.Errorf("resuming %q: %w", r.Name, err)
+		}
+		if task.Status != "open" {
+			return nil, nil, fmt.Errorf("resuming %q: %s:%d is no longer open", r.Name, ct.FilePath, ct.LineNumber)
+		}
+		stopped, err := switchTask(ctx, cfg, task, at)
+		return &task, stopped, err
+	}
+	return nil, nil, nil
+}
+
+// cmdResume restarts the last stopped task.
+//
+//	task resume [--at TIME]
+func cmdResume(ctx *ParseContext, cfg Config, args []string) error {
+	at, err := parseAtFlag("resume", args, ctx, time.Now().In(time.Local))
+	if err != nil {
+		return err
+	}
+	task, stopped, err := resumeTask(ctx, cfg, at)
+	if stopped != nil {
+		fmt.Printf("Stopped: %s\n", stopped.Name)
+	}
+	if err != nil {
+		return err
+	}
+	if task == nil {
+		fmt.Println("No stopped task to resume.")
+		return nil
+	}
+	fmt.Printf("Started: %s\n", task.Body)
+	return nil
+}
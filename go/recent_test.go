@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestRecent(t *testing.T) {
+	cfg := Config{StateDir: t.TempDir()}
+	stop := time.Date(2026, 2, 17, 9, 40, 0, 0, time.Local)
+	for _, name := range []string{"Read", "Write"} {
+		ct := CurrentTask{StartTime: stop.Add(-time.Hour).Unix(), Name: name, FilePath: "/a.md", LineNumber: 1}
+		FinishCurrentTaskFrom(cfg.StateDir, ct, stop, name == "Read")
+	}
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: stop.Add(5 * time.Minute).Unix(), Name: "Read", FilePath: "/a.md", LineNumber: 2})
+
+	var out bytes.Buffer
+	if err := recent(&out, defaultCtx, nil, cfg); err != nil {
+		t.Fatal(err)
+	}
+	want := "running    2026-02-17 09:45  Read  (/a.md:2)\n" +
+		"stopped    2026-02-17 09:40  Write  (/a.md:1)\n"
+	if out.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := recent(&out, defaultCtx, []string{"--json", "--limit", "1"}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	var entries []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0]["status"] != "running" || entries[0]["name"] != "Read" || entries[0]["stop_time"] != nil {
+		t.Errorf("json: %v", entries)
+	}
+}
+
+func TestResumeTask(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Write\n- [ ] Read\n"), 0644)
+	cfg := Config{StateDir: filepath.Join(dir, "state")}
+
+	if task, _, err := resumeTask(defaultCtx, cfg, time.Time{}); task != nil || err != nil {
+		t.Fatalf("empty state: %v, %v", task, err)
+	}
+
+	start := func(line int) {
+		t.Helper()
+		if _, _, err := startAtLocation(defaultCtx, cfg, []string{f, strconv.Itoa(line)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	start(1)
+	start(2)
+	if _, err := stopCurrentTask(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	// A line was inserted above both tasks since
+	data, _ := os.ReadFile(f)
+	os.WriteFile(f, append([]byte("# Today\n"), data...), 0644)
+
+	task, stopped, err := resumeTask(defaultCtx, cfg, time.Time{})
+	if err != nil || task == nil || task.Body != "Read" || stopped != nil {
+		t.Fatalf("resume: %+v, %+v, %v", task, stopped, err)
+	}
+	if ct, _ := ReadCurrentTaskFrom(cfg.StateDir); ct == nil || ct.Name != "Read" || ct.LineNumber != 3 {
+		t.Errorf("state = %+v", ct)
+	}
+
+	// With Read running, resume goes back to Write
+	task, stopped, err = resumeTask(defaultCtx, cfg, time.Time{})
+	if err != nil || task == nil || task.Body != "Write" || stopped == nil || stopped.Name != "Read" {
+		t.Fatalf("second resume: %+v, %+v, %v", task, stopped, err)
+	}
+
+	// Completed tasks are passed over
+	if _, err := completeCurrentTask(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if task, _, err = resumeTask(defaultCtx, cfg, time.Time{}); err != nil || task == nil || task.Body != "Read" {
+		t.Errorf("after completing Write: %+v, %v", task, err)
+	}
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence bases for Config.RecurFrom.
+const (
+	recurFromDue        = "due"
+	recurFromCompletion = "completion"
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+// recurWordRe matches the inline "every ..." token in a task body.
+var recurWordRe = regexp.MustCompile(`(?i)\bevery\s+((?:\d+\s*(?:days?|weeks?|months?|years?|[dwmy]))|day|weekday|week|month|year|` +
+	`monday|tuesday|wednesday|thursday|friday|saturday|sunday|mon|tue|wed|thu|fri|sat|sun)\b`)
+
+var recurSpecRe = regexp.MustCompile(`^(\d+)\s*(d|w|m|y|days?|weeks?|months?|years?)$`)
+
+// normalizeRecurrence converts a recurrence spec ("1w", "2 weeks", "monthly",
+// "Mon", "weekday") to its canonical form: "<N><d|w|m|y>", "weekday" or a
+// full lowercase weekday name.
+func normalizeRecurrence(spec string) (string, error) {
+	s := strings.ToLower(strings.TrimSpace(spec))
+	switch s {
+	case "day", "daily":
+		return "1d", nil
+	case "week", "weekly":
+		return "1w", nil
+	case "month", "monthly":
+		return "1m", nil
+	case "year", "yearly", "annually":
+		return "1y", nil
+	case "weekday":
+		return s, nil
+	}
+	if wd, ok := weekdayNames[s]; ok {
+		return strings.ToLower(wd.String()), nil
+	}
+	if m := recurSpecRe.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		if n > 0 {
+			return fmt.Sprintf("%d%c", n, m[2][0]), nil
+		}
+	}
+	return "", fmt.Errorf("invalid recurrence %q", spec)
+}
+
+// extractRecurrence finds a recurrence on a task line, preferring the
+// "<prefix>every [[spec]]" marker over an inline "every <spec>" token in the
+// body. It returns the canonical spec and the body with the token removed.
+func extractRecurrence(line, body string, ctx *ParseContext) (string, string) {
+	if m := ctx.recurMarkerRe.FindStringSubmatch(line); m != nil {
+		if rec, err := normalizeRecurrence(m[1]); err == nil {
+			body = strings.TrimSpace(ctx.recurMarkerRe.ReplaceAllString(body, ""))
+			return rec, body
+		}
+	}
+	if loc := recurWordRe.FindStringSubmatchIndex(body); loc != nil {
+		if rec, err := normalizeRecurrence(body[loc[2]:loc[3]]); err == nil {
+			body = strings.Join(strings.Fields(body[:loc[0]]+" "+body[loc[1]:]), " ")
+			return rec, body
+		}
+	}
+	return "", body
+}
+
+// addMonthsClamped adds n months, clamping the day to the end of the target
+// month (Jan 31 + 1 month = Feb 28/29).
+func addMonthsClamped(d time.Time, n int) time.Time {
+	first := time.Date(d.Year(), d.Month(), 1, 0, 0, 0, 0, d.Location()).AddDate(0, n, 0)
+	lastDay := first.AddDate(0, 1, -1).Day()
+	day := d.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	return time.Date(first.Year(), first.Month(), day, 0, 0, 0, 0, d.Location())
+}
+
+// NextOccurrence returns the due date that follows base for a canonical
+// recurrence spec. Weekday specs pick the first matching day after base.
+func NextOccurrence(rec string, base time.Time) (time.Time, error) {
+	base = extractDate(base)
+	if rec == "weekday" {
+		next := base.AddDate(0, 0, 1)
+		for next.Weekday() == time.Saturday || next.Weekday() == time.Sunday {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, nil
+	}
+	if wd, ok := weekdayNames[rec]; ok {
+		days := (int(wd) - int(base.Weekday()) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return base.AddDate(0, 0, days), nil
+	}
+	m := recurSpecRe.FindStringSubmatch(rec)
+	if m == nil {
+		return time.Time{}, fmt.Errorf("invalid recurrence %q", rec)
+	}
+	n, _ := strconv.Atoi(m[1])
+	switch m[2] {
+	case "d":
+		return base.AddDate(0, 0, n), nil
+	case "w":
+		return base.AddDate(0, 0, 7*n), nil
+	case "m":
+		return addMonthsClamped(base, n), nil
+	default:
+		return addMonthsClamped(base, 12*n), nil
+	}
+}
+
+// formatDateGroup renders a due date with the configured date wrapper.
+func formatDateGroup(d time.Time, ctx *ParseContext) string {
+	return ctx.dateWrap[0] + d.Format(ctx.formats.GoDate) + ctx.dateWrap[1] + ctx.dateWrap[2]
+}
+
+// NextOccurrenceLine builds the line for the next occurrence of a recurring
+// task: the same text with an open checkbox, the due date advanced and the
+// dated markers (history) removed. The recurrence itself is kept. A block ID
+// is replaced with a fresh one from newID, since IDs must stay unique.
+func NextOccurrenceLine(line string, task Task, completed time.Time, ctx *ParseContext, newID func() string) (string, error) {
+	base := completed
+	if ctx.recurFrom != recurFromCompletion && task.DueDate != nil {
+		base = *task.DueDate
+	}
+	next, err := NextOccurrence(task.Recurrence, base)
+	if err != nil {
+		return "", err
+	}
+
+	out := strings.TrimRight(line, "\r\n")
+	if loc := ctx.statusRe.FindStringSubmatchIndex(out); loc != nil {
+		out = out[:loc[2]] + ctx.checkbox["open"] + out[loc[3]:]
+	}
+	out = ctx.datedMarkerRe.ReplaceAllString(out, "")
+	if task.ID != "" {
+		out = strings.TrimRight(blockIDRe.ReplaceAllStringFunc(out, func(m string) string {
+			if strings.HasPrefix(m, " ") || strings.HasPrefix(m, "\t") {
+				return m[:1]
+			}
+			return ""
+		}), " \t")
+	}
+
+	if loc := ctx.dateRe.FindStringSubmatchIndex(out); loc != nil {
+		out = out[:loc[2]] + next.Format(ctx.formats.GoDate) + out[loc[3]:]
+	} else if loc := ctx.recurMarkerRe.FindStringIndex(out); loc != nil {
+		out = strings.TrimRight(out[:loc[0]], " \t") + " " + formatDateGroup(next, ctx) + " " + strings.TrimLeft(out[loc[0]:], " \t")
+	} else {
+		out = strings.TrimRight(out, " \t") + " " + formatDateGroup(next, ctx)
+	}
+	out = strings.TrimRight(out, " \t")
+	if task.ID != "" {
+		out += " ^" + newID()
+	}
+	return out, nil
+}
+
+// recurringTaskAt reads and parses the line at lineNum, returning it only if
+// it is an open recurring task. Call it before completing the task, then pass
+// the result to insertNextOccurrence.
+func recurringTaskAt(ctx *ParseContext, filePath string, lineNum int) (*Task, string, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, "", fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	lines := strings.Split(string(data), "\n")
+	if lineNum < 1 || lineNum > len(lines) {
+		return nil, "", nil // the mutation itself reports the range error
+	}
+	line := lines[lineNum-1]
+	task, err := ParseTask(RawMatch{Path: filePath, LineNumber: lineNum, Text: line}, ctx)
+	if err != nil || task.Status != "open" || task.Recurrence == "" {
+		return nil, "", nil
+	}
+	return &task, line, nil
+}
+
+// insertNextOccurrence inserts the next occurrence of a recurring task read
+// by recurringTaskAt below it and its subtasks, so that they stay nested
+// under the completed occurrence. A nil task is a no-op.
+func insertNextOccurrence(ctx *ParseContext, task *Task, line string, completed time.Time) error {
+	if task == nil {
+		return nil
+	}
+	newID := func() string {
+		taken := make(map[string]bool)
+		if data, err := os.ReadFile(task.FilePath); err == nil {
+			for _, l := range strings.Split(string(data), "\n") {
+				if id := parseBlockID(l); id != "" {
+					taken[id] = true
+				}
+			}
+		}
+		return newBlockID(taken)
+	}
+	next, err := NextOccurrenceLine(line, *task, completed, ctx, newID)
+	if err != nil {
+		return err
+	}
+	err = editLines(task.FilePath, func(lines []string) ([]string, error) {
+		idx := task.LineNumber - 1
+		if idx < 0 || idx >= len(lines) {
+			return nil, fmt.Errorf("line %d out of range (file has %d lines)", task.LineNumber, len(lines))
+		}
+		end := subtreeEnd(lines, idx)
+		result := make([]string, 0, len(lines)+1)
+		result = append(result, lines[:end+1]...)
+		result = append(result, next)
+		return append(result, lines[end+1:]...), nil
+	})
+	if err != nil {
+		return fmt.Errorf("inserting next occurrence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNormalizeRecurrence(t *testing.T) {
+	cases := map[string]string{
+		"1w":       "1w",
+		"2 weeks":  "2w",
+		"10d":      "10d",
+		"3 months": "3m",
+		"1 year":   "1y",
+		"daily":    "1d",
+		"Monthly":  "1m",
+		"week":     "1w",
+		"Mon":      "monday",
+		"friday":   "friday",
+		"weekday":  "weekday",
+	}
+	for in, want := range cases {
+		got, err := normalizeRecurrence(in)
+		if err != nil || got != want {
+			t.Errorf("normalizeRecurrence(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	for _, bad := range []string{"", "0d", "fortnight", "2x", "-1w"} {
+		if _, err := normalizeRecurrence(bad); err == nil {
+			t.Errorf("normalizeRecurrence(%q) should fail", bad)
+		}
+	}
+}
+
+func TestNextOccurrence(t *testing.T) {
+	cases := []struct {
+		rec, base, want string
+	}{
+		{"1d", "2026-02-17", "2026-02-18"},
+		{"2w", "2026-02-17", "2026-03-03"},
+		{"1m", "2026-01-31", "2026-02-28"},
+		{"1m", "2028-01-31", "2028-02-29"},
+		{"3m", "2026-11-15", "2027-02-15"},
+		{"1y", "2028-02-29", "2029-02-28"},
+		{"monday", "2026-02-17", "2026-02-23"},  // Tuesday -> next Monday
+		{"tuesday", "2026-02-17", "2026-02-24"}, // same weekday -> one week later
+		{"weekday", "2026-02-20", "2026-02-23"}, // Friday -> Monday
+		{"weekday", "2026-02-17", "2026-02-18"},
+	}
+	for _, c := range cases {
+		got, err := NextOccurrence(c.rec, mustDate(c.base))
+		if err != nil {
+			t.Fatalf("NextOccurrence(%q, %s): %v", c.rec, c.base, err)
+		}
+		if got.Format("2006-01-02") != c.want {
+			t.Errorf("NextOccurrence(%q, %s) = %s, want %s", c.rec, c.base, got.Format("2006-01-02"), c.want)
+		}
+	}
+}
+
+func TestParseTask_Recurrence(t *testing.T) {
+	cases := []struct {
+		line, rec, body string
+	}{
+		{"- [ ] Water plants (@[[2026-02-17]]) ::every [[1w]]", "1w", "Water plants"},
+		{"- [ ] Water plants ::every [[2 weeks]] (@[[2026-02-17]]) #home", "2w", "Water plants"},
+		{"- [ ] Pay rent ::every [[monthly]]", "1m", "Pay rent"},
+		{"- [ ] Take out bins every monday (@[[2026-02-16]])", "monday", "Take out bins"},
+		{"- [ ] Stretch every 3 days", "3d", "Stretch"},
+		{"- [ ] Review every item in the list", "", "Review every item in the list"},
+		{"- [ ] Broken ::every [[sometimes]]", "", "Broken"},
+	}
+	for _, c := range cases {
+		task, err := ParseTask(RawMatch{Path: "/a.md", LineNumber: 1, Text: c.line}, defaultCtx)
+		if err != nil {
+			t.Fatalf("%q: %v", c.line, err)
+		}
+		if task.Recurrence != c.rec || task.Body != c.body {
+			t.Errorf("%q: got recurrence %q body %q, want %q %q", c.line, task.Recurrence, task.Body, c.rec, c.body)
+		}
+		if len(task.Markers) != 0 {
+			t.Errorf("%q: recurrence should not be a marker, got %+v", c.line, task.Markers)
+		}
+	}
+}
+
+func TestNextOccurrenceLine(t *testing.T) {
+	completed := time.Date(2026, 2, 20, 18, 0, 0, 0, time.Local)
+	cases := []struct {
+		name string
+		cfg  Config
+		line string
+		want string
+	}{
+		{
+			"advances due date and drops history",
+			Config{},
+			"  - [ ] Standup <15m> (@[[2026-02-17]] 09:00) #work ::every [[1d]] ::start [[2026-02-17]] 09:01 ::stop [[2026-02-17]] 09:20",
+			"  - [ ] Standup <15m> (@[[2026-02-18]] 09:00) #work ::every [[1d]]",
+		},
+		{
+			"from completion date",
+			Config{RecurFrom: "completion"},
+			"- [ ] Water plants (@[[2026-02-10]]) ::every [[1w]]",
+			"- [ ] Water plants (@[[2026-02-27]]) ::every [[1w]]",
+		},
+		{
+			"undated task gets a date before the recurrence marker",
+			Config{},
+			"- [ ] Pay rent ::every [[1m]]",
+			"- [ ] Pay rent (@[[2026-03-20]]) ::every [[1m]]",
+		},
+		{
+			"inline token and custom wrapper",
+			Config{DateWrapper: []string{"📅 ", " "}},
+			"- [ ] Take out bins every monday",
+			"- [ ] Take out bins every monday 📅 2026-02-23 ",
+		},
+	}
+	for _, c := range cases {
+		ctx := NewParseContext(c.cfg)
+		task, err := ParseTask(RawMatch{Path: "/a.md", LineNumber: 1, Text: c.line}, ctx)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		got, err := NextOccurrenceLine(c.line, task, completed, ctx, nil)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if got != strings.TrimRight(c.want, " ") {
+			t.Errorf("%s:\n got  %q\n want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCompleteAt_InsertsNextOccurrence(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "chores.md")
+	os.WriteFile(f, []byte("# Chores\n- [ ] Water plants (@[[2026-02-17]]) ::every [[1w]]\n- [ ] Other\n"), 0644)
+
+	if err := cmdCompleteAt(defaultCtx, []string{f, "2"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, f))
+	if len(lines) != 4 {
+		t.Fatalf("got %d lines:\n%s", len(lines), strings.Join(lines, "\n"))
+	}
+	if !strings.HasPrefix(lines[1], "- [x] Water plants (@[[2026-02-17]]) ::every [[1w]] ::complete [[") {
+		t.Errorf("completed line = %q", lines[1])
+	}
+	if lines[2] != "- [ ] Water plants (@[[2026-02-24]]) ::every [[1w]]" {
+		t.Errorf("next occurrence = %q", lines[2])
+	}
+	if lines[3] != "- [ ] Other" {
+		t.Errorf("following line = %q", lines[3])
+	}
+
+	// Completing an already-done task must not add another occurrence.
+	if err := cmdCompleteAt(defaultCtx, []string{f, "2"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(splitLines(readFile(t, f))); n != 4 {
+		t.Errorf("re-completing inserted a line: %d lines", n)
+	}
+}
+
+func TestCompleteAt_NextOccurrenceAfterSubtasks(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Weekly review (@[[2026-10-16]]) ::every [[1w]]\n"+
+		"    - [ ] Inbox\n\n    - [ ] Calendar\n      notes on the calendar\n- [ ] Other\n"), 0644)
+
+	if err := cmdCompleteAt(defaultCtx, []string{f, "1", "--cascade"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, f))
+	if len(lines) != 7 || lines[5] != "- [ ] Weekly review (@[[2026-10-23]]) ::every [[1w]]" || lines[6] != "- [ ] Other" {
+		t.Fatalf("next occurrence should follow the subtasks:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// The completed subtasks stay under the completed occurrence
+	tasks, _, err := scanVault([]string{dir}, defaultCtx, Config{}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	linkSubtasks(tasks)
+	for _, task := range tasks {
+		if task.LineNumber == 6 && len(task.Children) != 0 {
+			t.Errorf("next occurrence has subtasks %v", task.Children)
+		}
+	}
+}
+
+func TestCheck_RecurringAndPlain(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Plain (@[[2026-02-17]])\n- [ ] Stretch every day (@[[2026-02-17]])\n"), 0644)
+
+	if err := cmdCheck(defaultCtx, []string{f, "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdCheck(defaultCtx, []string{f, "2"}); err != nil {
+		t.Fatal(err)
+	}
+	want := "- [x] Plain (@[[2026-02-17]])\n- [x] Stretch every day (@[[2026-02-17]])\n- [ ] Stretch every day (@[[2026-02-18]])\n"
+	if got := readFile(t, f); got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCompleteCurrentTask_Recurring(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Review ::every [[friday]] ::start [[2026-02-17]] 09:00\n"), 0644)
+	cfg := Config{StateDir: stateDir}
+	if err := WriteCurrentTaskTo(stateDir, CurrentTask{Name: "Review", FilePath: f, LineNumber: 1, StartTime: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := completeCurrentTask(cfg); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, f))
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "- [x] Review") {
+		t.Fatalf("unexpected file:\n%s", strings.Join(lines, "\n"))
+	}
+	next := time.Now().In(time.Local)
+	for next = next.AddDate(0, 0, 1); next.Weekday() != time.Friday; next = next.AddDate(0, 0, 1) {
+	}
+	if want := "- [ ] Review (@[[" + next.Format("2006-01-02") + "]]) ::every [[friday]]"; lines[1] != want {
+		t.Errorf("next occurrence = %q, want %q", lines[1], want)
+	}
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
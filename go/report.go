@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Groupings for `task report --by`.
+const (
+	reportByTask = "task"
+	reportByTag  = "tag"
+	reportByFile = "file"
+	reportByDay  = "day"
+)
+
+// untaggedKey is the --by tag row for time on tasks without tags.
+const untaggedKey = "(untagged)"
+
+// interval is a span of tracked time on a task.
+type interval struct {
+	start, end time.Time
+}
+
+// markerTime reads the timestamp of a marker in the configured formats. ok is
+// false for a marker without a time, which cannot be tracked.
+func markerTime(m Marker, ctx *ParseContext) (time.Time, bool) {
+	if m.Time == "" {
+		return time.Time{}, false
+	}
+	t, err := time.ParseInLocation(ctx.formats.GoDate+" "+ctx.formats.GoTime, m.Date+" "+m.Time, time.Local)
+	return t, err == nil
+}
+
+// taskIntervals pairs the start markers of a task with the stop or complete
+// marker that follows each. running says the task is the current task, whose
+// trailing start runs until now. Markers
+// that do not pair up are left out and described in the returned warnings:
+// a stop without a start, a start followed by another start, a stop earlier
+// than its start, a marker without a time, and a start left open.
+func taskIntervals(t Task, ctx *ParseContext, running bool, now time.Time) ([]interval, []string) {
+	var out []interval
+	var warnings []string
+	warn := func(format string, args ...interface{}) {
+		warnings = append(warnings, fmt.Sprintf("%s:%d: ", t.FilePath, t.LineNumber)+fmt.Sprintf(format, args...))
+	}
+	var open *time.Time
+	for _, m := range t.Markers {
+		if m.Kind != "start" && m.Kind != "stop" && m.Kind != "complete" {
+			continue
+		}
+		at, ok := markerTime(m, ctx)
+		if !ok {
+			warn("%s marker on %s has no time; ignored", m.Kind, m.Date)
+			continue
+		}
+		switch {
+		case m.Kind == "start" && open != nil:
+			warn("start at %s follows the start at %s without a stop; the earlier one is not counted", m.Date+" "+m.Time, open.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime))
+			open = &at
+		case m.Kind == "start":
+			open = &at
+		case open == nil:
+			if m.Kind == "stop" {
+				warn("stop at %s without a start; ignored", m.Date+" "+m.Time)
+			}
+		case at.Before(*open):
+			warn("%s at %s is before its start; the interval is not counted", m.Kind, m.Date+" "+m.Time)
+			open = nil
+		default:
+			out = append(out, interval{*open, at})
+			open = nil
+		}
+	}
+	if open != nil {
+		if running && !now.Before(*open) {
+			out = append(out, interval{*open, now})
+		} else {
+			warn("start at %s has no stop; not counted", open.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime))
+		}
+	}
+	return out, warnings
+}
+
+// splitAtMidnight cuts an interval into the parts that fall on each day.
+func splitAtMidnight(iv interval) []interval {
+	var out []interval
+	for {
+		next := extractDate(iv.start).AddDate(0, 0, 1)
+		if !iv.end.After(next) {
+			return append(out, iv)
+		}
+		out = append(out, interval{iv.start, next})
+		iv.start = next
+	}
+}
+
+// markedInRange reports whether t has a start, stop or complete marker dated
+// within [start, end), so that problems with old markers do not clutter every
+// report.
+func markedInRange(t Task, ctx *ParseContext, start, end time.Time) bool {
+	for _, m := range t.Markers {
+		if m.Kind != "start" && m.Kind != "stop" && m.Kind != "complete" {
+			continue
+		}
+		d, err := time.ParseInLocation(ctx.formats.GoDate, m.Date, time.Local)
+		if err == nil && !d.Before(start) && d.Before(end) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReportRow is one line of a time report.
+type ReportRow struct {
+	Key     string `json:"key"`
+	File    string `json:"file,omitempty"` // --by task only
+	Line    int    `json:"line,omitempty"` // --by task only
+	Seconds int64  `json:"seconds"`
+}
+
+// Report is the tracked time per group between two dates, inclusive.
+type Report struct {
+	From         string      `json:"from"`
+	To           string      `json:"to"`
+	By           string      `json:"by"`
+	Rows         []ReportRow `json:"rows"`
+	TotalSeconds int64       `json:"total_seconds"`
+}
+
+// BuildReport sums the tracked time of tasks between the from and to dates
+// (both days included), grouped by task, tag, file or day. Intervals that
+// cross midnight count towards each day they cover, and only the part
+// inside the range is counted. Tasks are matched to ct, the current task,
+// by ID or location. Rows are ordered by time spent, or by date for --by day.
+func BuildReport(tasks []Task, ctx *ParseContext, ct *CurrentTask, from, to time.Time, by string, now time.Time) (Report, []string) {
+	rangeStart, rangeEnd := extractDate(from), extractDate(to).AddDate(0, 0, 1)
+	r := Report{
+		From: from.Format(ctx.formats.GoDate),
+		To:   to.Format(ctx.formats.GoDate),
+		By:   by,
+		Rows: []ReportRow{},
+	}
+	var warnings []string
+	rows := make(map[string]*ReportRow)
+	days := make(map[string]time.Time) // --by day keys, which need not sort as strings
+	add := func(key string, t Task, d time.Duration) {
+		row := rows[key]
+		if row == nil {
+			row = &ReportRow{Key: key}
+			if by == reportByTask {
+				row.Key, row.File, row.Line = t.Body, t.FilePath, t.LineNumber
+			}
+			rows[key] = row
+		}
+		row.Seconds += int64(d / time.Second)
+	}
+
+	for _, t := range tasks {
+		running := ct != nil && ((ct.ID != "" && ct.ID == t.ID) || (ct.FilePath == t.FilePath && ct.LineNumber == t.LineNumber))
+		intervals, w := taskIntervals(t, ctx, running, now)
+		if markedInRange(t, ctx, rangeStart, rangeEnd) {
+			warnings = append(warnings, w...)
+		}
+		for _, iv := range intervals {
+			for _, part := range splitAtMidnight(iv) {
+				if part.start.Before(rangeStart) {
+					part.start = rangeStart
+				}
+				if part.end.After(rangeEnd) {
+					part.end = rangeEnd
+				}
+				if !part.end.After(part.start) {
+					continue
+				}
+				d := part.end.Sub(part.start)
+				r.TotalSeconds += int64(d / time.Second)
+				switch by {
+				case reportByTag:
+					if len(t.Tags) == 0 {
+						add(untaggedKey, t, d)
+					}
+					for _, tag := range t.Tags {
+						add(ctx.tagPrefix+tag, t, d)
+					}
+				case reportByFile:
+					add(t.FilePath, t, d)
+				case reportByDay:
+					day := part.start.Format(ctx.formats.GoDate)
+					days[day] = extractDate(part.start)
+					add(day, t, d)
+				default:
+					add(fmt.Sprintf("%s:%d", t.FilePath, t.LineNumber), t, d)
+				}
+			}
+		}
+	}
+
+	for _, row := range rows {
+		r.Rows = append(r.Rows, *row)
+	}
+	if by == reportByDay {
+		sort.Slice(r.Rows, func(i, j int) bool { return days[r.Rows[i].Key].Before(days[r.Rows[j].Key]) })
+		return r, warnings
+	}
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		if a.Seconds != b.Seconds {
+			return a.Seconds > b.Seconds
+		}
+		if a.Key != b.Key {
+			return a.Key < b.Key
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Line < b.Line
+	})
+	return r, warnings
+}
+
+// formatHours renders seconds as hours and minutes ("2h05m"), rounding down
+// to the minute.
+func formatHours(seconds int64) string {
+	m := seconds / 60
+	return fmt.Sprintf("%dh%02dm", m/60, m%60)
+}
+
+// FormatReport renders a report as a table: the time, then the group, then
+// for --by task the task's location; a total closes it.
+func FormatReport(r Report) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "Time by %s, %s to %s\n", r.By, r.From, r.To)
+	for _, row := range r.Rows {
+		fmt.Fprintf(&b, "%8s  %s", formatHours(row.Seconds), row.Key)
+		if row.File != "" {
+			fmt.Fprintf(&b, "  (%s:%d)", row.File, row.Line)
+		}
+		b.WriteString("\n")
+	}
+	fmt.Fprintf(&b, "%8s  Total\n", formatHours(r.TotalSeconds))
+	return b.String()
+}
+
+// cmdReport prints the time tracked with start/stop/complete markers.
+//
+//	task report [--from DATE] [--to DATE] [--by task|tag|file|day]
+//	    [--tag T]... [--query EXPR] [--format table|json]
+func cmdReport(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
+	return report(os.Stdout, notesPaths, ctx, args, cfg, time.Now().In(time.Local))
+}
+
+// report is cmdReport writing to out, with the clock passed in.
+func report(out io.Writer, notesPaths []string, ctx *ParseContext, args []string, cfg Config, now time.Time) error {
+	var tags tagList
+	fs := flag.NewFlagSet("report", flag.ContinueOnError)
+	fromStr := fs.String("from", "start_of_week", "first day of the report (as for defer --to)")
+	toStr := fs.String("to", "today", "last day of the report, included")
+	by := fs.String("by", reportByTask, "group by task, tag, file or day")
+	fs.Var(&tags, "tag", "only tasks with this tag (repeatable, OR logic)")
+	query := fs.String("query", "", "only tasks matching this filter expression")
+	format := fs.String("format", "table", "output format: table or json")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	switch *by {
+	case reportByTask, reportByTag, reportByFile, reportByDay:
+	default:
+		return fmt.Errorf("unknown --by %q (want task, tag, file or day)", *by)
+	}
+	if *format != "table" && *format != "json" {
+		return fmt.Errorf("unknown report format %q (want table or json)", *format)
+	}
+	from, err := parseDeferTarget(*fromStr, now, nil, ctx)
+	if err != nil {
+		return fmt.Errorf("--from: %w", err)
+	}
+	to, err := parseDeferTarget(*toStr, now, nil, ctx)
+	if err != nil {
+		return fmt.Errorf("--to: %w", err)
+	}
+	if to.Before(from) {
+		return fmt.Errorf("--to %s is before --from %s", to.Format(ctx.formats.GoDate), from.Format(ctx.formats.GoDate))
+	}
+	q, err := ParseQuery(*query, ctx, now)
+	if err != nil {
+		return err
+	}
+	ct, err := ReadCurrentTaskFrom(cfg.StateDir)
+	if err != nil {
+		return err
+	}
+
+	// Done tasks carry most of the tracked time, so every status is kept
+	tasks, _, err := scanVault(notesPaths, ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	MergeFrontmatterTags(tasks)
+	InheritFromParents(tasks, cfg.Subtasks)
+	tasks = andQueries(tagsQuery(tags), q).Filter(tasks)
+
+	r, warnings := BuildReport(tasks, ctx, ct, from, to, *by, now)
+	for _, w := range warnings {
+		fmt.Fprintf(os.Stderr, "taskbuffer: warning: %s\n", w)
+	}
+	if *format == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetEscapeHTML(false)
+		return enc.Encode(r)
+	}
+	_, err = io.WriteString(out, FormatReport(r))
+	return err
+}
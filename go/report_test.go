@@ -0,0 +1,152 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTaskIntervals(t *testing.T) {
+	cases := []struct {
+		name     string
+		line     string
+		running  bool
+		minutes  int
+		warnings []string
+	}{
+		{"pair", "- [ ] A ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 10:30", false, 90, nil},
+		{"several pairs", "- [x] A ::start [[2026-02-17]] 08:00 ::stop [[2026-02-17]] 08:20 ::start [[2026-02-17]] 09:00 ::complete [[2026-02-17]] 09:10", false, 30, nil},
+		{"stop without start", "- [ ] A ::stop [[2026-02-17]] 10:00", false, 0, []string{"stop at 2026-02-17 10:00 without a start"}},
+		{"complete without start", "- [x] A ::complete [[2026-02-17]] 10:00", false, 0, nil},
+		{"two starts", "- [ ] A ::start [[2026-02-17]] 08:00 ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 09:45", false, 45, []string{"follows the start at 2026-02-17 08:00 without a stop"}},
+		{"two stops", "- [ ] A ::start [[2026-02-17]] 08:00 ::stop [[2026-02-17]] 08:30 ::stop [[2026-02-17]] 09:00", false, 30, []string{"stop at 2026-02-17 09:00 without a start"}},
+		{"stop before start", "- [ ] A ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 08:00", false, 0, []string{"is before its start"}},
+		{"unterminated", "- [ ] A ::start [[2026-02-17]] 09:00", false, 0, []string{"start at 2026-02-17 09:00 has no stop"}},
+		{"running", "- [ ] A ::start [[2026-02-17]] 09:00", true, 60, nil},
+		{"no time", "- [ ] A ::start [[2026-02-17]] ::stop [[2026-02-17]] 10:00", false, 0, []string{"start marker on 2026-02-17 has no time", "without a start"}},
+		{"other markers", "- [ ] A ::original [[2026-02-10]] ::start [[2026-02-17]] 09:00 ::deferral [[2026-02-15]] ::stop [[2026-02-17]] 09:05", false, 5, nil},
+	}
+	for _, c := range cases {
+		task, err := ParseTask(RawMatch{Path: "/a.md", LineNumber: 1, Text: c.line}, defaultCtx)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		intervals, warnings := taskIntervals(task, defaultCtx, c.running, testNow)
+		var total time.Duration
+		for _, iv := range intervals {
+			total += iv.end.Sub(iv.start)
+		}
+		if total != time.Duration(c.minutes)*time.Minute {
+			t.Errorf("%s: tracked %v, want %dm", c.name, total, c.minutes)
+		}
+		if len(warnings) != len(c.warnings) {
+			t.Errorf("%s: warnings %q, want %d", c.name, warnings, len(c.warnings))
+			continue
+		}
+		for i, w := range c.warnings {
+			if !strings.HasPrefix(warnings[i], "/a.md:1: ") || !strings.Contains(warnings[i], w) {
+				t.Errorf("%s: warning %q, want it to mention %q", c.name, warnings[i], w)
+			}
+		}
+	}
+}
+
+func TestBuildReport(t *testing.T) {
+	lines := []string{
+		"- [x] Late night #ops ::start [[2026-02-15]] 23:30 ::complete [[2026-02-16]] 00:45",
+		"- [ ] Review #work #ops ::start [[2026-02-16]] 14:00 ::stop [[2026-02-16]] 15:00 ::start [[2026-02-17]] 09:00",
+		"- [ ] Untagged ::start [[2026-02-16]] 10:00 ::stop [[2026-02-16]] 10:20",
+		"- [ ] Old ::start [[2026-01-05]] 10:00 ::start [[2026-01-05]] 11:00 ::stop [[2026-01-05]] 12:00",
+	}
+	var tasks []Task
+	for i, line := range lines {
+		task, _ := ParseTask(RawMatch{Path: "/notes/a.md", LineNumber: i + 1, Text: line}, defaultCtx)
+		tasks = append(tasks, task)
+	}
+	tasks[2].FilePath = "/notes/b.md"
+	running := &CurrentTask{FilePath: "/notes/a.md", LineNumber: 2}
+	from, to := mustDatePtr("2026-02-16"), mustDatePtr("2026-02-17")
+
+	r, warnings := BuildReport(tasks, defaultCtx, running, *from, *to, reportByDay, testNow)
+	if len(warnings) != 0 {
+		t.Errorf("warnings for markers outside the range: %q", warnings)
+	}
+	want := []ReportRow{{Key: "2026-02-16", Seconds: (45 + 60 + 20) * 60}, {Key: "2026-02-17", Seconds: 60 * 60}}
+	if len(r.Rows) != 2 || r.Rows[0] != want[0] || r.Rows[1] != want[1] {
+		t.Errorf("by day: %+v, want %+v", r.Rows, want)
+	}
+	if r.TotalSeconds != (45+60+20+60)*60 {
+		t.Errorf("total %d", r.TotalSeconds)
+	}
+
+	// The whole overnight interval counts once both days are in range
+	r, _ = BuildReport(tasks[:1], defaultCtx, nil, testToday.AddDate(0, 0, -2), *to, reportByTask, testNow)
+	if len(r.Rows) != 1 || r.Rows[0].Seconds != 75*60 || r.Rows[0].Key != "Late night" || r.Rows[0].Line != 1 {
+		t.Errorf("by task across midnight: %+v", r.Rows)
+	}
+
+	r, _ = BuildReport(tasks, defaultCtx, running, *from, *to, reportByTag, testNow)
+	got := make(map[string]int64)
+	for _, row := range r.Rows {
+		got[row.Key] = row.Seconds / 60
+	}
+	if len(got) != 3 || got["#ops"] != 45+120 || got["#work"] != 120 || got[untaggedKey] != 20 {
+		t.Errorf("by tag: %v", got)
+	}
+	if r.Rows[0].Key != "#ops" {
+		t.Errorf("rows are not ordered by time: %+v", r.Rows)
+	}
+
+	r, _ = BuildReport(tasks, defaultCtx, nil, *from, *to, reportByFile, testNow)
+	if len(r.Rows) != 2 || r.Rows[0].Key != "/notes/a.md" || r.Rows[0].Seconds != 105*60 || r.Rows[1].Seconds != 20*60 {
+		t.Errorf("by file without a running task: %+v", r.Rows)
+	}
+
+	_, warnings = BuildReport(tasks, defaultCtx, nil, testToday.AddDate(0, -2, 0), *to, reportByTask, testNow)
+	if len(warnings) != 2 {
+		t.Errorf("warnings: %q", warnings)
+	}
+}
+
+func TestReport(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [x] Fix leak #work ::start [[2026-02-17]] 08:00 ::complete [[2026-02-17]] 09:30\n" +
+			"- [ ] Write docs ::start [[2026-02-16]] 16:00 ::stop [[2026-02-16]] 16:05\n",
+	})
+	cfg := Config{StateDir: t.TempDir()}
+
+	var out bytes.Buffer
+	if err := report(&out, []string{dir}, defaultCtx, []string{"--from", "2026-02-16"}, cfg, testNow); err != nil {
+		t.Fatal(err)
+	}
+	want := "Time by task, 2026-02-16 to 2026-02-17\n" +
+		"   1h30m  Fix leak  (" + filepath.Join(dir, "a.md") + ":1)\n" +
+		"   0h05m  Write docs  (" + filepath.Join(dir, "a.md") + ":2)\n" +
+		"   1h35m  Total\n"
+	if out.String() != want {
+		t.Errorf("table:\n%s\nwant:\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := report(&out, []string{dir}, defaultCtx, []string{"--by", "tag", "--format", "json"}, cfg, testNow); err != nil {
+		t.Fatal(err)
+	}
+	var r Report
+	if err := json.Unmarshal(out.Bytes(), &r); err != nil {
+		t.Fatalf("%v: %s", err, out.String())
+	}
+	if r.From != "2026-02-16" || r.To != "2026-02-17" || len(r.Rows) != 2 || r.Rows[0].Key != "#work" || r.TotalSeconds != 95*60 {
+		t.Errorf("json: %s", out.String())
+	}
+
+	for _, args := range [][]string{{"--by", "week"}, {"--format", "csv"}, {"--from", "2026-02-18"}} {
+		if err := report(&out, []string{dir}, defaultCtx, args, cfg, testNow); err == nil {
+			t.Errorf("%v: want an error", args)
+		}
+	}
+}
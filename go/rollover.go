@@ -0,0 +1,175 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+// overdueTasks returns the open tasks that are overdue (as bucketed by
+// GroupTasks, so with overdueByTime those due earlier today count) and due
+// before target: the tasks listed under the past horizon that moving to
+// target would push forward. Synthetic project tasks have no task line, and
+// subtasks with their parent's due date follow the parent, so both are left
+// out.
+func overdueTasks(tasks []Task, q *Query, now, target time.Time, overdueByTime bool) []Task {
+	today := extractDate(now)
+	var out []Task
+	for _, t := range q.Filter(tasks) {
+		if t.SortLast || t.DueDate == nil || t.DueFromParent {
+			continue
+		}
+		due := extractDate(*t.DueDate)
+		if taskBucketDate(t, now, overdueByTime).Before(today) && due.Before(target) {
+			out = append(out, t)
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if out[i].FilePath != out[j].FilePath {
+			return out[i].FilePath < out[j].FilePath
+		}
+		return out[i].LineNumber < out[j].LineNumber
+	})
+	return out
+}
+
+// errStaleScan reports that a task is no longer on the line it was scanned
+// at; a new scan finds it again.
+var errStaleScan = errors.New("file changed since it was scanned")
+
+// errNothingMoved abandons the rewrite of a file none of whose tasks moved.
+var errNothingMoved = errors.New("nothing to move")
+
+// rolloverFile defers the given tasks of one file to target in a single
+// rewrite. Each line is checked to still hold its task first. A task that
+// cannot be moved is left as it is and reported, as file:line, in failed;
+// the others are moved all the same. err is set if the file could not be
+// rewritten, in which case nothing in it moved.
+func rolloverFile(ctx *ParseContext, filePath string, tasks []Task, target, now time.Time) (moved int, failed []error, err error) {
+	err = editLines(filePath, func(lines []string) ([]string, error) {
+		moved, failed = 0, nil
+		for _, t := range tasks {
+			if err := rolloverLine(ctx, filePath, lines, t, target, now); err != nil {
+				failed = append(failed, fmt.Errorf("%s:%d: %w", filePath, t.LineNumber, err))
+				continue
+			}
+			moved++
+		}
+		if moved == 0 {
+			return nil, errNothingMoved
+		}
+		return lines, nil
+	})
+	if errors.Is(err, errNothingMoved) {
+		err = nil
+	}
+	if err != nil {
+		moved = 0
+	}
+	return moved, failed, err
+}
+
+// rolloverLine defers the task t in lines, after checking that its line
+// still holds it. lines is left as it was on error.
+func rolloverLine(ctx *ParseContext, filePath string, lines []string, t Task, target, now time.Time) error {
+	idx := t.LineNumber - 1
+	if idx < 0 || idx >= len(lines) {
+		return fmt.Errorf("%w: the file has %d lines", errStaleScan, len(lines))
+	}
+	current, err := ParseTask(RawMatch{Path: filePath, LineNumber: t.LineNumber, Text: lines[idx]}, ctx)
+	if err != nil || current.Body != t.Body {
+		return fmt.Errorf("%w: the line no longer holds %q", errStaleScan, t.Body)
+	}
+	return deferLine(lines, idx, &target, now, ctx, filePath)
+}
+
+// cmdRollover moves the due date of every overdue open task to today (or
+// --to), with the same ::original and ::deferral markers as `task defer`.
+func cmdRollover(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
+	return rollover(os.Stdout, notesPaths, ctx, args, cfg, time.Now().In(time.Local))
+}
+
+// rollover is cmdRollover writing to out, with the clock passed in.
+func rollover(out io.Writer, notesPaths []string, ctx *ParseContext, args []string, cfg Config, now time.Time) error {
+	var tags tagList
+	fs := flag.NewFlagSet("rollover", flag.ContinueOnError)
+	to := fs.String("to", "today", "new due date: a date, today, tomorrow, +Nd, next-monday, end_of_week, ...")
+	fs.Var(&tags, "tag", "only tasks with this tag (repeatable, OR logic)")
+	query := fs.String("query", "", "only tasks matching this filter expression")
+	dryRun := fs.Bool("dry-run", false, "list the tasks that would move without changing files")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	target, err := parseDeferTarget(*to, now, nil, ctx)
+	if err != nil {
+		return err
+	}
+	q, err := ParseQuery(*query, ctx, now)
+	if err != nil {
+		return err
+	}
+	tasks, err := loadOpenTasks(notesPaths, ctx, cfg)
+	if err != nil {
+		return err
+	}
+	overdue := overdueTasks(tasks, andQueries(tagsQuery(tags), q), now, target, cfg.OverdueByTime)
+
+	var files []string
+	byFile := make(map[string][]Task)
+	for _, t := range overdue {
+		if byFile[t.FilePath] == nil {
+			files = append(files, t.FilePath)
+		}
+		byFile[t.FilePath] = append(byFile[t.FilePath], t)
+	}
+
+	targetStr := target.Format(ctx.formats.GoDate)
+	if *dryRun {
+		for _, t := range overdue {
+			fmt.Fprintf(out, "%s:%d: %s (%s -> %s)\n", t.FilePath, t.LineNumber, t.Body, t.DueDate.Format(ctx.formats.GoDate), targetStr)
+		}
+	}
+
+	moved, movedFiles, failed := 0, 0, 0
+	retry := false
+	for _, f := range files {
+		n := len(byFile[f])
+		if !*dryRun {
+			var errs []error
+			var err error
+			n, errs, err = rolloverFile(ctx, f, byFile[f], target, now)
+			if err != nil {
+				errs = []error{fmt.Errorf("%s: %w", f, err)}
+			}
+			for _, e := range errs {
+				fmt.Fprintf(os.Stderr, "taskbuffer: warning: not moved: %v\n", e)
+				retry = retry || errors.Is(e, errFileChanged) || errors.Is(e, errStaleScan)
+			}
+			failed += len(byFile[f]) - n
+		}
+		if n == 0 {
+			continue
+		}
+		moved += n
+		movedFiles++
+		fmt.Fprintf(out, "%s: %d tasks\n", f, n)
+	}
+
+	verb := "Moved"
+	if *dryRun {
+		verb = "Would move"
+	}
+	fmt.Fprintf(out, "%s %d tasks to %s in %d files\n", verb, moved, targetStr, movedFiles)
+	if failed > 0 {
+		if retry {
+			return fmt.Errorf("could not move %d tasks; some files changed meanwhile, run task rollover again for them", failed)
+		}
+		return fmt.Errorf("could not move %d tasks", failed)
+	}
+	return nil
+}
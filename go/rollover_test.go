@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRollover(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Old work #work (@[[2026-02-10]])\n" +
+			"- [ ] Today (@[[2026-02-17]])\n" +
+			"- [x] Done (@[[2026-02-01]])\n" +
+			"- [ ] Older home #home (@[[2026-01-05]] 08:00) ::original [[2026-01-01]]\n",
+		"b.md":       "- [ ] Future (@[[2026-03-01]])\n- [ ] Stale #work (@[[2026-02-16]])\n",
+		"project.md": "---\ndue: 2026-02-12\n---\n- [ ] Inherited one\n- [ ] Inherited two\n",
+	})
+	a, b, project := filepath.Join(dir, "a.md"), filepath.Join(dir, "b.md"), filepath.Join(dir, "project.md")
+
+	var out bytes.Buffer
+	if err := rollover(&out, []string{dir}, defaultCtx, []string{"--dry-run"}, Config{}, testNow); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		a + ":1: Old work (2026-02-10 -> 2026-02-17)",
+		a + ":4: Older home (2026-01-05 -> 2026-02-17)",
+		a + ": 2 tasks",
+		b + ": 1 tasks",
+		project + ": 2 tasks",
+		"Would move 5 tasks to 2026-02-17 in 3 files",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("dry run output missing %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "Future") || strings.Contains(out.String(), "Done") {
+		t.Errorf("dry run lists tasks that are not overdue:\n%s", out.String())
+	}
+	if got := readFile(t, a); strings.Contains(got, "::deferral") {
+		t.Errorf("dry run modified a.md:\n%s", got)
+	}
+
+	out.Reset()
+	if err := rollover(&out, []string{dir}, defaultCtx, []string{"--tag", "work", "--to", "2026-02-18"}, Config{}, testNow); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Moved 2 tasks to 2026-02-18 in 2 files") {
+		t.Errorf("output:\n%s", out.String())
+	}
+	lines := splitLines(readFile(t, a))
+	if !strings.HasPrefix(lines[0], "- [ ] Old work #work (@[[2026-02-18]]) ::original [[2026-02-10]] ::deferral [[2026-02-17]]") {
+		t.Errorf("a.md line 1 = %q", lines[0])
+	}
+	if lines[3] != "- [ ] Older home #home (@[[2026-01-05]] 08:00) ::original [[2026-01-01]]" {
+		t.Errorf("untagged task moved: %q", lines[3])
+	}
+
+	out.Reset()
+	if err := rollover(&out, []string{dir}, defaultCtx, []string{"--query", "file:project.md"}, Config{}, testNow); err != nil {
+		t.Fatal(err)
+	}
+	lines = splitLines(readFile(t, project))
+	if lines[1] != "due: 2026-02-17" {
+		t.Errorf("frontmatter due = %q", lines[1])
+	}
+	for _, l := range lines[3:] {
+		if !strings.Contains(l, "::original [[2026-02-12]] ::deferral") {
+			t.Errorf("inherited task line = %q", l)
+		}
+	}
+}
+
+func TestRollover_SkipsChangedFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	os.WriteFile(path, []byte("- [ ] Old (@[[2026-02-10]])\n"), 0644)
+
+	tasks := []Task{{FilePath: path, LineNumber: 1, Body: "Something else"}}
+	moved, failed, err := rolloverFile(defaultCtx, path, tasks, testNow, testNow)
+	if err != nil || moved != 0 || len(failed) != 1 || !errors.Is(failed[0], errStaleScan) {
+		t.Errorf("moved %d, failed %v, err %v; want the task reported as changed since the scan", moved, failed, err)
+	}
+	if got := readFile(t, path); got != "- [ ] Old (@[[2026-02-10]])\n" {
+		t.Errorf("file changed: %q", got)
+	}
+}
+
+func TestRollover_KeepsOtherTasksOfFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("- [ ] Undated\n- [ ] Old (@[[2026-02-10]])\n"), 0644)
+
+	// The first task cannot be moved, having no date on its line
+	tasks := []Task{
+		{FilePath: path, LineNumber: 1, Body: "Undated"},
+		{FilePath: path, LineNumber: 2, Body: "Old"},
+	}
+	moved, failed, err := rolloverFile(defaultCtx, path, tasks, testNow, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if moved != 1 || len(failed) != 1 || !strings.HasPrefix(failed[0].Error(), path+":1: ") || errors.Is(failed[0], errStaleScan) {
+		t.Errorf("moved %d, failed %v", moved, failed)
+	}
+	lines := splitLines(readFile(t, path))
+	if lines[0] != "- [ ] Undated" || !strings.HasPrefix(lines[1], "- [ ] Old (@[[2026-02-17]]) ::original [[2026-02-10]]") {
+		t.Errorf("lines = %q", lines)
+	}
+}
+
+func TestRollover_OverdueByTime(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Morning call (@[[2026-02-17]] 09:00)\n" +
+			"- [ ] Afternoon call (@[[2026-02-17]] 14:00)\n" +
+			"- [ ] Untimed (@[[2026-02-17]])\n",
+	})
+	args := []string{"--dry-run", "--to", "tomorrow"}
+
+	// testNow is 10:00: only the 09:00 task is overdue, and only by time
+	var out bytes.Buffer
+	if err := rollover(&out, []string{dir}, defaultCtx, args, Config{OverdueByTime: true}, testNow); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Morning call (2026-02-17 -> 2026-02-18)") ||
+		!strings.Contains(out.String(), "Would move 1 tasks") {
+		t.Errorf("overdue_by_time output:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := rollover(&out, []string{dir}, defaultCtx, args, Config{}, testNow); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Would move 0 tasks") {
+		t.Errorf("without overdue_by_time nothing due today is overdue:\n%s", out.String())
+	}
+}
+
+func TestRollover_InheritedDue(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Trip (@[[2026-02-10]])\n" +
+			"    - [ ] Pack\n" +
+			"    - [ ] Book hotel (@[[2026-02-12]])\n" +
+			"- [ ] Unrelated (@[[2026-02-11]])\n",
+	})
+	a := filepath.Join(dir, "a.md")
+	cfg := Config{Subtasks: SubtaskConfig{InheritDue: true}}
+
+	// The undated subtask follows its parent rather than being moved itself
+	var out bytes.Buffer
+	if err := rollover(&out, []string{dir}, defaultCtx, nil, cfg, testNow); err != nil {
+		t.Fatalf("%v\n%s", err, out.String())
+	}
+	if !strings.Contains(out.String(), "Moved 3 tasks to 2026-02-17 in 1 files") {
+		t.Errorf("output:\n%s", out.String())
+	}
+	lines := splitLines(readFile(t, a))
+	if lines[1] != "    - [ ] Pack" {
+		t.Errorf("undated subtask changed: %q", lines[1])
+	}
+	for _, i := range []int{0, 2, 3} {
+		if !strings.Contains(lines[i], "(@[[2026-02-17]]) ::original") {
+			t.Errorf("line %d not moved: %q", i+1, lines[i])
+		}
+	}
+}
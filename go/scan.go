@@ -17,6 +17,7 @@ type RawMatch struct {
 	Path       string
 	LineNumber int
 	Text       string
+	Notes      []string // continuation lines below the task, see AnnotateMatches
 }
 
 const defaultScanPattern = `\- \[.\]`
@@ -83,7 +84,14 @@ func expandGlobs(paths []string) []string {
 	return deduplicatePaths(result)
 }
 
-// Scan searches one or more directories for task lines using ripgrep.
+// rgAvailable reports whether the rg binary can be found on PATH.
+func rgAvailable() bool {
+	_, err := exec.LookPath("rg")
+	return err == nil
+}
+
+// Scan searches one or more directories for task lines using ripgrep, or the
+// built-in walker when ctx selects it (or rg is missing in "auto" mode).
 // If ctx is non-nil, its scanPattern is used; otherwise the default pattern is used.
 func Scan(ctx *ParseContext, notesPaths ...string) ([]RawMatch, error) {
 	paths := expandGlobs(notesPaths)
@@ -92,10 +100,26 @@ func Scan(ctx *ParseContext, notesPaths ...string) ([]RawMatch, error) {
 	}
 
 	pattern := defaultScanPattern
-	if ctx != nil && ctx.scanPattern != "" {
-		pattern = ctx.scanPattern
+	backendName := ""
+	if ctx != nil {
+		if ctx.scanPattern != "" {
+			pattern = ctx.scanPattern
+		}
+		backendName = ctx.scanBackend
+	}
+
+	backend, err := resolveScanBackend(backendName)
+	if err != nil {
+		return nil, err
+	}
+	if backend == scanBackendGo {
+		return scanWalk(pattern, paths)
 	}
+	return scanRg(pattern, paths)
+}
 
+// scanRg runs rg --json over paths and collects the match messages.
+func scanRg(pattern string, paths []string) ([]RawMatch, error) {
 	args := []string{"--json", "-e", pattern}
 	args = append(args, paths...)
 	cmd := exec.Command("rg", args...)
@@ -158,93 +182,148 @@ func Scan(ctx *ParseContext, notesPaths ...string) ([]RawMatch, error) {
 // ScanProjects finds markdown files with "project" in frontmatter tags and a due date,
 // returning them as Task entries. goDateFmt is the Go time layout for parsing dates.
 func ScanProjects(goDateFmt string, fmCfg FrontmatterConfig, dateErrors *[]DateError, notesPaths ...string) ([]Task, error) {
+	return ScanProjectsWith("", goDateFmt, fmCfg, dateErrors, notesPaths...)
+}
+
+// ScanProjectsWith is ScanProjects with an explicit scan backend ("auto", "rg" or "go").
+func ScanProjectsWith(backendName, goDateFmt string, fmCfg FrontmatterConfig, dateErrors *[]DateError, notesPaths ...string) ([]Task, error) {
 	paths := expandGlobs(notesPaths)
 	if len(paths) == 0 {
 		return nil, nil
 	}
 
-	args := []string{"-l", "-e", "- project", "--glob", "*.md"}
-	args = append(args, paths...)
-	cmd := exec.Command("rg", args...)
-	out, err := cmd.Output()
+	backend, err := resolveScanBackend(backendName)
 	if err != nil {
-		var exitErr *exec.ExitError
-		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
-			return nil, nil // no matches
-		}
-		return nil, fmt.Errorf("rg project scan: %w", err)
+		return nil, err
+	}
+	var files []string
+	if backend == scanBackendGo {
+		files, err = listFilesWalk("- project", "*.md", paths)
+	} else {
+		files, err = listFilesRg("- project", "*.md", paths)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	var tasks []Task
-	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-		filePath := strings.TrimSpace(line)
-		if filePath == "" {
-			continue
-		}
-
+	for _, filePath := range files {
 		fm, err := ParseFrontmatter(filePath)
 		if err != nil || fm == nil {
 			continue
 		}
-
-		hasProject := false
-		for _, tag := range fm.Tags {
-			if tag == "project" {
-				hasProject = true
-				break
-			}
+		if t, ok := projectTask(filePath, fm, goDateFmt, fmCfg, dateErrors); ok {
+			tasks = append(tasks, t)
 		}
-		dueKey := fmCfg.DueKeyResolved()
-		statusKey := fmCfg.StatusKeyResolved()
-		doneValues := fmCfg.DoneValuesResolved()
+	}
 
-		fmDue := fm.GetString(dueKey)
-		if !hasProject || fmDue == "" {
-			continue
-		}
+	return tasks, nil
+}
 
-		fmStatus := strings.ToLower(fm.GetString(statusKey))
-		isDone := false
-		for _, dv := range doneValues {
-			if fmStatus == strings.ToLower(dv) {
-				isDone = true
-				break
-			}
+// projectTask builds the synthetic project task for a file whose frontmatter
+// has a "project" tag and a due date. It returns false for any other file,
+// including projects whose status marks them done.
+func projectTask(filePath string, fm *Frontmatter, goDateFmt string, fmCfg FrontmatterConfig, dateErrors *[]DateError) (Task, bool) {
+	hasProject := false
+	for _, tag := range fm.Tags {
+		if tag == "project" {
+			hasProject = true
+			break
 		}
-		if isDone {
-			continue
+	}
+	dueKey := fmCfg.DueKeyResolved()
+	statusKey := fmCfg.StatusKeyResolved()
+	doneValues := fmCfg.DoneValuesResolved()
+
+	fmDue := fm.GetString(dueKey)
+	if !hasProject || fmDue == "" {
+		return Task{}, false
+	}
+
+	fmStatus := strings.ToLower(fm.GetString(statusKey))
+	for _, dv := range doneValues {
+		if fmStatus == strings.ToLower(dv) {
+			return Task{}, false
 		}
+	}
 
-		var dueDate time.Time
-		var dueTime string
-		parts := strings.SplitN(fmDue, " ", 2)
-		dueDate, err = time.Parse(goDateFmt, parts[0])
-		if err != nil {
-			collectDateError(dateErrors, DateError{
-				FilePath: filePath,
-				DateStr:  parts[0],
-				Context:  "frontmatter project due",
-				Err:      err,
-			})
-			continue
+	var dueTime string
+	parts := strings.SplitN(fmDue, " ", 2)
+	dueDate, err := time.Parse(goDateFmt, parts[0])
+	if err != nil {
+		collectDateError(dateErrors, DateError{
+			FilePath: filePath,
+			DateStr:  parts[0],
+			Context:  "frontmatter project due",
+			Err:      err,
+		})
+		return Task{}, false
+	}
+	if len(parts) == 2 {
+		dueTime = strings.TrimSpace(parts[1])
+	}
+
+	body := strings.TrimSuffix(filepath.Base(filePath), ".md")
+
+	return Task{
+		FilePath:   filePath,
+		LineNumber: 1,
+		Body:       body,
+		DueDate:    &dueDate,
+		DueTime:    dueTime,
+		Tags:       fm.Tags,
+		Status:     "open",
+		SortLast:   true,
+	}, true
+}
+
+// listAllFilesRg lists the files rg would search under paths (`rg --files`),
+// honoring the same ignore files as scanRg.
+func listAllFilesRg(paths []string) ([]string, error) {
+	cmd := exec.Command("rg", append([]string{"--files"}, paths...)...)
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("rg (ripgrep) not found on PATH")
 		}
-		if len(parts) == 2 {
-			dueTime = strings.TrimSpace(parts[1])
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil // no files
 		}
+		return nil, fmt.Errorf("rg file listing: %w", err)
+	}
 
-		body := strings.TrimSuffix(filepath.Base(filePath), ".md")
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if filePath := strings.TrimSpace(line); filePath != "" {
+			files = append(files, filePath)
+		}
+	}
+	return files, nil
+}
 
-		tasks = append(tasks, Task{
-			FilePath:   filePath,
-			LineNumber: 1,
-			Body:       body,
-			DueDate:    &dueDate,
-			DueTime:    dueTime,
-			Tags:       fm.Tags,
-			Status:     "open",
-			SortLast:   true,
-		})
+// listFilesRg runs `rg -l` to list files under paths containing pattern.
+func listFilesRg(pattern, glob string, paths []string) ([]string, error) {
+	args := []string{"-l", "-e", pattern, "--glob", glob}
+	args = append(args, paths...)
+	cmd := exec.Command("rg", args...)
+	out, err := cmd.Output()
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, fmt.Errorf("rg (ripgrep) not found on PATH")
+		}
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+			return nil, nil // no matches
+		}
+		return nil, fmt.Errorf("rg project scan: %w", err)
 	}
 
-	return tasks, nil
+	var files []string
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		if filePath := strings.TrimSpace(line); filePath != "" {
+			files = append(files, filePath)
+		}
+	}
+	return files, nil
 }
@@ -0,0 +1,445 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FormatMarker renders a marker stamped with now, using the configured marker
+// prefix and date/time formats. The trailing space separates it from text
+// appended later.
+func FormatMarker(kind string, now time.Time, ctx *ParseContext) string {
+	return fmt.Sprintf("%s%s [[%s]] %s ", ctx.markerPrefix, kind, now.Format(ctx.formats.GoDate), now.Format(ctx.formats.GoTime))
+}
+
+// formatMarkerText renders a parsed marker back to text.
+func formatMarkerText(m Marker, ctx *ParseContext) string {
+	s := ctx.markerPrefix + m.Kind + " [[" + m.Date + "]]"
+	if m.Time != "" {
+		s += " " + m.Time
+	}
+	return s
+}
+
+var (
+	tagNameRe      = regexp.MustCompile(`^[A-Za-z_][\w-]*$`)
+	durationTextRe = regexp.MustCompile(`^(\d+)m$`)
+	blockIDTextRe  = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+)
+
+// SerializeTask writes t back to a task line. The existing line is patched in
+// place: only the parts of the task that differ from what line holds are
+// rewritten, so indentation, wikilink paths in the date group, the order of
+// tags and any text taskbuffer does not understand are kept. An empty line
+// builds a new one. Checkboxes, the date wrapper, date and time formats and
+// the tag and marker prefixes come from ctx.
+//
+// The result is parsed again and must yield t, so a task that cannot be
+// written faithfully (say, a body containing a tag) is an error rather than a
+// silently different line.
+func SerializeTask(line string, t Task, ctx *ParseContext) (string, error) {
+	if _, ok := ctx.checkbox[t.Status]; !ok {
+		return "", fmt.Errorf("no checkbox configured for status %q", t.Status)
+	}
+	if t.Recurrence != "" {
+		rec, err := normalizeRecurrence(t.Recurrence)
+		if err != nil {
+			return "", err
+		}
+		t.Recurrence = rec
+	}
+	if line == "" {
+		line = ctx.checkbox[t.Status] + " " + t.Body
+	}
+	line = strings.TrimRight(line, "\r\n")
+	trimmed := strings.TrimLeft(line, " \t")
+	indent := line[:len(line)-len(trimmed)]
+
+	old, err := ParseTask(RawMatch{Text: trimmed}, ctx)
+	if err != nil {
+		return "", err
+	}
+	s := &lineEditor{ctx: ctx, out: trimmed}
+	steps := []func(old, t Task) error{
+		s.setStatus, s.setBody, s.setDuration, s.setPriority, s.setTags, s.setDue, s.setMarkers, s.setRecurrence, s.setID,
+	}
+	for _, step := range steps {
+		if err := step(old, t); err != nil {
+			return "", err
+		}
+	}
+	out := indent + s.out
+
+	got, err := ParseTask(RawMatch{Text: out}, ctx)
+	if err != nil {
+		return "", fmt.Errorf("task line would not parse back: %w", err)
+	}
+	if field := taskFieldMismatch(got, t); field != "" {
+		return "", fmt.Errorf("task %s cannot be written to the line faithfully", field)
+	}
+	return out, nil
+}
+
+// taskFieldMismatch names the first field SerializeTask writes that differs
+// between a and b, or returns "".
+func taskFieldMismatch(a, b Task) string {
+	sameDate := (a.DueDate == nil) == (b.DueDate == nil) && (a.DueDate == nil || a.DueDate.Equal(*b.DueDate))
+	switch {
+	case a.Status != b.Status:
+		return "status"
+	case a.Body != b.Body:
+		return "body"
+	case !sameDate:
+		return "due date"
+	case a.DueTime != b.DueTime:
+		return "due time"
+	case a.Duration != b.Duration:
+		return "duration"
+	case a.Priority != b.Priority:
+		return "priority"
+	case !sameStringSet(a.Tags, b.Tags):
+		return "tags"
+	case len(a.Markers) != len(b.Markers) || len(a.Markers) > 0 && !reflect.DeepEqual(a.Markers, b.Markers):
+		return "markers"
+	case a.Recurrence != b.Recurrence:
+		return "recurrence"
+	case a.ID != b.ID:
+		return "block ID"
+	}
+	return ""
+}
+
+func sameStringSet(a, b []string) bool {
+	set := func(ss []string) []string {
+		seen := make(map[string]bool)
+		var out []string
+		for _, s := range ss {
+			if !seen[s] {
+				seen[s] = true
+				out = append(out, s)
+			}
+		}
+		sort.Strings(out)
+		return out
+	}
+	return strings.Join(set(a), "\x00") == strings.Join(set(b), "\x00")
+}
+
+// lineEditor applies SerializeTask's changes to a task line (without its
+// indentation), one part at a time. Positions are recomputed for every edit.
+type lineEditor struct {
+	ctx *ParseContext
+	out string
+}
+
+// headRange returns the span between the checkbox and the date group (or the
+// first marker, for undated tasks): the part ParseTask takes the body from.
+func (e *lineEditor) headRange() (int, int) {
+	start := e.ctx.statusRe.FindStringIndex(e.out)[1]
+	end := len(e.out)
+	if loc := e.ctx.dateRe.FindStringIndex(e.out); loc != nil {
+		end = loc[0]
+	} else if loc := e.ctx.markerStartRe.FindStringIndex(e.out); loc != nil {
+		end = loc[0]
+	}
+	return start, end
+}
+
+// cut removes out[start:end], leaving a single space between the remaining
+// text on either side.
+func (e *lineEditor) cut(start, end int) {
+	left := strings.TrimRight(e.out[:start], " \t")
+	right := strings.TrimLeft(e.out[end:], " \t")
+	if right == "" {
+		e.out = left
+		return
+	}
+	e.out = left + " " + right
+}
+
+// insertInHead adds text at the end of the head, before the date group or
+// markers.
+func (e *lineEditor) insertInHead(text string) {
+	_, end := e.headRange()
+	left := strings.TrimRight(e.out[:end], " \t")
+	right := e.out[end:]
+	if right == "" {
+		e.out = left + " " + text
+		return
+	}
+	e.out = left + " " + text + " " + right
+}
+
+func (e *lineEditor) setStatus(old, t Task) error {
+	if t.Status == old.Status {
+		return nil
+	}
+	loc := e.ctx.statusRe.FindStringSubmatchIndex(e.out)
+	e.out = e.out[:loc[2]] + e.ctx.checkbox[t.Status] + e.out[loc[3]:]
+	return nil
+}
+
+// setBody replaces the body text in the head, keeping the duration, priority, tags,
+// inline recurrence and block ID found there. When the body is one run of
+// text between those tokens it is replaced where it stands; a body split up
+// by tokens is written as one run, followed by the tokens in their order.
+func (e *lineEditor) setBody(old, t Task) error {
+	if t.Body == old.Body {
+		return nil
+	}
+	start, end := e.headRange()
+	head := e.out[start:end]
+
+	type token struct {
+		pos, end int
+		text     string
+	}
+	var keep []token
+	collect := func(re *regexp.Regexp) {
+		for _, loc := range re.FindAllStringIndex(head, -1) {
+			keep = append(keep, token{loc[0], loc[1], strings.TrimSpace(head[loc[0]:loc[1]])})
+		}
+	}
+	collect(e.ctx.durationRe)
+	if e.ctx.priorityRe != nil {
+		collect(e.ctx.priorityRe)
+	}
+	collect(e.ctx.tagRe)
+	collect(blockIDRe)
+	if old.Recurrence != "" && !e.ctx.recurMarkerRe.MatchString(e.out) {
+		collect(recurWordRe)
+	}
+	sort.SliceStable(keep, func(i, j int) bool { return keep[i].pos < keep[j].pos })
+
+	// The text between the tokens; a single run is the body itself
+	var runs [][2]int
+	from := 0
+	for _, k := range append(keep, token{pos: len(head), end: len(head)}) {
+		if k.pos > from && strings.TrimSpace(head[from:k.pos]) != "" {
+			runs = append(runs, [2]int{from, k.pos})
+		}
+		if k.end > from {
+			from = k.end
+		}
+	}
+	if len(runs) == 1 {
+		run := head[runs[0][0]:runs[0][1]]
+		lead := len(run) - len(strings.TrimLeft(run, " \t"))
+		trail := len(strings.TrimRight(run, " \t"))
+		at := start + runs[0][0]
+		e.out = e.out[:at+lead] + t.Body + e.out[at+trail:]
+		return nil
+	}
+
+	parts := []string{t.Body}
+	for _, k := range keep {
+		parts = append(parts, k.text)
+	}
+	newHead := " " + strings.Join(parts, " ")
+	if end < len(e.out) {
+		newHead += " "
+	}
+	e.out = e.out[:start] + newHead + e.out[end:]
+	return nil
+}
+
+func (e *lineEditor) setDuration(old, t Task) error {
+	if t.Duration == old.Duration {
+		return nil
+	}
+	loc := e.ctx.durationRe.FindStringSubmatchIndex(e.out)
+	if t.Duration == "" {
+		if loc != nil {
+			e.cut(loc[0], loc[1])
+		}
+		return nil
+	}
+	m := durationTextRe.FindStringSubmatch(t.Duration)
+	if m == nil {
+		return fmt.Errorf("invalid duration %q (want minutes, like 30m)", t.Duration)
+	}
+	if loc != nil {
+		e.out = e.out[:loc[2]] + m[1] + e.out[loc[3]:]
+	} else {
+		e.insertInHead("<" + m[1] + "m>")
+	}
+	return nil
+}
+
+// setPriority rewrites the priority token in the syntax it is already written
+// in, or adds one in the configured syntax.
+func (e *lineEditor) setPriority(old, t Task) error {
+	if t.Priority == old.Priority {
+		return nil
+	}
+	if t.Priority < 0 || t.Priority > priorityLow {
+		return fmt.Errorf("invalid priority %d", t.Priority)
+	}
+	if e.ctx.priorityRe == nil {
+		return fmt.Errorf("priorities are disabled (priority_syntax is none)")
+	}
+	loc := e.ctx.priorityRe.FindStringSubmatchIndex(e.out)
+	switch {
+	case loc != nil && t.Priority == 0:
+		e.cut(loc[2], loc[3])
+	case loc != nil:
+		token := priorityToken(t.Priority, prioritySyntaxOf(e.out[loc[2]:loc[3]]))
+		e.out = e.out[:loc[2]] + token + e.out[loc[3]:]
+	case t.Priority != 0:
+		e.insertInHead(priorityToken(t.Priority, e.ctx.prioritySyn))
+	}
+	return nil
+}
+
+func (e *lineEditor) setTags(old, t Task) error {
+	want := make(map[string]bool, len(t.Tags))
+	for _, tag := range t.Tags {
+		want[tag] = true
+	}
+	have := make(map[string]bool, len(old.Tags))
+	for _, tag := range old.Tags {
+		have[tag] = true
+	}
+
+	// Remove from the end so earlier positions stay valid.
+	locs := e.ctx.tagRe.FindAllStringSubmatchIndex(e.out, -1)
+	for i := len(locs) - 1; i >= 0; i-- {
+		if name := e.out[locs[i][2]:locs[i][3]]; !want[name] {
+			e.cut(locs[i][0], locs[i][1])
+		}
+	}
+	for _, tag := range t.Tags {
+		if have[tag] {
+			continue
+		}
+		if !tagNameRe.MatchString(tag) {
+			return fmt.Errorf("invalid tag %q", tag)
+		}
+		have[tag] = true
+		e.insertTag(e.ctx.tagPrefix + tag)
+	}
+	return nil
+}
+
+// insertTag adds a tag after the last tag on the line, or at the end of the
+// head if there is none.
+func (e *lineEditor) insertTag(text string) {
+	locs := e.ctx.tagRe.FindAllStringIndex(e.out, -1)
+	if len(locs) == 0 {
+		e.insertInHead(text)
+		return
+	}
+	at := locs[len(locs)-1][1]
+	e.out = e.out[:at] + " " + text + e.out[at:]
+}
+
+func (e *lineEditor) setDue(old, t Task) error {
+	if t.DueTime != "" && t.DueDate == nil {
+		return fmt.Errorf("a due time needs a due date")
+	}
+	sameDate := (old.DueDate == nil) == (t.DueDate == nil) && (t.DueDate == nil || old.DueDate.Equal(*t.DueDate))
+	if sameDate && t.DueTime == old.DueTime {
+		return nil
+	}
+
+	loc := e.ctx.dateRe.FindStringSubmatchIndex(e.out)
+	if loc == nil {
+		if t.DueDate != nil {
+			group := e.ctx.dateWrap[0] + t.DueDate.Format(e.ctx.formats.GoDate) + e.ctx.dateWrap[1]
+			if t.DueTime != "" {
+				group += " " + t.DueTime
+			}
+			e.insertInHead(group + e.ctx.dateWrap[2])
+		}
+		return nil
+	}
+	if t.DueDate == nil {
+		e.cut(loc[0], loc[1])
+		return nil
+	}
+
+	// Time first: it sits after the date, so the date's position holds.
+	switch {
+	case loc[4] >= 0 && t.DueTime == "":
+		start := loc[4]
+		for start > loc[3] && (e.out[start-1] == ' ' || e.out[start-1] == '\t') {
+			start--
+		}
+		e.out = e.out[:start] + e.out[loc[5]:]
+	case loc[4] >= 0:
+		e.out = e.out[:loc[4]] + t.DueTime + e.out[loc[5]:]
+	case t.DueTime != "":
+		at := loc[1] - len(e.ctx.dateWrap[2])
+		e.out = e.out[:at] + " " + t.DueTime + e.out[at:]
+	}
+	if !sameDate {
+		e.out = e.out[:loc[2]] + t.DueDate.Format(e.ctx.formats.GoDate) + e.out[loc[3]:]
+	}
+	return nil
+}
+
+func (e *lineEditor) setMarkers(old, t Task) error {
+	if len(old.Markers) == len(t.Markers) && (len(t.Markers) == 0 || reflect.DeepEqual(old.Markers, t.Markers)) {
+		return nil
+	}
+	add := t.Markers
+	if len(t.Markers) > len(old.Markers) && reflect.DeepEqual(old.Markers, t.Markers[:len(old.Markers)]) {
+		add = t.Markers[len(old.Markers):]
+	} else {
+		e.out = strings.TrimRight(e.ctx.datedMarkerRe.ReplaceAllString(e.out, ""), " \t")
+	}
+	for _, m := range add {
+		e.out = appendToTaskLine(e.out, formatMarkerText(m, e.ctx))
+	}
+	return nil
+}
+
+func (e *lineEditor) setRecurrence(old, t Task) error {
+	if t.Recurrence == old.Recurrence {
+		return nil
+	}
+	rec := t.Recurrence
+	if loc := e.ctx.recurMarkerRe.FindStringSubmatchIndex(e.out); loc != nil {
+		if rec == "" {
+			e.cut(loc[0], loc[1])
+		} else {
+			e.out = e.out[:loc[2]] + rec + e.out[loc[3]:]
+		}
+		return nil
+	}
+	if old.Recurrence != "" {
+		start, end := e.headRange()
+		if loc := recurWordRe.FindStringSubmatchIndex(e.out[start:end]); loc != nil {
+			if rec == "" {
+				e.cut(start+loc[0], start+loc[1])
+			} else {
+				e.out = e.out[:start+loc[2]] + rec + e.out[start+loc[3]:]
+			}
+			return nil
+		}
+	}
+	if rec != "" {
+		e.out = appendToTaskLine(e.out, e.ctx.markerPrefix+"every [["+rec+"]]")
+	}
+	return nil
+}
+
+func (e *lineEditor) setID(old, t Task) error {
+	if t.ID == old.ID {
+		return nil
+	}
+	if loc := blockIDRe.FindStringSubmatchIndex(e.out); loc != nil {
+		e.cut(loc[2]-1, loc[3]) // include the caret
+	}
+	if t.ID != "" {
+		if !blockIDTextRe.MatchString(t.ID) {
+			return fmt.Errorf("invalid block ID %q", t.ID)
+		}
+		e.out = strings.TrimRight(e.out, " \t") + " ^" + t.ID
+	}
+	return nil
+}
@@ -0,0 +1,279 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// serializeWith parses line, applies change and serializes the result.
+func serializeWith(t *testing.T, ctx *ParseContext, line string, change func(*Task)) (string, error) {
+	t.Helper()
+	task, err := ParseTask(RawMatch{Text: line}, ctx)
+	if err != nil {
+		t.Fatalf("parse %q: %v", line, err)
+	}
+	change(&task)
+	return SerializeTask(line, task, ctx)
+}
+
+func TestSerializeTask_Unchanged(t *testing.T) {
+	custom := NewParseContext(Config{
+		DateFormat:   "%d.%m.%Y",
+		TimeFormat:   "%I:%M %p",
+		DateWrapper:  []string{"<", ">"},
+		TagPrefix:    "+",
+		MarkerPrefix: ">>",
+		Checkbox:     map[string]string{"open": "* [ ]", "done": "* [x]"},
+	})
+	cases := []struct {
+		ctx  *ParseContext
+		line string
+	}{
+		{defaultCtx, "- [ ] Write report <30m> #work (@[[2026-02-17]] 15:00) ::start [[2026-02-17]] 15:17 "},
+		{defaultCtx, "\t- [x] Indented   spacing kept #a #b (@[[daily/2026-02-17|Tue]]) trailing text"},
+		{defaultCtx, "- [ ] Water plants every week ^tb-0001"},
+		{defaultCtx, "- [-] Dropped ::irrelevant [[2026-02-17]] 10:00"},
+		{custom, "  * [ ] Meeting +work <17.02.2026 01:00 PM> >>start [[17.02.2026]] 01:05 PM"},
+	}
+	for _, c := range cases {
+		got, err := serializeWith(t, c.ctx, c.line, func(*Task) {})
+		if err != nil {
+			t.Errorf("%q: %v", c.line, err)
+			continue
+		}
+		if got != c.line {
+			t.Errorf("unchanged task rewritten:\n got %q\nwant %q", got, c.line)
+		}
+	}
+}
+
+func TestSerializeTask_Changes(t *testing.T) {
+	cases := []struct {
+		name   string
+		line   string
+		change func(*Task)
+		want   string
+	}{
+		{"move date keeps wikilink path",
+			"- [ ] Task (@[[daily/2026-02-17]]) ::start [[2026-02-17]] 09:00",
+			func(t *Task) { t.DueDate = mustDatePtr("2026-02-20") },
+			"- [ ] Task (@[[daily/2026-02-20]]) ::start [[2026-02-17]] 09:00"},
+		{"add time",
+			"- [ ] Task (@[[2026-02-17]])",
+			func(t *Task) { t.DueTime = "09:30" },
+			"- [ ] Task (@[[2026-02-17]] 09:30)"},
+		{"change time",
+			"- [ ] Task (@[[2026-02-17]] 09:30)",
+			func(t *Task) { t.DueTime = "14:00" },
+			"- [ ] Task (@[[2026-02-17]] 14:00)"},
+		{"remove time",
+			"- [ ] Task (@[[2026-02-17]] 09:30) #x",
+			func(t *Task) { t.DueTime = "" },
+			"- [ ] Task (@[[2026-02-17]]) #x"},
+		{"remove date",
+			"- [ ] Task (@[[2026-02-17]] 09:30) ::start [[2026-02-17]] 09:00",
+			func(t *Task) { t.DueDate, t.DueTime = nil, "" },
+			"- [ ] Task ::start [[2026-02-17]] 09:00"},
+		{"add date before markers",
+			"- [ ] Task #work ::start [[2026-02-17]] 09:00 ^tb-0001",
+			func(t *Task) { t.DueDate = mustDatePtr("2026-02-18"); t.DueTime = "08:00" },
+			"- [ ] Task #work (@[[2026-02-18]] 08:00) ::start [[2026-02-17]] 09:00 ^tb-0001"},
+		{"add duration",
+			"- [ ] Task #work (@[[2026-02-17]])",
+			func(t *Task) { t.Duration = "45m" },
+			"- [ ] Task #work <45m> (@[[2026-02-17]])"},
+		{"change and remove duration",
+			"- [ ] Task <30m> #work",
+			func(t *Task) { t.Duration = "" },
+			"- [ ] Task #work"},
+		{"tags",
+			"- [ ] Task #a #b (@[[2026-02-17]]) #c",
+			func(t *Task) { t.Tags = []string{"a", "c", "d"} },
+			"- [ ] Task #a (@[[2026-02-17]]) #c #d"},
+		{"tag added after the last tag",
+			"- [ ] Task #a (@[[2026-02-17]]) ::start [[2026-02-17]] 09:00",
+			func(t *Task) { t.Tags = []string{"a", "b"} },
+			"- [ ] Task #a #b (@[[2026-02-17]]) ::start [[2026-02-17]] 09:00"},
+		{"tag added to an untagged task",
+			"- [ ] Task (@[[2026-02-17]])",
+			func(t *Task) { t.Tags = []string{"b"} },
+			"- [ ] Task #b (@[[2026-02-17]])"},
+		{"body keeps tokens",
+			"- [ ] Old text <30m> #work every week (@[[2026-02-17]]) ^tb-0001",
+			func(t *Task) { t.Body = "New text" },
+			"- [ ] New text <30m> #work every week (@[[2026-02-17]]) ^tb-0001"},
+		{"body replaced where it stands",
+			"- [ ] !! #work  Old text   (@[[2026-02-17]])",
+			func(t *Task) { t.Body = "New text" },
+			"- [ ] !! #work  New text   (@[[2026-02-17]])"},
+		{"body split by tokens",
+			"- [ ] Call #phone Bob (@[[2026-02-17]])",
+			func(t *Task) { t.Body = "Call Alice" },
+			"- [ ] Call Alice #phone (@[[2026-02-17]])"},
+		{"body of undated task",
+			"- [ ] Old ::start [[2026-02-17]] 09:00",
+			func(t *Task) { t.Body = "New" },
+			"- [ ] New ::start [[2026-02-17]] 09:00"},
+		{"status",
+			"  - [ ] Task",
+			func(t *Task) { t.Status = "done" },
+			"  - [x] Task"},
+		{"append marker before ID",
+			"- [ ] Task ::start [[2026-02-17]] 09:00 ^tb-0001",
+			func(t *Task) {
+				t.Markers = append(t.Markers, Marker{Kind: "stop", Date: "2026-02-17", Time: "10:00"})
+			},
+			"- [ ] Task ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 10:00 ^tb-0001"},
+		{"recurrence marker",
+			"- [ ] Task (@[[2026-02-17]]) ::every [[1w]]",
+			func(t *Task) { t.Recurrence = "2 weeks" },
+			"- [ ] Task (@[[2026-02-17]]) ::every [[2w]]"},
+		{"recurrence token removed",
+			"- [ ] Water plants every monday (@[[2026-02-17]])",
+			func(t *Task) { t.Recurrence = "" },
+			"- [ ] Water plants (@[[2026-02-17]])"},
+		{"recurrence added",
+			"- [ ] Task (@[[2026-02-17]]) ^tb-0001",
+			func(t *Task) { t.Recurrence = "1m" },
+			"- [ ] Task (@[[2026-02-17]]) ::every [[1m]] ^tb-0001"},
+		{"block ID",
+			"- [ ] Task ^tb-0001 (@[[2026-02-17]])",
+			func(t *Task) { t.ID = "tb-beef" },
+			"- [ ] Task (@[[2026-02-17]]) ^tb-beef"},
+	}
+	for _, c := range cases {
+		got, err := serializeWith(t, defaultCtx, c.line, c.change)
+		if err != nil {
+			t.Errorf("%s: %v", c.name, err)
+			continue
+		}
+		if got != c.want {
+			t.Errorf("%s:\n got %q\nwant %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestSerializeTask_ConfiguredFormats(t *testing.T) {
+	ctx := NewParseContext(Config{
+		DateFormat:   "%d.%m.%Y",
+		TimeFormat:   "%I:%M %p",
+		DateWrapper:  []string{"<", ">"},
+		TagPrefix:    "+",
+		MarkerPrefix: ">>",
+		Checkbox:     map[string]string{"open": "* [ ]", "done": "* [x]"},
+	})
+	task := Task{
+		Body:       "Meeting",
+		Status:     "open",
+		DueDate:    mustDatePtr("2026-03-04"),
+		DueTime:    "01:00 PM",
+		Duration:   "60m",
+		Tags:       []string{"work"},
+		Markers:    []Marker{{Kind: "start", Date: "04.03.2026", Time: "12:55 PM"}},
+		Recurrence: "1w",
+		ID:         "tb-0001",
+	}
+	got, err := SerializeTask("", task, ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "* [ ] Meeting <60m> +work <04.03.2026 01:00 PM> >>start [[04.03.2026]] 12:55 PM >>every [[1w]] ^tb-0001"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	if m := FormatMarker("stop", time.Date(2026, 3, 4, 14, 5, 0, 0, time.Local), ctx); m != ">>stop [[04.03.2026]] 2:05 PM " {
+		t.Errorf("FormatMarker = %q", m)
+	}
+}
+
+func TestSerializeTask_Rejects(t *testing.T) {
+	cases := []struct {
+		name   string
+		change func(*Task)
+	}{
+		{"body with a tag", func(t *Task) { t.Body = "Talk to #bob" }},
+		{"time without date", func(t *Task) { t.DueDate = nil; t.DueTime = "09:00" }},
+		{"bad tag", func(t *Task) { t.Tags = append(t.Tags, "no spaces") }},
+		{"bad duration", func(t *Task) { t.Duration = "1h" }},
+		{"unknown status", func(t *Task) { t.Status = "waiting" }},
+	}
+	for _, c := range cases {
+		if got, err := serializeWith(t, defaultCtx, "- [ ] Task (@[[2026-02-17]])", c.change); err == nil {
+			t.Errorf("%s: expected error, got %q", c.name, got)
+		}
+	}
+}
+
+func TestCmdEdit_KeepsLayout(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("\t- [ ] !! Draft  talk (@[[daily/2026-02-17]] 09:00) #conf ::start [[2026-02-16]] 10:00 ^tb-0001\n"), 0644)
+
+	err := cmdEdit(defaultCtx, []string{path, "1", "--body", "Rehearse talk", "--due", "2026-02-19", "--time", "14:00",
+		"--add-tag", "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "\t- [ ] !! Rehearse talk (@[[daily/2026-02-19]] 14:00) #conf #work ::start [[2026-02-16]] 10:00 ^tb-0001"
+	if got := splitLines(readFile(t, path))[0]; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+}
+
+func TestCmdEdit(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("# Notes\n  - [ ] Call bob #phone (@[[2026-02-17]]) ::start [[2026-02-17]] 09:00\n"), 0644)
+
+	err := cmdEdit(defaultCtx, []string{path, "2", "--due", "2026-02-20", "--time", "3:30pm", "--duration", "1h",
+		"--add-tag", "#work", "--remove-tag", "phone", "--body", "Call Bob back"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "  - [ ] Call Bob back <60m> #work (@[[2026-02-20]] 15:30) ::start [[2026-02-17]] 09:00"
+	if got := splitLines(readFile(t, path))[1]; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	if err := cmdEdit(defaultCtx, []string{path, "2", "--due", "none", "--duration", "none"}); err != nil {
+		t.Fatal(err)
+	}
+	want = "  - [ ] Call Bob back #work ::start [[2026-02-17]] 09:00"
+	if got := splitLines(readFile(t, path))[1]; got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	// Offsets count from the due date, or from today for an undated task
+	os.WriteFile(path, []byte("- [ ] Later (@[[2099-01-10]])\n- [ ] Undated\n"), 0644)
+	if err := cmdEdit(defaultCtx, []string{path, "1", "--due", "+1w"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdEdit(defaultCtx, []string{path, "2", "--due", "+1d"}); err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := time.Now().AddDate(0, 0, 1).Format("2006-01-02")
+	want = "- [ ] Later (@[[2099-01-17]])\n- [ ] Undated (@[[" + tomorrow + "]])\n"
+	if got := readFile(t, path); got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+
+	os.WriteFile(path, []byte("# Notes\n  - [ ] Call Bob back #work ::start [[2026-02-17]] 09:00\n"), 0644)
+	for _, args := range [][]string{
+		{path, "2"},                       // nothing to change
+		{path, "2", "--time", "09:00"},    // no due date
+		{path, "1", "--body", "x"},        // not a task
+		{path, "2", "--duration", "90s"},  // not whole minutes
+		{path, "2", "--add-tag", "a b"},   // invalid tag
+		{path, "2", "--due", "someday"},   // invalid date
+		{path, "2", "--body", "Ping #ab"}, // would not round-trip
+	} {
+		before := readFile(t, path)
+		if err := cmdEdit(defaultCtx, args); err == nil {
+			t.Errorf("%v: expected error", args[2:])
+		}
+		if readFile(t, path) != before {
+			t.Errorf("%v: file changed despite the error", args[2:])
+		}
+	}
+}
@@ -0,0 +1,350 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+	rpcTaskAmbiguous  = -32001 // --expect matched several lines (exit code 3)
+	rpcTaskNotFound   = -32002 // --expect matched no line (exit code 4)
+)
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// serveParams is the union of parameters accepted by the server methods.
+// Each method reads only the fields it needs.
+type serveParams struct {
+	File          string   `json:"file"`
+	Line          int      `json:"line"`
+	ID            string   `json:"id"`     // block ID, instead of line (and file)
+	Expect        string   `json:"expect"` // task fingerprint, see TaskFingerprint
+	Body          string   `json:"body"`
+	Header        string   `json:"header"`
+	InboxFile     string   `json:"inbox_file"`
+	InboxHeader   string   `json:"inbox_header"`
+	Tags          []string `json:"tags"`
+	Query         string   `json:"query"`
+	Markers       bool     `json:"markers"`
+	IgnoreUndated bool     `json:"ignore_undated"`
+	Format        string   `json:"format"` // list output: taskfile (default), json or ndjson
+	Args          []string `json:"args"`   // extra flags, as accepted by the matching CLI subcommand
+}
+
+// currentTaskResult is the JSON shape of a CurrentTask in server responses.
+type currentTaskResult struct {
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Line      int    `json:"line"`
+	StartTime int64  `json:"start_time"`
+	ID        string `json:"id,omitempty"`
+}
+
+func currentTaskJSON(ct *CurrentTask) interface{} {
+	if ct == nil {
+		return nil
+	}
+	return currentTaskResult{Name: ct.Name, File: ct.FilePath, Line: ct.LineNumber, StartTime: ct.StartTime, ID: ct.ID}
+}
+
+// server holds the state kept warm between requests.
+type server struct {
+	notesPaths []string
+	ctx        *ParseContext
+	cfg        Config
+	done       bool // set by the "shutdown" method
+}
+
+// errInvalidParams marks errors that should be reported as rpcInvalidParams.
+var errInvalidParams = errors.New("invalid params")
+
+// taskArgs validates the file/line or id params and renders them as CLI
+// arguments ("<file> <line>" or "<file> ^id", plus --expect). An id without a
+// file is looked up in the vault.
+func (s *server) taskArgs(p serveParams) ([]string, error) {
+	if p.Expect != "" {
+		p.Args = append([]string{"--expect", p.Expect}, p.Args...)
+	}
+	if p.ID != "" {
+		if p.File == "" {
+			return expandIDArgs(s.notesPaths, s.ctx, s.cfg, append([]string{"^" + p.ID}, p.Args...))
+		}
+		return append([]string{p.File, "^" + p.ID}, p.Args...), nil
+	}
+	if p.File == "" || p.Line < 1 {
+		return nil, fmt.Errorf("%w: file and line (or id) are required", errInvalidParams)
+	}
+	return append([]string{p.File, strconv.Itoa(p.Line)}, p.Args...), nil
+}
+
+// invalidate forgets cached data for a file the server just modified, so the
+// next request re-reads it even if the edit kept its size and mtime.
+func (s *server) invalidate(filePath string) {
+	InvalidateFrontmatter(filePath)
+	if warmIndex != nil {
+		warmIndex.Invalidate(filePath)
+	}
+}
+
+// call dispatches a single method and returns its result.
+func (s *server) call(method string, p serveParams) (interface{}, error) {
+	switch method {
+	case "list":
+		var args []string
+		for _, t := range p.Tags {
+			args = append(args, "--tag", t)
+		}
+		if p.Query != "" {
+			args = append(args, "--query", p.Query)
+		}
+		if p.Markers {
+			args = append(args, "--markers")
+		}
+		if p.IgnoreUndated {
+			args = append(args, "--ignore-undated")
+		}
+		if p.Format == "" {
+			p.Format = "taskfile"
+		}
+		if p.Format == "ndjson" {
+			// One response is one JSON value, so ndjson is sent as the array
+			p.Format = "json"
+		}
+		args = append(append(args, "--format", p.Format), p.Args...)
+		out, err := renderList(s.notesPaths, s.ctx, args, s.cfg)
+		if err != nil || p.Format != "json" {
+			return out, err
+		}
+		return json.RawMessage(out), nil
+
+	case "tags":
+		var args []string
+		if p.Query != "" {
+			args = append(args, "--query", p.Query)
+		}
+		tags, err := collectTags(s.notesPaths, s.ctx, append(args, p.Args...), s.cfg)
+		if tags == nil {
+			tags = []string{}
+		}
+		return tags, err
+
+	case "current":
+		ct, err := ReadCurrentTaskFrom(s.cfg.StateDir)
+		return currentTaskJSON(ct), err
+
+	case "start":
+		args, err := s.taskArgs(p)
+		if err != nil {
+			return nil, err
+		}
+		task, stopped, err := startAtLocation(s.ctx, s.cfg, args)
+		if stopped != nil {
+			s.invalidate(stopped.FilePath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.invalidate(task.FilePath)
+		ct, err := ReadCurrentTaskFrom(s.cfg.StateDir)
+		return currentTaskJSON(ct), err
+
+	case "resume":
+		at, err := parseAtFlag(method, p.Args, s.ctx, time.Now().In(time.Local))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidParams, err)
+		}
+		task, stopped, err := resumeTask(s.ctx, s.cfg, at)
+		if stopped != nil {
+			s.invalidate(stopped.FilePath)
+		}
+		if err != nil || task == nil {
+			return nil, err
+		}
+		s.invalidate(task.FilePath)
+		ct, err := ReadCurrentTaskFrom(s.cfg.StateDir)
+		return currentTaskJSON(ct), err
+
+	case "recent":
+		st, err := ReadStateFrom(s.cfg.StateDir)
+		if err != nil {
+			return nil, err
+		}
+		return recentEntries(st), nil
+
+	case "stop", "complete":
+		stop := stopCurrentTaskAt
+		if method == "complete" {
+			stop = completeCurrentTaskAt
+		}
+		at, err := parseAtFlag(method, p.Args, s.ctx, time.Now().In(time.Local))
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", errInvalidParams, err)
+		}
+		ct, err := stop(s.cfg, at)
+		if err != nil {
+			return nil, err
+		}
+		if ct != nil {
+			s.invalidate(ct.FilePath)
+		}
+		return currentTaskJSON(ct), nil
+
+	case "defer", "check", "irrelevant", "unset", "complete-at", "edit":
+		args, err := s.taskArgs(p)
+		if err != nil {
+			return nil, err
+		}
+		mutate := map[string]func(*ParseContext, []string) error{
+			"defer":       cmdDefer,
+			"edit":        cmdEdit,
+			"check":       cmdCheck,
+			"irrelevant":  cmdIrrelevant,
+			"unset":       cmdUnset,
+			"complete-at": cmdCompleteAt,
+		}[method]
+		if err := mutate(s.ctx, args); err != nil {
+			return nil, err
+		}
+		s.invalidate(args[0])
+		return true, nil
+
+	case "create":
+		if p.Body == "" {
+			return nil, fmt.Errorf("%w: body is required", errInvalidParams)
+		}
+		args := []string{"--file", p.File, "--header", p.Header, "--inbox-file", p.InboxFile, "--inbox-header", p.InboxHeader}
+		args = append(args, p.Args...)
+		args = append(args, p.Body)
+		if err := cmdCreate(s.ctx, args); err != nil {
+			return nil, err
+		}
+		target := p.File
+		if target == "" {
+			target = p.InboxFile
+		}
+		s.invalidate(expandHome(target))
+		return true, nil
+
+	case "shutdown":
+		s.done = true
+		return nil, nil
+	}
+	return nil, errMethodNotFound
+}
+
+var errMethodNotFound = errors.New("method not found")
+
+// handle decodes one request line and produces its response. It returns nil
+// for notifications (requests without an id), which get no reply.
+func (s *server) handle(line []byte) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return &rpcResponse{JSONRPC: "2.0", ID: json.RawMessage("null"), Error: &rpcError{Code: rpcParseError, Message: err.Error()}}
+	}
+	id := req.ID
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	if req.Method == "" {
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: rpcInvalidRequest, Message: "missing method"}}
+	}
+
+	var p serveParams
+	if len(req.Params) > 0 && string(req.Params) != "null" {
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: rpcInvalidParams, Message: err.Error()}}
+		}
+	}
+
+	result, err := s.call(req.Method, p)
+	if len(req.ID) == 0 {
+		return nil
+	}
+	if err != nil {
+		code := rpcServerError
+		switch {
+		case errors.Is(err, errMethodNotFound):
+			code = rpcMethodNotFound
+			err = fmt.Errorf("method not found: %s", req.Method)
+		case errors.Is(err, errInvalidParams):
+			code = rpcInvalidParams
+		case errors.Is(err, errTaskAmbiguous):
+			code = rpcTaskAmbiguous
+		case errors.Is(err, errTaskNotFound):
+			code = rpcTaskNotFound
+		}
+		return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: err.Error()}}
+	}
+	if result == nil {
+		result = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Result: result}
+}
+
+// serve reads line-delimited JSON-RPC requests from in and writes one
+// response line per request to out until in is closed or "shutdown" is called.
+// Parsed tasks and frontmatter stay cached between requests; only files whose
+// mtime or size changed (or that the server itself modified) are re-read.
+func serve(in io.Reader, out io.Writer, notesPaths []string, ctx *ParseContext, cfg Config) error {
+	defer useWarmIndex(ctx, cfg)()
+
+	s := &server{notesPaths: notesPaths, ctx: ctx, cfg: cfg}
+	reader := bufio.NewReader(in)
+	enc := json.NewEncoder(out)
+
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		if len(bytes.TrimSpace(line)) > 0 {
+			if resp := s.handle(line); resp != nil {
+				if err := enc.Encode(resp); err != nil {
+					return fmt.Errorf("writing response: %w", err)
+				}
+			}
+			if s.done {
+				break
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				break
+			}
+			return fmt.Errorf("reading request: %w", readErr)
+		}
+	}
+
+	return nil
+}
+
+func cmdServe(notesPaths []string, ctx *ParseContext, cfg Config) error {
+	return serve(os.Stdin, os.Stdout, notesPaths, ctx, cfg)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// rpcClient drives serve() over in-memory pipes, one request at a time.
+type rpcClient struct {
+	t    *testing.T
+	in   *io.PipeWriter
+	out  *bufio.Reader
+	done chan error
+}
+
+func startServer(t *testing.T, notesPaths []string, cfg Config) *rpcClient {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	c := &rpcClient{t: t, in: inW, out: bufio.NewReader(outR), done: make(chan error, 1)}
+	go func() {
+		err := serve(inR, outW, notesPaths, NewParseContext(cfg), cfg)
+		outW.Close()
+		c.done <- err
+	}()
+	t.Cleanup(func() {
+		inW.Close()
+		<-c.done
+	})
+	return c
+}
+
+// send writes a raw request line and decodes the response line.
+func (c *rpcClient) send(line string) map[string]interface{} {
+	c.t.Helper()
+	if _, err := io.WriteString(c.in, line+"\n"); err != nil {
+		c.t.Fatal(err)
+	}
+	resp, err := c.out.ReadString('\n')
+	if err != nil {
+		c.t.Fatalf("reading response: %v", err)
+	}
+	var m map[string]interface{}
+	if err := json.Unmarshal([]byte(resp), &m); err != nil {
+		c.t.Fatalf("bad response %q: %v", resp, err)
+	}
+	return m
+}
+
+// call sends a request and returns its result, failing on an RPC error.
+func (c *rpcClient) call(method string, params interface{}) interface{} {
+	c.t.Helper()
+	req := map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method}
+	if params != nil {
+		req["params"] = params
+	}
+	data, _ := json.Marshal(req)
+	resp := c.send(string(data))
+	if e, ok := resp["error"]; ok {
+		c.t.Fatalf("%s: unexpected error %v", method, e)
+	}
+	return resp["result"]
+}
+
+func TestServe_ListReflectsExternalEdits(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] First task\n"})
+	c := startServer(t, []string{dir}, Config{StateDir: t.TempDir()})
+
+	out, _ := c.call("list", nil).(string)
+	if !strings.Contains(out, "First task") {
+		t.Fatalf("list missing task:\n%s", out)
+	}
+
+	writeTree(t, dir, map[string]string{"b.md": "- [ ] Added outside the server\n"})
+	out, _ = c.call("list", nil).(string)
+	if !strings.Contains(out, "Added outside the server") {
+		t.Errorf("list did not pick up new file:\n%s", out)
+	}
+}
+
+func TestServe_ListJSON(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] First task #work\n"})
+	c := startServer(t, []string{dir}, Config{StateDir: t.TempDir()})
+
+	for _, format := range []string{"json", "ndjson"} {
+		out, ok := c.call("list", map[string]interface{}{"format": format}).([]interface{})
+		if !ok || len(out) != 1 {
+			t.Fatalf("%s: list = %#v, want an array of one task", format, out)
+		}
+		task, _ := out[0].(map[string]interface{})
+		if task["body"] != "First task" || task["line"] != 1.0 {
+			t.Errorf("%s: task = %v", format, task)
+		}
+	}
+}
+
+func TestServe_FrontmatterChangesInvalidateCache(t *testing.T) {
+	ResetFrontmatterCache()
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "---\ntags:\n  - alpha\n---\n- [ ] Task\n"})
+	c := startServer(t, []string{dir}, Config{StateDir: t.TempDir()})
+
+	tags := c.call("tags", nil).([]interface{})
+	if len(tags) != 1 || tags[0] != "alpha" {
+		t.Fatalf("tags = %v, want [alpha]", tags)
+	}
+
+	writeTree(t, dir, map[string]string{"a.md": "---\ntags:\n  - beta-renamed\n---\n- [ ] Task\n"})
+	tags = c.call("tags", nil).([]interface{})
+	if len(tags) != 1 || tags[0] != "beta-renamed" {
+		t.Errorf("tags after edit = %v, want [beta-renamed]", tags)
+	}
+}
+
+func TestServe_MutationsAndTimer(t *testing.T) {
+	dir := t.TempDir()
+	state := t.TempDir()
+	path := filepath.Join(dir, "a.md")
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] Write report\n- [ ] Call bob\n"})
+	c := startServer(t, []string{dir}, Config{StateDir: state})
+
+	started := c.call("start", map[string]interface{}{"file": path, "line": 1}).(map[string]interface{})
+	if started["name"] != "Write report" {
+		t.Errorf("start result = %v", started)
+	}
+	current := c.call("current", nil).(map[string]interface{})
+	if current["line"] != float64(1) {
+		t.Errorf("current = %v", current)
+	}
+	stopped := c.call("stop", nil).(map[string]interface{})
+	if stopped["name"] != "Write report" {
+		t.Errorf("stop result = %v", stopped)
+	}
+	if c.call("current", nil) != nil {
+		t.Error("current should be null after stop")
+	}
+	recent := c.call("recent", nil).([]interface{})
+	if len(recent) != 1 || recent[0].(map[string]interface{})["status"] != "stopped" {
+		t.Errorf("recent = %v", recent)
+	}
+	resumed := c.call("resume", nil).(map[string]interface{})
+	if resumed["name"] != "Write report" {
+		t.Errorf("resume result = %v", resumed)
+	}
+	c.call("stop", nil)
+
+	if c.call("check", map[string]interface{}{"file": path, "line": 2}) != true {
+		t.Error("check should return true")
+	}
+	out := c.call("list", nil).(string)
+	if strings.Contains(out, "Call bob") {
+		t.Errorf("checked task still listed:\n%s", out)
+	}
+
+	data, _ := os.ReadFile(path)
+	lines := strings.Split(string(data), "\n")
+	if !strings.Contains(lines[0], "::start") || !strings.Contains(lines[0], "::stop") {
+		t.Errorf("line 1 = %q, want start and stop markers", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "- [x] Call bob") {
+		t.Errorf("line 2 = %q", lines[1])
+	}
+}
+
+func TestServe_Create(t *testing.T) {
+	dir := t.TempDir()
+	inbox := filepath.Join(dir, "inbox.md")
+	c := startServer(t, []string{dir}, Config{StateDir: t.TempDir()})
+
+	c.call("create", map[string]interface{}{"body": "New idea", "inbox_file": inbox})
+	out := c.call("list", nil).(string)
+	if !strings.Contains(out, "New idea") {
+		t.Errorf("created task not listed:\n%s", out)
+	}
+}
+
+func TestServe_Errors(t *testing.T) {
+	c := startServer(t, []string{t.TempDir()}, Config{StateDir: t.TempDir()})
+
+	cases := []struct {
+		line string
+		code float64
+	}{
+		{`not json`, rpcParseError},
+		{`{"jsonrpc":"2.0","id":1}`, rpcInvalidRequest},
+		{`{"jsonrpc":"2.0","id":2,"method":"frobnicate"}`, rpcMethodNotFound},
+		{`{"jsonrpc":"2.0","id":3,"method":"defer","params":{}}`, rpcInvalidParams},
+		{`{"jsonrpc":"2.0","id":4,"method":"check","params":{"file":"/nonexistent.md","line":1}}`, rpcServerError},
+	}
+	for _, tc := range cases {
+		resp := c.send(tc.line)
+		e, ok := resp["error"].(map[string]interface{})
+		if !ok {
+			t.Errorf("%s: expected error, got %v", tc.line, resp)
+			continue
+		}
+		if e["code"] != tc.code {
+			t.Errorf("%s: code = %v, want %v", tc.line, e["code"], tc.code)
+		}
+	}
+}
+
+func TestServe_NotificationAndShutdown(t *testing.T) {
+	c := startServer(t, []string{t.TempDir()}, Config{StateDir: t.TempDir()})
+
+	// A notification gets no reply, so the next response belongs to id 7
+	io.WriteString(c.in, `{"jsonrpc":"2.0","method":"tags"}`+"\n")
+	resp := c.send(`{"jsonrpc":"2.0","id":7,"method":"shutdown"}`)
+	if resp["id"] != float64(7) {
+		t.Errorf("response id = %v, want 7", resp["id"])
+	}
+	if err := <-c.done; err != nil {
+		t.Errorf("serve returned %v", err)
+	}
+	c.done <- nil // satisfy cleanup
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cmdDo picks a task and starts it, stopping the running task first. The
+// candidates are the open tasks due today, or those in --horizon and/or
+// matching --query. --match starts the one candidate whose body contains the
+// text; otherwise the task is picked with fzf, or from a numbered list on the
+// terminal when fzf is not installed. With --at both the stop and the start
+// happen at that time instead of now.
+//
+//	task do [--match TEXT] [--horizon LABEL] [--query EXPR] [--at TIME]
+func cmdDo(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
+	fs := flag.NewFlagSet("do", flag.ContinueOnError)
+	match := fs.String("match", "", "start the one candidate whose body contains this text")
+	horizon := fs.String("horizon", "", `pick from a horizon instead of today, e.g. "this week"`)
+	query := fs.String("query", "", "pick from the open tasks matching this filter expression")
+	atStr := fs.String("at", "", "when it happened: HH:MM, -15m or a date and time")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return fmt.Errorf("usage: task do [--match TEXT] [--horizon LABEL] [--query EXPR] [--at TIME]")
+	}
+	now := time.Now().In(time.Local)
+	var at time.Time
+	if *atStr != "" {
+		var err error
+		if at, err = parseAtTime(*atStr, now, ctx); err != nil {
+			return err
+		}
+	}
+	q, err := ParseQuery(*query, ctx, now)
+	if err != nil {
+		return err
+	}
+
+	tasks, err := loadOpenTasks(notesPaths, ctx, cfg)
+	if err != nil {
+		return err
+	}
+	candidates, err := doCandidates(tasks, ctx, cfg, now, *horizon, q)
+	if err != nil {
+		return err
+	}
+	if len(candidates) == 0 {
+		if *horizon == "" && q == nil {
+			fmt.Println("No tasks due today.")
+		} else {
+			fmt.Println("No matching tasks.")
+		}
+		return nil
+	}
+
+	var task Task
+	if *match != "" {
+		if task, err = matchTask(candidates, *match); err != nil {
+			return err
+		}
+	} else {
+		idx, err := pickTask(candidates)
+		if err != nil {
+			return err
+		}
+		if idx < 0 {
+			fmt.Println("No task selected.")
+			return nil
+		}
+		task = candidates[idx]
+	}
+
+	stopped, err := switchTask(ctx, cfg, task, at)
+	if stopped != nil {
+		fmt.Printf("Stopped: %s\n", stopped.Name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started: %s\n", task.Body)
+	return nil
+}
+
+// doCandidates narrows open tasks to those `task do` offers: the tasks due
+// today, or, with a horizon or a query, the tasks listed under that horizon
+// and matching the query. Synthetic project tasks are never offered.
+func doCandidates(tasks []Task, ctx *ParseContext, cfg Config, now time.Time, horizon string, q *Query) ([]Task, error) {
+	var real []Task
+	for _, t := range tasks {
+		if !t.SortLast {
+			real = append(real, t)
+		}
+	}
+	if horizon == "" {
+		if q != nil {
+			return q.Filter(real), nil
+		}
+		today := now.Format(ctx.formats.GoDate)
+		var out []Task
+		for _, t := range real {
+			if t.DueDate != nil && t.DueDate.Format(ctx.formats.GoDate) == today {
+				out = append(out, t)
+			}
+		}
+		return out, nil
+	}
+
+	horizons, overlap := listHorizons(cfg, now)
+	want := horizonKey(horizon)
+	known := false
+	labels := []string{}
+	hasUndated := false
+	for _, h := range horizons {
+		known = known || horizonKey(h.Label) == want
+		hasUndated = hasUndated || h.Undated
+		labels = append(labels, strings.TrimSpace(strings.TrimLeft(h.Label, "#")))
+	}
+	if !hasUndated {
+		// GroupTasks lists undated tasks under "# Someday" when no horizon takes them
+		known = known || horizonKey("Someday") == want
+		labels = append(labels, "Someday")
+	}
+	if !known {
+		return nil, fmt.Errorf("unknown horizon %q (want one of: %s)", horizon, strings.Join(labels, ", "))
+	}
+
+	groups := GroupTasks(real, now, FormatOpts{Query: q, Horizons: horizons, Overlap: overlap, OverdueByTime: cfg.OverdueByTime})
+	var out []Task
+	for _, g := range groups {
+		if horizonKey(g.Label) == want {
+			out = append(out, g.Tasks...)
+		}
+	}
+	return out, nil
+}
+
+// horizonKey normalizes a horizon label for comparison, so that "this-week"
+// and "thisweek" name "# This Week".
+func horizonKey(label string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '#', ' ', '\t', '-', '_':
+			return -1
+		}
+		return r
+	}, strings.ToLower(label))
+}
+
+// matchTask returns the one task whose body contains text, ignoring case.
+// None or several matching is an error, listing the matches in the latter
+// case.
+func matchTask(tasks []Task, text string) (Task, error) {
+	needle := strings.ToLower(text)
+	var found []Task
+	for _, t := range tasks {
+		if strings.Contains(strings.ToLower(t.Body), needle) {
+			found = append(found, t)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Task{}, fmt.Errorf("no task matches %q", text)
+	case 1:
+		return found[0], nil
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d tasks match %q:", len(found), text)
+	for _, t := range found {
+		fmt.Fprintf(&b, "\n  %s:%d: %s", t.FilePath, t.LineNumber, t.Body)
+	}
+	return Task{}, errors.New(b.String())
+}
+
+// pickTask lets the user pick one of tasks with fzf, or with promptTask on
+// the terminal when fzf is not installed. It returns -1 if nothing was
+// picked.
+func pickTask(tasks []Task) (int, error) {
+	if _, err := exec.LookPath("fzf"); err == nil {
+		return fzfTask(tasks)
+	}
+	if fi, err := os.Stdin.Stat(); err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		return -1, fmt.Errorf("fzf is not installed and stdin is not a terminal; use --match TEXT or task start-at <file> <line>")
+	}
+	return promptTask(tasks, os.Stdin, os.Stderr)
+}
+
+func fzfTask(tasks []Task) (int, error) {
+	var fzfInput strings.Builder
+	for i, t := range tasks {
+		fmt.Fprintf(&fzfInput, "%d\t%s\n", i, t.Body)
+	}
+
+	cmd := exec.Command("fzf", "--with-nth=2..", "--delimiter=\t")
+	cmd.Stdin = strings.NewReader(fzfInput.String())
+	cmd.Stderr = os.Stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return -1, nil // cancelled
+	}
+
+	selection := strings.TrimRight(string(out), "\n\r")
+	parts := strings.SplitN(selection, "\t", 2)
+	var idx int
+	fmt.Sscanf(parts[0], "%d", &idx)
+	if idx < 0 || idx >= len(tasks) {
+		return -1, fmt.Errorf("invalid selection index: %d", idx)
+	}
+	return idx, nil
+}
+
+// promptTask prints tasks as a numbered list to out and reads the number of
+// the one to start from in, asking again on bad input. An empty answer or the
+// end of input cancels with -1.
+func promptTask(tasks []Task, in io.Reader, out io.Writer) (int, error) {
+	width := len(strconv.Itoa(len(tasks)))
+	for i, t := range tasks {
+		fmt.Fprintf(out, "%*d) %s\n", width, i+1, t.Body)
+	}
+	sc := bufio.NewScanner(in)
+	for {
+		fmt.Fprintf(out, "Start which task? [1-%d, empty to cancel] ", len(tasks))
+		if !sc.Scan() {
+			fmt.Fprintln(out)
+			return -1, sc.Err()
+		}
+		answer := strings.TrimSpace(sc.Text())
+		if answer == "" {
+			return -1, nil
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(tasks) {
+			return n - 1, nil
+		}
+		fmt.Fprintf(out, "No task %q.\n", answer)
+	}
+}
+
+// switchTask stops the running task and starts task, both at at, or now if at
+// is zero. A backdated start may not precede a timer marker on the task's
+// line. It returns the task that was stopped, if any.
+func switchTask(ctx *ParseContext, cfg Config, task Task, at time.Time) (*CurrentTask, error) {
+	startAt := time.Now().In(time.Local)
+	if !at.IsZero() {
+		if err := checkMarkerOrder(task, "start", at, ctx); err != nil {
+			return nil, err
+		}
+		startAt = at
+	}
+	stopped, err := stopCurrentTaskAt(cfg, at)
+	if err != nil {
+		return nil, fmt.Errorf("stopping current task: %w", err)
+	}
+	return stopped, startTask(ctx, cfg, task, startAt)
+}
+
+// startAtLocation starts the task at "<file> <line|^id>" in args, which also
+// take --at and --expect. It returns the started task and the one stopped, if
+// any.
+func startAtLocation(ctx *ParseContext, cfg Config, args []string) (Task, *CurrentTask, error) {
+	fs := flag.NewFlagSet("start-at", flag.ContinueOnError)
+	atStr := fs.String("at", "", "when it happened: HH:MM, -15m or a date and time")
+	filePath, lineNum, err := taskLocationWith(ctx, fs, args, "task start-at <filepath> <linenum|^id> [--at TIME]")
+	if err != nil {
+		return Task{}, nil, err
+	}
+	var at time.Time
+	if *atStr != "" {
+		if at, err = parseAtTime(*atStr, time.Now().In(time.Local), ctx); err != nil {
+			return Task{}, nil, err
+		}
+	}
+	// The state outlives the working directory, so it records absolute paths
+	if abs, err := filepath.Abs(filePath); err == nil {
+		filePath = abs
+	}
+	task, err := readTaskAt(ctx, filePath, lineNum)
+	if err != nil {
+		return Task{}, nil, err
+	}
+	if task.Status != "open" {
+		return Task{}, nil, fmt.Errorf("%s:%d is not an open task", filePath, lineNum)
+	}
+	stopped, err := switchTask(ctx, cfg, task, at)
+	return task, stopped, err
+}
+
+// cmdStartAt starts the task on a given line, without picking.
+//
+//	task start-at <filepath> <linenum|^id> [--at TIME] [--expect FP]
+func cmdStartAt(ctx *ParseContext, cfg Config, args []string) error {
+	task, stopped, err := startAtLocation(ctx, cfg, args)
+	if stopped != nil {
+		fmt.Printf("Stopped: %s\n", stopped.Name)
+	}
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started: %s\n", task.Body)
+	return nil
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPromptTask(t *testing.T) {
+	tasks := []Task{{Body: "Write"}, {Body: "Read"}}
+	var out bytes.Buffer
+	idx, err := promptTask(tasks, strings.NewReader("x\n3\n 2 \n"), &out)
+	if err != nil || idx != 1 {
+		t.Fatalf("got %d, %v", idx, err)
+	}
+	if !strings.HasPrefix(out.String(), "1) Write\n2) Read\nStart which task? [1-2, empty to cancel] ") ||
+		strings.Count(out.String(), "Start which task?") != 3 {
+		t.Errorf("output:\n%s", out.String())
+	}
+	for _, in := range []string{"\n", ""} {
+		if idx, err := promptTask(tasks, strings.NewReader(in), &out); idx != -1 || err != nil {
+			t.Errorf("%q: got %d, %v; want a cancel", in, idx, err)
+		}
+	}
+}
+
+func TestDoCandidates(t *testing.T) {
+	body := func(tasks []Task) string {
+		var b []string
+		for _, t := range tasks {
+			b = append(b, t.Body)
+		}
+		return strings.Join(b, ",")
+	}
+	// testNow is Tuesday 2026-02-17
+	tasks := []Task{
+		{Body: "Late", DueDate: mustDatePtr("2026-02-16"), Status: "open"},
+		{Body: "Now", DueDate: mustDatePtr("2026-02-17"), Status: "open", Tags: []string{"work"}},
+		{Body: "Soon", DueDate: mustDatePtr("2026-02-19"), Status: "open", Tags: []string{"work"}},
+		{Body: "Later", Status: "open", Tags: []string{"work"}},
+		{Body: "Project", DueDate: mustDatePtr("2026-02-17"), Status: "open", SortLast: true},
+	}
+	work, _ := ParseQuery("#work", defaultCtx, testNow)
+	cases := []struct {
+		horizon string
+		q       *Query
+		want    string
+	}{
+		{"", nil, "Now"},
+		{"", work, "Now,Soon,Later"},
+		{"this-week", nil, "Soon"},
+		{"# Overdue", nil, "Late"},
+		{"someday", work, "Later"},
+		{"overdue", work, ""},
+	}
+	for _, c := range cases {
+		got, err := doCandidates(tasks, defaultCtx, Config{}, testNow, c.horizon, c.q)
+		if err != nil {
+			t.Errorf("%q %s: %v", c.horizon, c.q, err)
+			continue
+		}
+		if body(got) != c.want {
+			t.Errorf("%q %s = %q, want %q", c.horizon, c.q, body(got), c.want)
+		}
+	}
+	if _, err := doCandidates(tasks, defaultCtx, Config{}, testNow, "next year", nil); err == nil || !strings.Contains(err.Error(), "This Week") {
+		t.Errorf("unknown horizon: %v", err)
+	}
+}
+
+func TestMatchTask(t *testing.T) {
+	tasks := []Task{{Body: "Write report"}, {Body: "Review report"}, {Body: "Call Bob"}}
+	if got, err := matchTask(tasks, "WRITE"); err != nil || got.Body != "Write report" {
+		t.Errorf("got %q, %v", got.Body, err)
+	}
+	if _, err := matchTask(tasks, "report"); err == nil || !strings.Contains(err.Error(), "2 tasks match") || !strings.Contains(err.Error(), "Review report") {
+		t.Errorf("several: %v", err)
+	}
+	if _, err := matchTask(tasks, "lunch"); err == nil {
+		t.Error("none: want an error")
+	}
+}
+
+func TestStartAtLocation(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Write\n- [ ] Read ^r1\n- [x] Done\n"), 0644)
+	cfg := Config{StateDir: filepath.Join(dir, "state")}
+
+	task, stopped, err := startAtLocation(defaultCtx, cfg, []string{f, "1"})
+	if err != nil || stopped != nil || task.Body != "Write" {
+		t.Fatalf("got %q, %v, %v", task.Body, stopped, err)
+	}
+	_, _, err = startAtLocation(defaultCtx, cfg, []string{f, "2", "--expect", TaskFingerprint("open", "Gone")})
+	if err == nil {
+		t.Error("a task that is gone should not be started")
+	}
+	task, stopped, err = startAtLocation(defaultCtx, cfg, []string{f, "^r1"})
+	if err != nil || stopped == nil || stopped.Name != "Write" || task.Body != "Read" {
+		t.Fatalf("got %q, %+v, %v", task.Body, stopped, err)
+	}
+	if ct, _ := ReadCurrentTaskFrom(cfg.StateDir); ct == nil || ct.Name != "Read" || ct.ID != "r1" || ct.LineNumber != 2 {
+		t.Errorf("state = %+v", ct)
+	}
+	lines := splitLines(readFile(t, f))
+	if !strings.Contains(lines[0], "::start") || !strings.Contains(lines[0], "::stop") || !strings.Contains(lines[1], "::start") {
+		t.Errorf("file:\n%s", strings.Join(lines, "\n"))
+	}
+
+	if _, _, err := startAtLocation(defaultCtx, cfg, []string{f, "3"}); err == nil {
+		t.Error("starting a completed task should fail")
+	}
+	if ct, _ := ReadCurrentTaskFrom(cfg.StateDir); ct == nil || ct.Name != "Read" {
+		t.Errorf("a refused start changed the state: %+v", ct)
+	}
+}
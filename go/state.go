@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -10,13 +11,63 @@ import (
 )
 
 const defaultStateDir = ".local/state/task"
+
+// stateFile is the legacy state file, a single tab-separated line
+// "StartTime\tName\tFilePath\tLineNumber[\tID]". It is migrated to
+// stateJSONFile the next time the state is written.
 const stateFile = "current_task"
 
+const stateJSONFile = "state.json"
+
+// stateLockFile is flocked while the state is read, changed and written back.
+const stateLockFile = "state.lock"
+
+// stateVersion is bumped whenever the layout of stateJSONFile changes.
+const stateVersion = 1
+
+// recentLimit is how many recently worked tasks the state keeps.
+const recentLimit = 20
+
 type CurrentTask struct {
-	StartTime  int64  // unix timestamp
-	Name       string // task body
-	FilePath   string
-	LineNumber int
+	StartTime   int64  `json:"start_time"` // unix timestamp
+	Name        string `json:"name"`       // task body
+	FilePath    string `json:"file"`
+	LineNumber  int    `json:"line"`
+	ID          string `json:"id,omitempty"`          // block ID, used to find the task again if its line moved
+	Fingerprint string `json:"fingerprint,omitempty"` // TaskFingerprint of the task, likewise
+}
+
+// RecentTask is a task that was worked on and stopped or completed.
+type RecentTask struct {
+	CurrentTask
+	StopTime  int64 `json:"stop_time"`
+	Completed bool  `json:"completed,omitempty"`
+}
+
+// sameTask reports whether two entries name the same task: the same block ID,
+// or else the same fingerprint in the same file.
+func (ct CurrentTask) sameTask(other CurrentTask) bool {
+	if ct.ID != "" || other.ID != "" {
+		return ct.ID == other.ID
+	}
+	return ct.FilePath == other.FilePath && ct.fingerprint() == other.fingerprint()
+}
+
+// fingerprint is the recorded fingerprint, or for entries written without one
+// that of an open task with the entry's name.
+func (ct CurrentTask) fingerprint() string {
+	if ct.Fingerprint != "" {
+		return ct.Fingerprint
+	}
+	return TaskFingerprint("open", ct.Name)
+}
+
+// State is the content of stateJSONFile: the running task, if any, and the
+// recently worked tasks, most recent first, each listed once.
+type State struct {
+	Version int          `json:"version"`
+	Current *CurrentTask `json:"current"`
+	Recent  []RecentTask `json:"recent"`
 }
 
 // resolveStateDir returns the state directory, using the provided override
@@ -33,25 +84,83 @@ func statePathFor(stateDir string) string {
 	return filepath.Join(resolveStateDir(stateDir), stateFile)
 }
 
+func stateJSONPathFor(stateDir string) string {
+	return filepath.Join(resolveStateDir(stateDir), stateJSONFile)
+}
+
 // Backward-compatible: uses default state dir
 func statePath() string {
 	return statePathFor("")
 }
 
-func ReadCurrentTask() (*CurrentTask, error) {
-	return ReadCurrentTaskFrom("")
-}
+// ReadStateFrom reads the state in stateDir. A missing state is an empty
+// one. A legacy current_task file, which only a binary predating
+// stateJSONFile writes, holds the running task; it is left in place for
+// updateStateIn to migrate.
+func ReadStateFrom(stateDir string) (*State, error) {
+	st := &State{Version: stateVersion}
+	data, err := os.ReadFile(stateJSONPathFor(stateDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, st); err != nil {
+			return nil, fmt.Errorf("malformed %s: %w", stateJSONFile, err)
+		}
+		if st.Version > stateVersion {
+			return nil, fmt.Errorf("%s has version %d; this binary reads up to version %d", stateJSONFile, st.Version, stateVersion)
+		}
+	}
 
-func ReadCurrentTaskFrom(stateDir string) (*CurrentTask, error) {
-	data, err := os.ReadFile(statePathFor(stateDir))
+	legacy, err := os.ReadFile(statePathFor(stateDir))
+	if os.IsNotExist(err) {
+		return st, nil
+	}
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
-		}
 		return nil, err
 	}
-	line := strings.TrimRight(string(data), "\n\r")
-	parts := strings.SplitN(line, "\t", 4)
+	if st.Current, err = parseLegacyState(string(legacy)); err != nil {
+		return nil, err
+	}
+	return st, nil
+}
+
+// updateStateIn reads the state in stateDir, applies change and writes it
+// back, removing a legacy current_task file it was read from. The state lock
+// is held throughout, so concurrent commands neither lose each other's
+// changes nor race on the migration.
+func updateStateIn(stateDir string, change func(st *State)) error {
+	dir := resolveStateDir(stateDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, stateLockFile), os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := lockFile(f); err != nil {
+		return fmt.Errorf("locking state: %w", err)
+	}
+	defer unlockFile(f)
+
+	st, err := ReadStateFrom(stateDir)
+	if err != nil {
+		return err
+	}
+	change(st)
+	if err := WriteStateTo(stateDir, st); err != nil {
+		return err
+	}
+	if err := os.Remove(statePathFor(stateDir)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func parseLegacyState(data string) (*CurrentTask, error) {
+	line := strings.TrimRight(data, "\n\r")
+	parts := strings.SplitN(line, "\t", 5)
 	if len(parts) < 4 {
 		return nil, fmt.Errorf("malformed current_task: %q", line)
 	}
@@ -63,39 +172,99 @@ func ReadCurrentTaskFrom(stateDir string) (*CurrentTask, error) {
 	if err != nil {
 		return nil, fmt.Errorf("bad line number: %w", err)
 	}
-	return &CurrentTask{
-		StartTime:  ts,
-		Name:       parts[1],
-		FilePath:   parts[2],
-		LineNumber: ln,
-	}, nil
+	ct := &CurrentTask{
+		StartTime:   ts,
+		Name:        parts[1],
+		FilePath:    parts[2],
+		LineNumber:  ln,
+		Fingerprint: TaskFingerprint("open", parts[1]),
+	}
+	if len(parts) == 5 {
+		ct.ID = parts[4]
+	}
+	return ct, nil
+}
+
+// WriteStateTo writes st to stateDir, replacing the previous file atomically.
+func WriteStateTo(stateDir string, st *State) error {
+	path := stateJSONPathFor(stateDir)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	st.Version = stateVersion
+	if st.Recent == nil {
+		st.Recent = []RecentTask{}
+	}
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding state: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), stateJSONFile+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func ReadCurrentTask() (*CurrentTask, error) {
+	return ReadCurrentTaskFrom("")
+}
+
+func ReadCurrentTaskFrom(stateDir string) (*CurrentTask, error) {
+	st, err := ReadStateFrom(stateDir)
+	if err != nil {
+		return nil, err
+	}
+	return st.Current, nil
 }
 
 func WriteCurrentTask(ct CurrentTask) error {
 	return WriteCurrentTaskTo("", ct)
 }
 
+// WriteCurrentTaskTo records ct as the running task, keeping the history.
 func WriteCurrentTaskTo(stateDir string, ct CurrentTask) error {
-	dir := filepath.Dir(statePathFor(stateDir))
-	if err := os.MkdirAll(dir, 0755); err != nil {
-		return err
-	}
-	line := fmt.Sprintf("%d\t%s\t%s\t%d\n", ct.StartTime, ct.Name, ct.FilePath, ct.LineNumber)
-	return os.WriteFile(statePathFor(stateDir), []byte(line), 0644)
+	return updateStateIn(stateDir, func(st *State) {
+		st.Current = &ct
+	})
 }
 
 func ClearCurrentTask() error {
 	return ClearCurrentTaskFrom("")
 }
 
+// ClearCurrentTaskFrom forgets the running task without adding it to the
+// recent tasks.
 func ClearCurrentTaskFrom(stateDir string) error {
-	err := os.Remove(statePathFor(stateDir))
-	if os.IsNotExist(err) {
-		return nil
+	st, err := ReadStateFrom(stateDir)
+	if err != nil || st.Current == nil {
+		return err
 	}
-	return err
+	return updateStateIn(stateDir, func(st *State) {
+		st.Current = nil
+	})
 }
 
-func FormatMarker(kind string, now time.Time, fmts DateTimeFormats) string {
-	return fmt.Sprintf("::%-s [[%s]] %s ", kind, now.Format(fmts.GoDate), now.Format(fmts.GoTime))
+// FinishCurrentTaskFrom clears the running task and puts ct, its up to date
+// location, first among the recent tasks, stopped at stop.
+func FinishCurrentTaskFrom(stateDir string, ct CurrentTask, stop time.Time, completed bool) error {
+	ct.Fingerprint = ct.fingerprint()
+	return updateStateIn(stateDir, func(st *State) {
+		st.Current = nil
+		recent := []RecentTask{{CurrentTask: ct, StopTime: stop.Unix(), Completed: completed}}
+		for _, r := range st.Recent {
+			if !r.sameTask(ct) && len(recent) < recentLimit {
+				recent = append(recent, r)
+			}
+		}
+		st.Recent = recent
+	})
 }
@@ -3,7 +3,10 @@ package main
 import (
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestWriteAndReadCurrentTask(t *testing.T) {
@@ -85,3 +88,90 @@ func TestClearCurrentTask_NoFile(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestState_MigratesLegacyFileOnWrite(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, stateFile), []byte("42\tWrite\t/a.md\t3\ttb-1\n"), 0644)
+
+	st, err := ReadStateFrom(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := CurrentTask{StartTime: 42, Name: "Write", FilePath: "/a.md", LineNumber: 3, ID: "tb-1", Fingerprint: TaskFingerprint("open", "Write")}
+	if st.Current == nil || *st.Current != want {
+		t.Fatalf("current = %+v, want %+v", st.Current, want)
+	}
+	// Reading changes nothing; the next write migrates
+	if _, err := os.Stat(filepath.Join(dir, stateFile)); err != nil {
+		t.Errorf("legacy file touched by a read: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateJSONFile)); !os.IsNotExist(err) {
+		t.Error("state.json written by a read")
+	}
+	if err := FinishCurrentTaskFrom(dir, *st.Current, time.Unix(100, 0), false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, stateFile)); !os.IsNotExist(err) {
+		t.Error("legacy file kept after migration")
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, stateJSONFile))
+	if !strings.Contains(string(data), `"version": 1`) || !strings.Contains(string(data), `"fingerprint": "`+want.Fingerprint+`"`) ||
+		!strings.Contains(string(data), `"current": null`) {
+		t.Errorf("state.json:\n%s", data)
+	}
+
+	os.WriteFile(filepath.Join(dir, stateJSONFile), []byte(`{"version": 99}`), 0644)
+	if _, err := ReadStateFrom(dir); err == nil || !strings.Contains(err.Error(), "version 99") {
+		t.Errorf("newer version: %v", err)
+	}
+}
+
+func TestWriteCurrentTask_TabInName(t *testing.T) {
+	dir := t.TempDir()
+	ct := CurrentTask{StartTime: 1, Name: "a\tb", FilePath: "/x\ty.md", LineNumber: 2}
+	if err := WriteCurrentTaskTo(dir, ct); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := ReadCurrentTaskFrom(dir); err != nil || got == nil || *got != ct {
+		t.Errorf("got %+v, %v", got, err)
+	}
+}
+
+func TestFinishCurrentTask_Recent(t *testing.T) {
+	dir := t.TempDir()
+	stop := time.Unix(1000, 0)
+	finish := func(name string, completed bool) {
+		t.Helper()
+		ct := CurrentTask{StartTime: 1, Name: name, FilePath: "/a.md", LineNumber: 1}
+		WriteCurrentTaskTo(dir, ct)
+		if err := FinishCurrentTaskFrom(dir, ct, stop, completed); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < recentLimit+5; i++ {
+		finish(strconv.Itoa(i), false)
+	}
+	finish("3", true)
+
+	st, err := ReadStateFrom(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if st.Current != nil {
+		t.Errorf("current = %+v after finishing", st.Current)
+	}
+	if len(st.Recent) != recentLimit {
+		t.Fatalf("%d recent tasks, want %d", len(st.Recent), recentLimit)
+	}
+	if r := st.Recent[0]; r.Name != "3" || !r.Completed || r.StopTime != 1000 {
+		t.Errorf("first = %+v", r)
+	}
+	if r := st.Recent[1]; r.Name != strconv.Itoa(recentLimit+4) {
+		t.Errorf("second = %+v", r)
+	}
+	for _, r := range st.Recent[1:] {
+		if r.Name == "3" {
+			t.Error("task 3 listed twice")
+		}
+	}
+}
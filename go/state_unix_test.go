@@ -0,0 +1,33 @@
+//go:build unix
+
+package main
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestFinishCurrentTask_Concurrent(t *testing.T) {
+	dir := t.TempDir()
+	const n = 10
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			ct := CurrentTask{Name: "Task " + strconv.Itoa(i), FilePath: "/a.md", LineNumber: i + 1}
+			errs <- FinishCurrentTaskFrom(dir, ct, time.Unix(int64(i), 0), false)
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	st, err := ReadStateFrom(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Recent) != n {
+		t.Errorf("recent has %d tasks, want %d: updates were lost", len(st.Recent), n)
+	}
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// SubtaskConfig controls what indented subtasks take over from their parent.
+type SubtaskConfig struct {
+	InheritDue  bool `json:"inherit_due,omitempty"`  // undated subtasks take the parent's due date
+	InheritTags bool `json:"inherit_tags,omitempty"` // subtasks carry the parent's tags as well
+}
+
+// tabWidth is the indent a leading tab counts for when nesting tasks.
+const tabWidth = 4
+
+// indentWidth returns the column at which the text of line starts, with tabs
+// advancing to the next multiple of tabWidth.
+func indentWidth(line string) int {
+	col := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			col++
+		case '\t':
+			col += tabWidth - col%tabWidth
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// taskKey identifies a task by its location.
+type taskKey struct {
+	file string
+	line int
+}
+
+// fileLineOrder returns the indexes of tasks sorted by file, then line.
+func fileLineOrder(tasks []Task) []int {
+	order := make([]int, len(tasks))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		ta, tb := &tasks[order[a]], &tasks[order[b]]
+		if ta.FilePath != tb.FilePath {
+			return ta.FilePath < tb.FilePath
+		}
+		return ta.LineNumber < tb.LineNumber
+	})
+	return order
+}
+
+// linkSubtasks fills in Parent, Children and ChildrenDone. A task is a
+// subtask of the nearest task above it in the same file with a smaller
+// indent. Lines that are not tasks (notes, blank lines) do not end the
+// nesting, since the scanner only sees task lines.
+func linkSubtasks(tasks []Task) {
+	var stack []int
+	prevFile := ""
+	for _, i := range fileLineOrder(tasks) {
+		t := &tasks[i]
+		if t.FilePath != prevFile {
+			stack, prevFile = stack[:0], t.FilePath
+		}
+		for len(stack) > 0 && tasks[stack[len(stack)-1]].Indent >= t.Indent {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) > 0 {
+			p := &tasks[stack[len(stack)-1]]
+			t.Parent = p.LineNumber
+			p.Children = append(p.Children, t.LineNumber)
+			if t.Status != "open" {
+				p.ChildrenDone++
+			}
+		}
+		stack = append(stack, i)
+	}
+}
+
+// InheritFromParents copies the parent's due date to undated subtasks and
+// the parent's tags to every subtask, as cfg enables. Parents are handled
+// before their subtasks, so values pass down any number of levels.
+func InheritFromParents(tasks []Task, cfg SubtaskConfig) {
+	if !cfg.InheritDue && !cfg.InheritTags {
+		return
+	}
+	byLine := make(map[taskKey]int, len(tasks))
+	for i, t := range tasks {
+		if !t.SortLast {
+			byLine[taskKey{t.FilePath, t.LineNumber}] = i
+		}
+	}
+	for _, i := range fileLineOrder(tasks) {
+		t := &tasks[i]
+		p, ok := byLine[taskKey{t.FilePath, t.Parent}]
+		if t.Parent == 0 || t.SortLast || !ok {
+			continue
+		}
+		parent := &tasks[p]
+		if cfg.InheritDue && t.DueDate == nil && parent.DueDate != nil {
+			t.DueDate = parent.DueDate
+			t.DueFromParent = true
+		}
+		if cfg.InheritTags {
+			tags := append([]string(nil), t.Tags...)
+			for _, tag := range parent.Tags {
+				if !containsString(tags, tag) {
+					tags = append(tags, tag)
+				}
+			}
+			t.Tags = tags
+		}
+	}
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+// nestSubtasks splits tasks into the ones listed at the top level and the
+// subtasks whose parent is among tasks, keyed by that parent and in line
+// order. A subtask whose parent is not listed (done, or filtered out) stays
+// at the top level.
+func nestSubtasks(tasks []Task) ([]Task, map[taskKey][]Task) {
+	present := make(map[taskKey]bool, len(tasks))
+	for _, t := range tasks {
+		if !t.SortLast {
+			present[taskKey{t.FilePath, t.LineNumber}] = true
+		}
+	}
+	var top []Task
+	kids := make(map[taskKey][]Task)
+	for _, t := range tasks {
+		parent := taskKey{t.FilePath, t.Parent}
+		if t.Parent > 0 && !t.SortLast && present[parent] {
+			kids[parent] = append(kids[parent], t)
+		} else {
+			top = append(top, t)
+		}
+	}
+	for _, c := range kids {
+		sort.Slice(c, func(i, j int) bool { return c[i].LineNumber < c[j].LineNumber })
+	}
+	return top, kids
+}
+
+// withSubtasks lists each task followed by its nested subtasks, depth first,
+// setting Depth on each.
+func withSubtasks(tasks []Task, kids map[taskKey][]Task, depth int) []Task {
+	if len(kids) == 0 {
+		return tasks
+	}
+	out := make([]Task, 0, len(tasks))
+	for _, t := range tasks {
+		t.Depth = depth
+		out = append(out, t)
+		if c := kids[taskKey{t.FilePath, t.LineNumber}]; len(c) > 0 {
+			out = append(out, withSubtasks(c, kids, depth+1)...)
+		}
+	}
+	return out
+}
+
+// subtreeEnd returns the index of the last line of the subtree of lines[idx]:
+// the last of the lines below it indented further, up to the first non-blank
+// line that is not. It is idx for a line with nothing nested under it.
+func subtreeEnd(lines []string, idx int) int {
+	indent := indentWidth(lines[idx])
+	end := idx
+	for i := idx + 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == "" {
+			continue
+		}
+		if indentWidth(lines[i]) <= indent {
+			break
+		}
+		end = i
+	}
+	return end
+}
+
+// openSubtasks returns the indexes of the open, non-recurring task lines
+// nested under lines[idx] at any depth: the task lines below it up to the
+// next one that is not indented further, as in linkSubtasks.
+func openSubtasks(ctx *ParseContext, lines []string, idx int) []int {
+	if idx < 0 || idx >= len(lines) {
+		return nil
+	}
+	indent := indentWidth(lines[idx])
+	var out []int
+	for i := idx + 1; i < len(lines); i++ {
+		t, err := ParseTask(RawMatch{Text: lines[i]}, ctx)
+		if err != nil {
+			continue
+		}
+		if indentWidth(lines[i]) <= indent {
+			break
+		}
+		if t.Status == "open" && t.Recurrence == "" {
+			out = append(out, i)
+		}
+	}
+	return out
+}
+
+// completeSubtasks checks off the open subtasks of the task on line lineNum,
+// appending marker to each unless it is empty. Recurring subtasks are left
+// open. Without cascade nothing is changed; the open subtasks are only
+// reported on stderr.
+func completeSubtasks(ctx *ParseContext, filePath string, lineNum int, marker string, cascade bool) error {
+	if !cascade {
+		data, err := os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", filePath, err)
+		}
+		if n := len(openSubtasks(ctx, strings.Split(string(data), "\n"), lineNum-1)); n > 0 {
+			fmt.Fprintf(os.Stderr, "taskbuffer: %s:%d has %d open subtasks; pass --cascade to complete them too\n", filePath, lineNum, n)
+		}
+		return nil
+	}
+	return editLines(filePath, func(lines []string) ([]string, error) {
+		for _, i := range openSubtasks(ctx, lines, lineNum-1) {
+			if marker != "" {
+				lines[i] = appendToTaskLine(lines[i], marker)
+			}
+			lines[i] = strings.Replace(lines[i], ctx.checkbox["open"], ctx.checkbox["done"], 1)
+		}
+		return lines, nil
+	})
+}
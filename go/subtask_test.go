@@ -0,0 +1,210 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestIndentWidth(t *testing.T) {
+	cases := map[string]int{
+		"- [ ] a":      0,
+		"  - [ ] a":    2,
+		"\t- [ ] a":    4,
+		"  \t- [ ] a":  4,
+		"\t  - [ ] a":  6,
+		"\t\t- [ ] a":  8,
+		"     - [ ] a": 5,
+	}
+	for line, want := range cases {
+		if got := indentWidth(line); got != want {
+			t.Errorf("indentWidth(%q) = %d, want %d", line, got, want)
+		}
+	}
+}
+
+// parseFile parses the task lines of content as ParseTasks sees them from a scan.
+func parseFile(path, content string) []Task {
+	var matches []RawMatch
+	for i, line := range strings.Split(content, "\n") {
+		if strings.Contains(line, "- [") {
+			matches = append(matches, RawMatch{Path: path, LineNumber: i + 1, Text: line})
+		}
+	}
+	return ParseTasks(matches, defaultCtx)
+}
+
+func TestParseTasks_LinksSubtasks(t *testing.T) {
+	tasks := parseFile("/a.md", strings.Join([]string{
+		"- [ ] Project",           // 1
+		"  - [x] Step one",        // 2
+		"    some note",           // 3
+		"  - [ ] Step two",        // 4
+		"\t\t- [ ] Detail",        // 5
+		"  - [-] Dropped",         // 6
+		"- [ ] Next top-level",    // 7
+		"\t- [ ] Tab-indented",    // 8
+		"## Heading",              // 9
+		"    - [ ] After heading", // 10
+	}, "\n"))
+	tasks = append(tasks, parseFile("/b.md", "  - [ ] Indented first line")...)
+
+	want := map[string]struct {
+		parent   int
+		children []int
+		done     int
+	}{
+		"Project":             {0, []int{2, 4, 6}, 2},
+		"Step one":            {1, nil, 0},
+		"Step two":            {1, []int{5}, 0},
+		"Detail":              {4, nil, 0},
+		"Dropped":             {1, nil, 0},
+		"Next top-level":      {0, []int{8, 10}, 0},
+		"Tab-indented":        {7, nil, 0},
+		"After heading":       {7, nil, 0},
+		"Indented first line": {0, nil, 0},
+	}
+	if len(tasks) != len(want) {
+		t.Fatalf("parsed %d tasks, want %d", len(tasks), len(want))
+	}
+	for _, task := range tasks {
+		w := want[task.Body]
+		if task.Parent != w.parent || !reflect.DeepEqual(task.Children, w.children) || task.ChildrenDone != w.done {
+			t.Errorf("%s: parent %d children %v done %d; want %d %v %d",
+				task.Body, task.Parent, task.Children, task.ChildrenDone, w.parent, w.children, w.done)
+		}
+	}
+}
+
+func TestInheritFromParents(t *testing.T) {
+	content := strings.Join([]string{
+		"- [ ] Trip #travel (@[[2026-03-01]])",
+		"  - [ ] Book hotel",
+		"    - [ ] Compare prices #web",
+		"  - [ ] Renew passport (@[[2026-02-20]])",
+	}, "\n")
+
+	tasks := parseFile("/a.md", content)
+	InheritFromParents(tasks, SubtaskConfig{})
+	if tasks[1].DueDate != nil || len(tasks[1].Tags) != 0 {
+		t.Errorf("nothing should be inherited by default: %+v", tasks[1])
+	}
+
+	tasks = parseFile("/a.md", content)
+	InheritFromParents(tasks, SubtaskConfig{InheritDue: true, InheritTags: true})
+	for _, c := range []struct {
+		idx  int
+		due  string
+		tags []string
+	}{
+		{1, "2026-03-01", []string{"travel"}},
+		{2, "2026-03-01", []string{"web", "travel"}},
+		{3, "2026-02-20", []string{"travel"}},
+	} {
+		got := tasks[c.idx]
+		if got.DueDate == nil || got.DueDate.Format("2006-01-02") != c.due || !reflect.DeepEqual(got.Tags, c.tags) {
+			t.Errorf("%s: due %v tags %v; want %s %v", got.Body, got.DueDate, got.Tags, c.due, c.tags)
+		}
+	}
+}
+
+func TestFormatTaskfile_Subtasks(t *testing.T) {
+	tasks := parseFile("/a.md", strings.Join([]string{
+		"- [ ] Plan party (@[[2026-02-18]])",
+		"  - [x] Send invites",
+		"  - [ ] Buy cake (@[[2026-02-17]]) #shop",
+		"    - [ ] Pick flavor",
+		"  - [ ] Decorate",
+	}, "\n"))
+	var open []Task
+	for _, task := range tasks {
+		if task.Status == "open" {
+			open = append(open, task)
+		}
+	}
+
+	got := FormatTaskfile(open, testNow, defaultOpts)
+	want := "# Tomorrow\n" +
+		"/a.md:1:1:\t[[2026-02-18]]\t |       |     |\t Plan party \t [1/3]\n" +
+		"/a.md:3:1:\t[[2026-02-17]]\t |       |     |\t   Buy cake \t [0/1] #shop\n" +
+		"/a.md:4:1:\t          \t |       |     |\t     Pick flavor \t\n" +
+		"/a.md:5:1:\t          \t |       |     |\t   Decorate \t\n"
+	if got != want {
+		t.Errorf("got:\n%s\nwant:\n%s", got, want)
+	}
+
+	// With the parent filtered out, subtasks are listed on their own.
+	got = FormatTaskfile(open, testNow, FormatOpts{TagFilter: []string{"shop"}})
+	want = "# Today\n/a.md:3:1:\t[[2026-02-17]]\t |       |     |\t Buy cake \t [0/1] #shop\n"
+	if got != want {
+		t.Errorf("filtered: got:\n%s\nwant:\n%s", got, want)
+	}
+
+	out, err := FormatJSON(open, testNow, defaultOpts, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(out)
+	if !strings.Contains(lines[0], `"children":[2,3,5],"children_done":1`) ||
+		!strings.Contains(lines[2], `"parent":3,"depth":2`) {
+		t.Errorf("JSON lacks the subtask fields:\n%s", out)
+	}
+}
+
+func TestCompleteAt_Cascade(t *testing.T) {
+	content := strings.Join([]string{
+		"- [ ] Release",
+		"  - [ ] Tag version",
+		"    notes about tagging",
+		"    - [ ] Push tag",
+		"  - [x] Changelog",
+		"  - [ ] Weekly sync every week",
+		"- [ ] Unrelated",
+		"  - [ ] Its child",
+		"",
+	}, "\n")
+	path := filepath.Join(t.TempDir(), "a.md")
+
+	os.WriteFile(path, []byte(content), 0644)
+	if err := cmdCompleteAt(defaultCtx, []string{path, "1"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, path))
+	if !strings.HasPrefix(lines[0], "- [x] Release ::complete") || lines[1] != "  - [ ] Tag version" {
+		t.Errorf("without --cascade only the parent changes:\n%s", strings.Join(lines, "\n"))
+	}
+
+	os.WriteFile(path, []byte(content), 0644)
+	if err := cmdCompleteAt(defaultCtx, []string{path, "1", "--cascade"}); err != nil {
+		t.Fatal(err)
+	}
+	lines = splitLines(readFile(t, path))
+	for i, prefix := range []string{
+		"- [x] Release ::complete",
+		"  - [x] Tag version ::complete",
+		"    notes about tagging",
+		"    - [x] Push tag ::complete",
+		"  - [x] Changelog",
+		"  - [ ] Weekly sync every week", // recurring subtasks stay open
+		"- [ ] Unrelated",
+		"  - [ ] Its child",
+	} {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i+1, lines[i], prefix)
+		}
+	}
+	if strings.Contains(lines[4], "::complete") {
+		t.Errorf("already done subtask got a marker: %q", lines[4])
+	}
+
+	os.WriteFile(path, []byte(content), 0644)
+	if err := cmdCheck(defaultCtx, []string{path, "2", "--cascade"}); err != nil {
+		t.Fatal(err)
+	}
+	lines = splitLines(readFile(t, path))
+	if lines[0] != "- [ ] Release" || lines[1] != "  - [x] Tag version" || lines[3] != "    - [x] Push tag" {
+		t.Errorf("check --cascade:\n%s", strings.Join(lines, "\n"))
+	}
+}
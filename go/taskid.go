@@ -0,0 +1,272 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// blockIDPrefix starts every ID generated by `task id assign`. Any other
+// Obsidian-style block ID on a task line is honored as well.
+const blockIDPrefix = "tb-"
+
+// blockIDRe matches a block ID (" ^tb-7f3a") anywhere on a task line.
+var blockIDRe = regexp.MustCompile(`(?:^|\s)\^([A-Za-z0-9][A-Za-z0-9-]*)(?:\s|$)`)
+
+// trailingBlockIDRe matches a block ID at the very end of a line, where
+// Obsidian expects it.
+var trailingBlockIDRe = regexp.MustCompile(`\s\^[A-Za-z0-9][A-Za-z0-9-]*\s*$`)
+
+// parseBlockID returns the block ID on a line, or "".
+func parseBlockID(line string) string {
+	if m := blockIDRe.FindStringSubmatch(line); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// stripBlockID removes a block ID from text such as a task body.
+func stripBlockID(s string) string {
+	if !blockIDRe.MatchString(s) {
+		return s
+	}
+	return strings.TrimSpace(blockIDRe.ReplaceAllString(s, " "))
+}
+
+// appendToTaskLine appends text to a task line, keeping a trailing block ID
+// at the end of the line.
+func appendToTaskLine(line, text string) string {
+	if loc := trailingBlockIDRe.FindStringIndex(line); loc != nil {
+		id := strings.TrimSpace(line[loc[0]:])
+		return strings.TrimRight(line[:loc[0]], " \t") + " " + strings.TrimRight(text, " \t") + " " + id
+	}
+	return strings.TrimRight(line, " \t") + " " + text
+}
+
+// newBlockID returns a random "tb-xxxx" ID not present in taken, and records
+// it there.
+func newBlockID(taken map[string]bool) string {
+	for n := 2; ; n++ {
+		// Grow the ID if the short space is crowded.
+		for attempt := 0; attempt < 16; attempt++ {
+			buf := make([]byte, n)
+			rand.Read(buf)
+			id := blockIDPrefix + hex.EncodeToString(buf)
+			if !taken[id] {
+				taken[id] = true
+				return id
+			}
+		}
+	}
+}
+
+// isBlockIDArg reports whether a command argument is a block ID reference
+// ("^tb-7f3a") rather than a line number.
+func isBlockIDArg(arg string) bool {
+	return strings.HasPrefix(arg, "^") && len(arg) > 1
+}
+
+// findBlockIDLine returns the 1-based line number carrying the block ID.
+func findBlockIDLine(filePath, id string) (int, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return 0, fmt.Errorf("reading %s: %w", filePath, err)
+	}
+	for i, line := range strings.Split(string(data), "\n") {
+		if parseBlockID(line) == id {
+			return i + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("task ^%s not found in %s", id, filePath)
+}
+
+// taskLocation parses the "<filepath> <linenum|^id> [--expect FP]" arguments
+// shared by the mutation commands, resolving an ID to its current line in the
+// file. With --expect, the line must hold the task with that body fingerprint;
+// if it does not, the task is relocated (see relocateTask).
+func taskLocation(ctx *ParseContext, args []string, usage string) (string, int, error) {
+	return taskLocationWith(ctx, flag.NewFlagSet("task", flag.ContinueOnError), args, usage)
+}
+
+// taskLocationWith is taskLocation for commands with flags of their own,
+// which they define on fs before the call.
+func taskLocationWith(ctx *ParseContext, fs *flag.FlagSet, args []string, usage string) (string, int, error) {
+	expect := fs.String("expect", "", "fingerprint of the task expected on the line")
+	if err := fs.Parse(args); err != nil {
+		return "", 0, err
+	}
+	pos := fs.Args()
+	if len(pos) > 2 {
+		// Flags may also follow the positional arguments.
+		if err := fs.Parse(pos[2:]); err != nil {
+			return "", 0, err
+		}
+		if fs.NArg() > 0 {
+			return "", 0, fmt.Errorf("unexpected argument %q; usage: %s", fs.Arg(0), usage)
+		}
+	}
+	if len(pos) < 2 {
+		return "", 0, fmt.Errorf("usage: %s", usage)
+	}
+
+	filePath := pos[0]
+	var lineNum int
+	var err error
+	if isBlockIDArg(pos[1]) {
+		lineNum, err = findBlockIDLine(filePath, pos[1][1:])
+	} else if lineNum, err = strconv.Atoi(pos[1]); err != nil {
+		err = fmt.Errorf("bad line number: %w", err)
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	if *expect != "" {
+		match, err := fingerprintMatcher(*expect)
+		if err != nil {
+			return "", 0, err
+		}
+		if lineNum, err = relocateTask(ctx, filePath, lineNum, match); err != nil {
+			return "", 0, err
+		}
+	}
+	return filePath, lineNum, nil
+}
+
+// findTaskByID scans the vault for the task with the given block ID.
+func findTaskByID(notesPaths []string, ctx *ParseContext, cfg Config, id string) (Task, error) {
+	tasks, _, err := scanVault(notesPaths, ctx, cfg, false)
+	if err != nil {
+		return Task{}, err
+	}
+	var found []Task
+	for _, t := range tasks {
+		if t.ID == id {
+			found = append(found, t)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return Task{}, fmt.Errorf("no task with ID ^%s", id)
+	case 1:
+		return found[0], nil
+	}
+	var locs []string
+	for _, t := range found {
+		locs = append(locs, fmt.Sprintf("%s:%d", t.FilePath, t.LineNumber))
+	}
+	return Task{}, fmt.Errorf("ID ^%s is used by %d tasks: %s", id, len(found), strings.Join(locs, ", "))
+}
+
+// expandIDArgs rewrites a leading bare "^id" argument into "<filepath> ^id"
+// by looking the ID up in the vault, so mutation commands can be called as
+// `task defer ^tb-7f3a`.
+func expandIDArgs(notesPaths []string, ctx *ParseContext, cfg Config, args []string) ([]string, error) {
+	if len(args) == 0 || !isBlockIDArg(args[0]) {
+		return args, nil
+	}
+	task, err := findTaskByID(notesPaths, ctx, cfg, args[0][1:])
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{task.FilePath, args[0]}, args[1:]...), nil
+}
+
+// locateCurrentTask re-resolves the running task's line, so stop and complete
+// hit the right line after lines above it changed: by block ID if it has one,
+// otherwise by checking the recorded line against the task's fingerprint or
+// name and relocating the task if it moved.
+func locateCurrentTask(ctx *ParseContext, ct *CurrentTask) error {
+	if ct.ID != "" {
+		lineNum, err := findBlockIDLine(ct.FilePath, ct.ID)
+		if err == nil {
+			ct.LineNumber = lineNum
+			return nil
+		}
+		fmt.Fprintf(os.Stderr, "taskbuffer: warning: %v; looking for %q\n", err, ct.Name)
+	}
+	if ct.Name == "" && ct.Fingerprint == "" {
+		return nil // nothing to check against
+	}
+	lineNum, err := relocateTask(ctx, ct.FilePath, ct.LineNumber, matchCurrentTask(ctx, *ct))
+	if err != nil {
+		return err
+	}
+	ct.LineNumber = lineNum
+	return nil
+}
+
+// cmdID manages block IDs.
+//
+//	task id assign [--all]        stamp IDs on open tasks that lack one
+//	task id assign <file> <line>  stamp one task and print its ID
+func cmdID(notesPaths []string, ctx *ParseContext, cfg Config, args []string) error {
+	if len(args) == 0 || args[0] != "assign" {
+		return fmt.Errorf("usage: task id assign [--all] [<filepath> <linenum>]")
+	}
+	fs := flag.NewFlagSet("id assign", flag.ContinueOnError)
+	all := fs.Bool("all", false, "also stamp done and irrelevant tasks")
+	if err := fs.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	tasks, _, err := scanVault(notesPaths, ctx, cfg, false)
+	if err != nil {
+		return err
+	}
+	taken := make(map[string]bool)
+	for _, t := range tasks {
+		if t.ID != "" {
+			taken[t.ID] = true
+		}
+	}
+
+	if fs.NArg() > 0 {
+		filePath, lineNum, err := taskLocation(ctx, fs.Args(), "task id assign <filepath> <linenum>")
+		if err != nil {
+			return err
+		}
+		task, err := readTaskAt(ctx, filePath, lineNum)
+		if err != nil {
+			return err
+		}
+		if task.ID == "" {
+			task.ID = newBlockID(taken)
+			if err := AddBlockIDs(filePath, map[int]string{lineNum: task.ID}); err != nil {
+				return err
+			}
+		}
+		fmt.Println(task.ID)
+		return nil
+	}
+
+	byFile := make(map[string]map[int]string)
+	count := 0
+	for _, t := range tasks {
+		if t.ID != "" || (!*all && t.Status != "open") {
+			continue
+		}
+		if byFile[t.FilePath] == nil {
+			byFile[t.FilePath] = make(map[int]string)
+		}
+		byFile[t.FilePath][t.LineNumber] = newBlockID(taken)
+		count++
+	}
+	files := make([]string, 0, len(byFile))
+	for f := range byFile {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	for _, f := range files {
+		if err := AddBlockIDs(f, byFile[f]); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Assigned %d IDs in %d files\n", count, len(files))
+	return nil
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTask_BlockID(t *testing.T) {
+	cases := []struct {
+		line, id, body string
+	}{
+		{"- [ ] Write report (@[[2026-02-17]]) ^tb-7f3a", "tb-7f3a", "Write report"},
+		{"- [ ] Write report ^tb-7f3a (@[[2026-02-17]])", "tb-7f3a", "Write report"},
+		{"- [ ] Undated ^abc123 ::start [[2026-02-17]] 09:00", "abc123", "Undated"},
+		{"- [ ] Undated ::start [[2026-02-17]] 09:00 ^tb-0001", "tb-0001", "Undated"},
+		{"- [ ] Compute 2^10 for x", "", "Compute 2^10 for x"},
+	}
+	for _, c := range cases {
+		task, err := ParseTask(RawMatch{Path: "/a.md", LineNumber: 1, Text: c.line}, defaultCtx)
+		if err != nil {
+			t.Fatalf("%q: %v", c.line, err)
+		}
+		if task.ID != c.id || task.Body != c.body {
+			t.Errorf("%q: got id %q body %q, want %q %q", c.line, task.ID, task.Body, c.id, c.body)
+		}
+	}
+}
+
+func TestAppendToTaskLine_KeepsTrailingID(t *testing.T) {
+	got := appendToTaskLine("- [ ] Task (@[[2026-02-17]]) ^tb-7f3a", "::start [[2026-02-17]] 09:00 ")
+	want := "- [ ] Task (@[[2026-02-17]]) ::start [[2026-02-17]] 09:00 ^tb-7f3a"
+	if got != want {
+		t.Errorf("got  %q\nwant %q", got, want)
+	}
+	got = appendToTaskLine("- [ ] Task", "::stop [[2026-02-17]] 10:00 ")
+	if got != "- [ ] Task ::stop [[2026-02-17]] 10:00 " {
+		t.Errorf("got %q", got)
+	}
+}
+
+func TestMutations_AddressByID(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] First ^tb-0001\n- [ ] Second (@[[2026-02-17]]) ^tb-0002\n"), 0644)
+
+	// A line inserted above both tasks shifts them down.
+	os.WriteFile(f, []byte("# Header\n- [ ] First ^tb-0001\n- [ ] Second (@[[2026-02-17]]) ^tb-0002\n"), 0644)
+
+	if err := cmdDefer(defaultCtx, []string{f, "^tb-0002"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmdCheck(defaultCtx, []string{f, "^tb-0001"}); err != nil {
+		t.Fatal(err)
+	}
+	lines := splitLines(readFile(t, f))
+	if lines[1] != "- [x] First ^tb-0001" {
+		t.Errorf("check hit the wrong line: %q", lines[1])
+	}
+	if !strings.HasPrefix(lines[2], "- [ ] Second (@[[2026-02-17]]) ::original [[2026-02-17]] ::deferral [[") ||
+		!strings.HasSuffix(lines[2], " ^tb-0002") {
+		t.Errorf("defer result = %q", lines[2])
+	}
+
+	if err := cmdIrrelevant(defaultCtx, []string{f, "^tb-9999"}); err == nil ||
+		!strings.Contains(err.Error(), "^tb-9999 not found") {
+		t.Errorf("missing ID error = %v", err)
+	}
+}
+
+func TestExpandIDArgs(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md":     "- [ ] One ^tb-aaaa\n",
+		"sub/b.md": "- [ ] Two ^tb-bbbb\n- [ ] Dup ^tb-dddd\n",
+		"c.md":     "- [ ] Dup ^tb-dddd\n",
+	})
+	paths := []string{dir}
+
+	args, err := expandIDArgs(paths, defaultCtx, Config{}, []string{"^tb-bbbb"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(args) != 2 || args[0] != filepath.Join(dir, "sub", "b.md") || args[1] != "^tb-bbbb" {
+		t.Errorf("args = %v", args)
+	}
+
+	args, _ = expandIDArgs(paths, defaultCtx, Config{}, []string{"/x.md", "3"})
+	if strings.Join(args, " ") != "/x.md 3" {
+		t.Errorf("file/line args changed: %v", args)
+	}
+
+	if _, err := expandIDArgs(paths, defaultCtx, Config{}, []string{"^tb-dddd"}); err == nil ||
+		!strings.Contains(err.Error(), "used by 2 tasks") {
+		t.Errorf("duplicate ID error = %v", err)
+	}
+	if _, err := expandIDArgs(paths, defaultCtx, Config{}, []string{"^tb-none"}); err == nil {
+		t.Error("expected error for unknown ID")
+	}
+}
+
+func TestCmdID_Assign(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "- [ ] Needs ID\n- [x] Done task\n- [ ] Has ID ^tb-1234\n",
+		"b.md": "- [ ] Another (@[[2026-02-17]])\n",
+	})
+	out, err := captureStdout(t, func() error { return cmdID([]string{dir}, defaultCtx, Config{}, []string{"assign"}) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if out != "Assigned 2 IDs in 2 files\n" {
+		t.Errorf("output = %q", out)
+	}
+
+	idLine := regexp.MustCompile(` \^tb-[0-9a-f]{4}$`)
+	a := splitLines(readFile(t, filepath.Join(dir, "a.md")))
+	if !idLine.MatchString(a[0]) || a[1] != "- [x] Done task" || a[2] != "- [ ] Has ID ^tb-1234" {
+		t.Errorf("a.md = %q", a)
+	}
+	b := splitLines(readFile(t, filepath.Join(dir, "b.md")))
+	if !idLine.MatchString(b[0]) || parseBlockID(a[0]) == parseBlockID(b[0]) {
+		t.Errorf("b.md = %q (a.md %q)", b, a[0])
+	}
+
+	// Single task: prints the existing ID without rewriting.
+	out, err = captureStdout(t, func() error {
+		return cmdID([]string{dir}, defaultCtx, Config{}, []string{"assign", filepath.Join(dir, "a.md"), "3"})
+	})
+	if err != nil || out != "tb-1234\n" {
+		t.Errorf("single assign = %q, %v", out, err)
+	}
+
+	if err := cmdID([]string{dir}, defaultCtx, Config{}, nil); err == nil {
+		t.Error("expected usage error")
+	}
+}
+
+func TestNewBlockID_Unique(t *testing.T) {
+	taken := make(map[string]bool)
+	for i := 0; i < 70000; i++ {
+		newBlockID(taken)
+	}
+	if len(taken) != 70000 {
+		t.Errorf("got %d unique IDs, want 70000", len(taken))
+	}
+}
+
+func TestCurrentTask_IDRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ct := CurrentTask{StartTime: 42, Name: "Task", FilePath: "/a.md", LineNumber: 3, ID: "tb-7f3a"}
+	if err := WriteCurrentTaskTo(dir, ct); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadCurrentTaskFrom(dir)
+	if err != nil || *got != ct {
+		t.Fatalf("got %+v, %v", got, err)
+	}
+
+	// Files written before IDs existed have four fields.
+	os.WriteFile(filepath.Join(dir, stateFile), []byte("42\tTask\t/a.md\t3\n"), 0644)
+	got, err = ReadCurrentTaskFrom(dir)
+	if err != nil || got.ID != "" || got.LineNumber != 3 {
+		t.Fatalf("legacy state = %+v, %v", got, err)
+	}
+}
+
+func TestStopCurrentTask_FollowsIDAfterDrift(t *testing.T) {
+	dir := t.TempDir()
+	stateDir := filepath.Join(dir, "state")
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Focus ^tb-f00d\n"), 0644)
+	cfg := Config{StateDir: stateDir}
+
+	task, err := readTaskAt(defaultCtx, f, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := startTask(defaultCtx, cfg, task, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	content := readFile(t, f)
+	os.WriteFile(f, []byte("# Inserted\n\n"+content), 0644)
+
+	ct, err := stopCurrentTask(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ct.LineNumber != 3 {
+		t.Errorf("stopped line %d, want 3", ct.LineNumber)
+	}
+	lines := splitLines(readFile(t, f))
+	if !strings.Contains(lines[2], "::start") || !strings.Contains(lines[2], "::stop") ||
+		!strings.HasSuffix(lines[2], "^tb-f00d") {
+		t.Errorf("task line = %q", lines[2])
+	}
+	if lines[0] != "# Inserted" {
+		t.Errorf("header modified: %q", lines[0])
+	}
+}
+
+func TestNextOccurrenceLine_FreshID(t *testing.T) {
+	line := "- [ ] Water plants (@[[2026-02-17]]) ::every [[1w]] ^tb-0001"
+	task, _ := ParseTask(RawMatch{Path: "/a.md", LineNumber: 1, Text: line}, defaultCtx)
+	got, err := NextOccurrenceLine(line, task, time.Now(), defaultCtx, func() string { return "tb-0002" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "- [ ] Water plants (@[[2026-02-24]]) ::every [[1w]] ^tb-0002" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// parseAtTime resolves the --at value of do, stop, complete and complete-at:
+// a time today ("09:30", "3:04pm"), a time ago ("-15m", "-1h30m"), or a date
+// and a time ("2026-02-17 09:30", "yesterday 17:30", "2026-02-17T09:30"),
+// the date as for --query. The result is truncated to the minute, as markers
+// are, and may not be in the future.
+func parseAtTime(s string, now time.Time, ctx *ParseContext) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	at, ok := atTime(s, now, ctx)
+	if !ok {
+		return time.Time{}, fmt.Errorf("invalid --at %q (want HH:MM, 3:04pm, -15m or a date and time like \"2026-02-17 09:30\")", s)
+	}
+	at = at.Truncate(time.Minute)
+	if at.After(now) {
+		return time.Time{}, fmt.Errorf("--at %s is in the future", at.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime))
+	}
+	return at, nil
+}
+
+func atTime(s string, now time.Time, ctx *ParseContext) (time.Time, bool) {
+	if strings.HasPrefix(s, "-") {
+		d, err := time.ParseDuration(s[1:])
+		return now.Add(-d), err == nil && d >= 0
+	}
+	day := func(d time.Time, clock string) (time.Time, bool) {
+		m, ok := clockMinutes(strings.TrimSpace(clock))
+		y, mo, dd := d.Date()
+		return time.Date(y, mo, dd, m/60, m%60, 0, 0, time.Local), ok
+	}
+	if at, ok := day(now, s); ok {
+		return at, true
+	}
+	// Try every split between a date and a time, since either may hold spaces
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] != ' ' && s[i] != 'T' {
+			continue
+		}
+		if d, err := parseQueryDate(s[:i], now, ctx.formats.GoDate); err == nil {
+			if at, ok := day(d, s[i+1:]); ok {
+				return at, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// checkMarkerOrder refuses a timer marker at at on a task that has a later
+// start, stop or complete marker, so that a stop cannot precede its start.
+func checkMarkerOrder(task Task, kind string, at time.Time, ctx *ParseContext) error {
+	for _, m := range task.Markers {
+		if m.Kind != "start" && m.Kind != "stop" && m.Kind != "complete" {
+			continue
+		}
+		if prev, ok := markerTime(m, ctx); ok && at.Before(prev) {
+			return fmt.Errorf("%s at %s would precede the %s marker at %s %s on %s:%d",
+				kind, at.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime), m.Kind, m.Date, m.Time, task.FilePath, task.LineNumber)
+		}
+	}
+	return nil
+}
+
+// checkStopAt refuses a retroactive stop or complete of the running task
+// before it started.
+func checkStopAt(ctx *ParseContext, ct *CurrentTask, kind string, at time.Time) error {
+	if start := time.Unix(ct.StartTime, 0).In(time.Local).Truncate(time.Minute); at.Before(start) {
+		return fmt.Errorf("%s at %s would precede the start at %s", kind,
+			at.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime), start.Format(ctx.formats.GoDate+" "+ctx.formats.GoTime))
+	}
+	task, err := readTaskAt(ctx, ct.FilePath, ct.LineNumber)
+	if err != nil {
+		return err
+	}
+	return checkMarkerOrder(task, kind, at, ctx)
+}
+
+// parseAtFlag parses the flags of stop and complete, which take only --at. A
+// zero time means --at was not given.
+func parseAtFlag(name string, args []string, ctx *ParseContext, now time.Time) (time.Time, error) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	atStr := fs.String("at", "", "when it happened: HH:MM, -15m or a date and time")
+	if err := fs.Parse(args); err != nil {
+		return time.Time{}, err
+	}
+	if fs.NArg() > 0 {
+		return time.Time{}, fmt.Errorf("usage: task %s [--at TIME]", name)
+	}
+	if *atStr == "" {
+		return time.Time{}, nil
+	}
+	return parseAtTime(*atStr, now, ctx)
+}
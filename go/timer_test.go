@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAtTime(t *testing.T) {
+	// testNow is 2026-02-17 10:00
+	cases := []struct {
+		in   string
+		want string
+	}{
+		{"09:30", "2026-02-17 09:30"},
+		{"9:15am", "2026-02-17 09:15"},
+		{"10:00", "2026-02-17 10:00"},
+		{"-15m", "2026-02-17 09:45"},
+		{"-1h30m", "2026-02-17 08:30"},
+		{"2026-02-16 17:30", "2026-02-16 17:30"},
+		{"2026-02-16T08:00", "2026-02-16 08:00"},
+		{"yesterday 5:30 pm", "2026-02-16 17:30"},
+	}
+	for _, c := range cases {
+		got, err := parseAtTime(c.in, testNow.Add(20*time.Second), defaultCtx)
+		if err != nil {
+			t.Errorf("%q: %v", c.in, err)
+			continue
+		}
+		if got.Format("2006-01-02 15:04:05") != c.want+":00" {
+			t.Errorf("%q = %s, want %s", c.in, got.Format("2006-01-02 15:04:05"), c.want)
+		}
+	}
+	for _, bad := range []string{"10:30", "tomorrow 08:00", "+15m", "-soon", "25:00", "2026-02-16", ""} {
+		if _, err := parseAtTime(bad, testNow, defaultCtx); err == nil {
+			t.Errorf("%q: want an error", bad)
+		}
+	}
+}
+
+func TestStopAndCompleteAt(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Write ::start [[2026-02-17]] 09:00\n"), 0644)
+	cfg := Config{StateDir: filepath.Join(dir, "state")}
+	started := time.Date(2026, 2, 17, 9, 0, 0, 0, time.Local)
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: started.Unix(), Name: "Write", FilePath: f, LineNumber: 1})
+
+	if _, err := stopCurrentTaskAt(cfg, started.Add(-30*time.Minute)); err == nil || !strings.Contains(err.Error(), "precede") {
+		t.Errorf("stop before the start: %v", err)
+	}
+	if ct, _ := ReadCurrentTaskFrom(cfg.StateDir); ct == nil {
+		t.Fatal("a refused stop cleared the state")
+	}
+	if _, err := stopCurrentTaskAt(cfg, started.Add(40*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, f); got != "- [ ] Write ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 09:40 \n" {
+		t.Errorf("after stop: %q", got)
+	}
+
+	// The stop marker on the line bounds a completion as well
+	WriteCurrentTaskTo(cfg.StateDir, CurrentTask{StartTime: started.Unix(), Name: "Write", FilePath: f, LineNumber: 1})
+	if _, err := completeCurrentTaskAt(cfg, started.Add(20*time.Minute)); err == nil || !strings.Contains(err.Error(), "stop marker at 2026-02-17 09:40") {
+		t.Errorf("complete before the last stop: %v", err)
+	}
+	if _, err := completeCurrentTaskAt(cfg, started.Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, f); got != "- [x] Write ::start [[2026-02-17]] 09:00 ::stop [[2026-02-17]] 09:40 ::complete [[2026-02-17]] 10:00 \n" {
+		t.Errorf("after complete: %q", got)
+	}
+}
+
+func TestStartTask_At(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "a.md")
+	os.WriteFile(f, []byte("- [ ] Read ::start [[2026-02-17]] 08:00 ::stop [[2026-02-17]] 09:00\n"), 0644)
+	cfg := Config{StateDir: filepath.Join(dir, "state")}
+	task, _ := readTaskAt(defaultCtx, f, 1)
+
+	if err := checkMarkerOrder(task, "start", time.Date(2026, 2, 17, 8, 30, 0, 0, time.Local), defaultCtx); err == nil {
+		t.Error("a start before the last stop should be refused")
+	}
+	at := time.Date(2026, 2, 17, 9, 15, 0, 0, time.Local)
+	if err := checkMarkerOrder(task, "start", at, defaultCtx); err != nil {
+		t.Fatal(err)
+	}
+	if err := startTask(defaultCtx, cfg, task, at); err != nil {
+		t.Fatal(err)
+	}
+	if ct, _ := ReadCurrentTaskFrom(cfg.StateDir); ct == nil || ct.StartTime != at.Unix() {
+		t.Errorf("state = %+v, want StartTime %d", ct, at.Unix())
+	}
+	if got := readFile(t, f); !strings.HasSuffix(got, "::stop [[2026-02-17]] 09:00 ::start [[2026-02-17]] 09:15 \n") {
+		t.Errorf("line = %q", got)
+	}
+}
+
+func TestCmdCompleteAt_At(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.md")
+	os.WriteFile(path, []byte("- [ ] Call ::start [[2020-01-06]] 09:00\n"), 0644)
+
+	if err := cmdCompleteAt(defaultCtx, []string{path, "1", "--at", "2020-01-06 08:00"}); err == nil {
+		t.Error("want an error for a completion before the start")
+	}
+	if err := cmdCompleteAt(defaultCtx, []string{path, "1", "--at", "2020-01-06 9:20am"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "- [x] Call ::start [[2020-01-06]] 09:00 ::complete [[2020-01-06]] 09:20 \n" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,366 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Scan backends selectable via Config.ScanBackend.
+const (
+	scanBackendAuto = "auto"
+	scanBackendRg   = "rg"
+	scanBackendGo   = "go"
+)
+
+// ignoreFileNames are the per-directory ignore files honored by the built-in
+// walker. .gitignore is only honored inside a git repository, matching rg.
+var ignoreFileNames = []string{".gitignore", ".ignore", ".rgignore"}
+
+// resolveScanBackend maps a configured backend name to "rg" or "go".
+// "auto" (or empty) picks rg when it is on PATH and the built-in walker otherwise.
+func resolveScanBackend(name string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", scanBackendAuto:
+		if rgAvailable() {
+			return scanBackendRg, nil
+		}
+		return scanBackendGo, nil
+	case scanBackendRg, "ripgrep":
+		return scanBackendRg, nil
+	case scanBackendGo, "builtin":
+		return scanBackendGo, nil
+	default:
+		return "", fmt.Errorf("unknown scan backend %q (want auto, rg or go)", name)
+	}
+}
+
+// ignoreRule is a single compiled line from a gitignore-style file.
+type ignoreRule struct {
+	base     string // directory containing the ignore file
+	pattern  string // glob pattern with leading "/" and trailing "/" removed
+	negate   bool   // "!pattern" re-includes a previously ignored path
+	dirOnly  bool   // "pattern/" only matches directories
+	anchored bool   // pattern contains a "/" and is matched against the full relative path
+}
+
+// matches reports whether the rule applies to absPath.
+func (r ignoreRule) matches(absPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+	rel, err := filepath.Rel(r.base, absPath)
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	rel = filepath.ToSlash(rel)
+	if r.anchored {
+		return matchGlobPath(r.pattern, rel)
+	}
+	ok, _ := path.Match(r.pattern, path.Base(rel))
+	return ok
+}
+
+// matchGlobPath matches a slash-separated glob against a slash-separated path,
+// where a "**" segment matches zero or more path segments.
+func matchGlobPath(pattern, name string) bool {
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchSegments(pat, name []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			rest := pat[1:]
+			for i := 0; i <= len(name); i++ {
+				if matchSegments(rest, name[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(name) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pat[0], name[0]); !ok {
+			return false
+		}
+		pat, name = pat[1:], name[1:]
+	}
+	return len(name) == 0
+}
+
+// parseIgnoreFile reads a gitignore-style file into rules relative to its directory.
+// A missing or unreadable file yields no rules.
+func parseIgnoreFile(filePath string) []ignoreRule {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil
+	}
+	base := filepath.Dir(filePath)
+	var rules []ignoreRule
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = strings.TrimRight(line, " \t")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := ignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.pattern = line
+		rules = append(rules, r)
+	}
+	return rules
+}
+
+// ignoreMatcher is a stack of ignore rules; later rules take precedence.
+type ignoreMatcher struct {
+	rules []ignoreRule
+}
+
+func (m *ignoreMatcher) ignored(absPath string, isDir bool) bool {
+	for i := len(m.rules) - 1; i >= 0; i-- {
+		if m.rules[i].matches(absPath, isDir) {
+			return !m.rules[i].negate
+		}
+	}
+	return false
+}
+
+// with returns a new matcher extended with the ignore files found in dir.
+func (m *ignoreMatcher) with(dir string, inGit bool) *ignoreMatcher {
+	var added []ignoreRule
+	for _, name := range ignoreFileNames {
+		if name == ".gitignore" && !inGit {
+			continue
+		}
+		added = append(added, parseIgnoreFile(filepath.Join(dir, name))...)
+	}
+	if len(added) == 0 {
+		return m
+	}
+	rules := make([]ignoreRule, 0, len(m.rules)+len(added))
+	rules = append(rules, m.rules...)
+	rules = append(rules, added...)
+	return &ignoreMatcher{rules: rules}
+}
+
+// gitRootFor returns the nearest ancestor of dir (inclusive) containing a .git
+// entry, or "" when dir is not inside a git repository.
+func gitRootFor(dir string) string {
+	for {
+		if _, err := os.Lstat(filepath.Join(dir, ".git")); err == nil {
+			return dir
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// ancestorMatcher collects ignore rules from the ancestors of root, outermost
+// first, so that rules in parent directories apply the same way rg applies them.
+// .ignore and .rgignore are read all the way up; .gitignore stops at the git root.
+func ancestorMatcher(root, gitRoot string) *ignoreMatcher {
+	var dirs []string
+	for dir := filepath.Dir(root); ; dir = filepath.Dir(dir) {
+		dirs = append(dirs, dir)
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	m := &ignoreMatcher{}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		inGit := gitRoot != "" && (dirs[i] == gitRoot || strings.HasPrefix(dirs[i], gitRoot+string(filepath.Separator)))
+		m = m.with(dirs[i], inGit)
+	}
+	return m
+}
+
+// walkFiles calls fn for every searchable file under roots. Directory roots are
+// walked recursively, skipping hidden entries and anything excluded by
+// .gitignore, .ignore or .rgignore; symlinks below a root are not followed.
+// Roots that name a file are always visited. If match is non-nil, only files
+// whose base name matches it are visited during the recursive walk.
+func walkFiles(roots []string, match func(name string) bool, fn func(path string) error) error {
+	return walkTree(roots, match, nil, fn)
+}
+
+// walkDirs calls fn for every directory walkFiles would descend into,
+// including the directory roots themselves.
+func walkDirs(roots []string, fn func(path string) error) error {
+	return walkTree(roots, nil, fn, func(string) error { return nil })
+}
+
+// walkTree is the shared implementation of walkFiles and walkDirs; dirFn may be nil.
+func walkTree(roots []string, match func(name string) bool, dirFn, fileFn func(path string) error) error {
+	for _, root := range roots {
+		info, err := os.Stat(root)
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() {
+			if err := fileFn(root); err != nil {
+				return err
+			}
+			continue
+		}
+
+		gitRoot := gitRootFor(root)
+		matchers := map[string]*ignoreMatcher{}
+		parent := ancestorMatcher(root, gitRoot)
+
+		err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				if p == root {
+					return err
+				}
+				return nil // unreadable entries are skipped, as rg does
+			}
+			if p != root && strings.HasPrefix(d.Name(), ".") {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			var m *ignoreMatcher
+			if p == root {
+				m = parent
+			} else {
+				m = matchers[filepath.Dir(p)]
+			}
+			if p != root && m.ignored(p, d.IsDir()) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if d.IsDir() {
+				matchers[p] = m.with(p, gitRoot != "")
+				if dirFn != nil {
+					return dirFn(p)
+				}
+				return nil
+			}
+			if !d.Type().IsRegular() {
+				return nil
+			}
+			if match != nil && !match(d.Name()) {
+				return nil
+			}
+			return fileFn(p)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// grepFile returns the lines of filePath matching re, in the same shape rg
+// reports them: 1-based line numbers and text including the line terminator.
+// Files containing a NUL byte are treated as binary and skipped.
+func grepFile(filePath string, re *regexp.Regexp) ([]RawMatch, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	return grepData(filePath, data, re), nil
+}
+
+// grepData is grepFile over already-read file contents.
+func grepData(filePath string, data []byte, re *regexp.Regexp) []RawMatch {
+	if bytes.IndexByte(data, 0) >= 0 {
+		return nil
+	}
+
+	var matches []RawMatch
+	lineNum := 0
+	for len(data) > 0 {
+		lineNum++
+		end := bytes.IndexByte(data, '\n')
+		var line []byte
+		if end < 0 {
+			line, data = data, nil
+		} else {
+			line, data = data[:end+1], data[end+1:]
+		}
+		if re.Match(bytes.TrimRight(line, "\r\n")) {
+			matches = append(matches, RawMatch{
+				Path:       filePath,
+				LineNumber: lineNum,
+				Text:       string(line),
+			})
+		}
+	}
+	return matches
+}
+
+// scanWalk is the pure-Go equivalent of the rg invocation in Scan.
+func scanWalk(pattern string, paths []string) ([]RawMatch, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compiling scan pattern: %w", err)
+	}
+	var matches []RawMatch
+	err = walkFiles(paths, nil, func(p string) error {
+		found, err := grepFile(p, re)
+		if err != nil {
+			return nil // unreadable files are skipped
+		}
+		matches = append(matches, found...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking notes: %w", err)
+	}
+	return matches, nil
+}
+
+// listFilesWalk is the pure-Go equivalent of `rg -l -e pattern --glob glob`.
+func listFilesWalk(pattern, glob string, paths []string) ([]string, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("compiling pattern: %w", err)
+	}
+	matchName := func(name string) bool {
+		ok, _ := path.Match(glob, name)
+		return ok
+	}
+	var files []string
+	err = walkFiles(paths, matchName, func(p string) error {
+		found, err := grepFile(p, re)
+		if err == nil && len(found) > 0 {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking notes: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// sortedMatchKeys renders matches as "path:line:text" strings in a stable order
+// so results from different backends can be compared directly.
+func sortedMatchKeys(matches []RawMatch) []string {
+	keys := make([]string, len(matches))
+	for i, m := range matches {
+		keys[i] = fmt.Sprintf("%s:%d:%s", m.Path, m.LineNumber, m.Text)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// writeTree creates files (relative path -> content) under dir.
+func writeTree(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		p := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// walkScanFiles scans dir with the go backend and returns the base names of
+// files that produced matches.
+func walkScanFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	ctx := NewParseContext(Config{ScanBackend: "go"})
+	matches, err := Scan(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[string]bool{}
+	var names []string
+	for _, m := range matches {
+		rel, _ := filepath.Rel(dir, m.Path)
+		if !seen[rel] {
+			seen[rel] = true
+			names = append(names, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestScanBackends_IdenticalOnFixtureVaults(t *testing.T) {
+	if !rgAvailable() {
+		t.Skip("rg not on PATH")
+	}
+	entries, err := os.ReadDir(testdataDir(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := map[string]Config{
+		"default": {},
+		"custom":  {Checkbox: map[string]string{"open": "* [ ]", "done": "* [x]", "irrelevant": "* [-]"}},
+	}
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		vault := filepath.Join(testdataDir(t), e.Name())
+		for cfgName, cfg := range configs {
+			t.Run(e.Name()+"/"+cfgName, func(t *testing.T) {
+				cfg.ScanBackend = "rg"
+				rgMatches, err := Scan(NewParseContext(cfg), vault)
+				if err != nil {
+					t.Fatalf("rg scan: %v", err)
+				}
+				cfg.ScanBackend = "go"
+				goMatches, err := Scan(NewParseContext(cfg), vault)
+				if err != nil {
+					t.Fatalf("go scan: %v", err)
+				}
+				rgKeys, goKeys := sortedMatchKeys(rgMatches), sortedMatchKeys(goMatches)
+				if strings.Join(rgKeys, "\n") != strings.Join(goKeys, "\n") {
+					t.Errorf("backends differ\nrg:\n%s\ngo:\n%s", strings.Join(rgKeys, "\n"), strings.Join(goKeys, "\n"))
+				}
+			})
+		}
+	}
+}
+
+func TestScanProjectsBackends_IdenticalOnFixtureVaults(t *testing.T) {
+	if !rgAvailable() {
+		t.Skip("rg not on PATH")
+	}
+	for _, name := range []string{"fm-due-vault", "frontmatter-vault", "frontmatter-edge-vault"} {
+		t.Run(name, func(t *testing.T) {
+			vault := vaultPath(t, name)
+			rgTasks, err := ScanProjectsWith("rg", "2006-01-02", FrontmatterConfig{}, nil, vault)
+			if err != nil {
+				t.Fatal(err)
+			}
+			goTasks, err := ScanProjectsWith("go", "2006-01-02", FrontmatterConfig{}, nil, vault)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(rgTasks) != len(goTasks) {
+				t.Fatalf("rg found %d projects, go found %d", len(rgTasks), len(goTasks))
+			}
+			sort.Slice(rgTasks, func(i, j int) bool { return rgTasks[i].FilePath < rgTasks[j].FilePath })
+			sort.Slice(goTasks, func(i, j int) bool { return goTasks[i].FilePath < goTasks[j].FilePath })
+			for i := range rgTasks {
+				if rgTasks[i].FilePath != goTasks[i].FilePath || rgTasks[i].Body != goTasks[i].Body {
+					t.Errorf("project %d: rg=%s go=%s", i, rgTasks[i].FilePath, goTasks[i].FilePath)
+				}
+			}
+		})
+	}
+}
+
+func TestScanGo_MatchesFixtureVault(t *testing.T) {
+	ctx := NewParseContext(Config{ScanBackend: "go"})
+	tasks := scanAndParseWith(t, ctx, vaultPath(t, "basic-vault"))
+	if len(tasks) != 7 {
+		t.Fatalf("got %d tasks, want 7", len(tasks))
+	}
+}
+
+func TestScanGo_LineNumbersAndText(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"a.md": "# Title\r\n- [ ] CRLF task\r\ntext\n- [x] Last line without newline",
+	})
+	ctx := NewParseContext(Config{ScanBackend: "go"})
+	matches, err := Scan(ctx, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches, want 2", len(matches))
+	}
+	if matches[0].LineNumber != 2 || matches[0].Text != "- [ ] CRLF task\r\n" {
+		t.Errorf("match 0 = %d %q", matches[0].LineNumber, matches[0].Text)
+	}
+	if matches[1].LineNumber != 4 || matches[1].Text != "- [x] Last line without newline" {
+		t.Errorf("match 1 = %d %q", matches[1].LineNumber, matches[1].Text)
+	}
+}
+
+func TestScanGo_SkipsHiddenAndBinary(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"visible.md":         "- [ ] Visible\n",
+		".hidden.md":         "- [ ] Hidden file\n",
+		".obsidian/cache.md": "- [ ] Hidden dir\n",
+		"binary.dat":         "- [ ] Binary\x00\n",
+	})
+	got := walkScanFiles(t, dir)
+	if strings.Join(got, ",") != "visible.md" {
+		t.Errorf("scanned files = %v, want [visible.md]", got)
+	}
+}
+
+func TestScanGo_HonorsIgnoreFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".ignore":           "archive/\n*.bak\n!keep.bak\n",
+		"notes.md":          "- [ ] Keep\n",
+		"old.bak":           "- [ ] Ignored by glob\n",
+		"keep.bak":          "- [ ] Re-included\n",
+		"archive/2020.md":   "- [ ] Ignored dir\n",
+		"sub/.rgignore":     "/local.md\n",
+		"sub/local.md":      "- [ ] Ignored anchored\n",
+		"sub/deep/local.md": "- [ ] Anchored rule does not reach here\n",
+	})
+	got := walkScanFiles(t, dir)
+	want := "keep.bak,notes.md,sub/deep/local.md"
+	if strings.Join(got, ",") != want {
+		t.Errorf("scanned files = %v, want %s", got, want)
+	}
+}
+
+func TestScanGo_GitignoreOnlyInsideRepo(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":  "drafts/\n",
+		"drafts/a.md": "- [ ] Draft\n",
+		"main.md":     "- [ ] Main\n",
+	})
+	// Outside a git repository .gitignore is not honored (same as rg)
+	if got := walkScanFiles(t, dir); len(got) != 2 {
+		t.Errorf("outside repo: scanned files = %v, want 2", got)
+	}
+
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if got := walkScanFiles(t, dir); strings.Join(got, ",") != "main.md" {
+		t.Errorf("inside repo: scanned files = %v, want [main.md]", got)
+	}
+}
+
+func TestScanGo_ParentGitignoreApplies(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		".gitignore":           "**/tmp/*.md\n",
+		"vault/tmp/scratch.md": "- [ ] Scratch\n",
+		"vault/real.md":        "- [ ] Real\n",
+	})
+	os.Mkdir(filepath.Join(dir, ".git"), 0755)
+	got := walkScanFiles(t, filepath.Join(dir, "vault"))
+	if strings.Join(got, ",") != "real.md" {
+		t.Errorf("scanned files = %v, want [real.md]", got)
+	}
+}
+
+func TestScanGo_NonExistentPath(t *testing.T) {
+	ctx := NewParseContext(Config{ScanBackend: "go"})
+	if _, err := Scan(ctx, "/nonexistent/path/that/does/not/exist"); err == nil {
+		t.Error("expected error for non-existent path")
+	}
+}
+
+func TestScanProjectsGo_OnlyMarkdown(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{
+		"proj.md":  "---\ntags:\n  - project\ndue: 2026-03-01\n---\n",
+		"proj.txt": "---\ntags:\n  - project\ndue: 2026-03-01\n---\n",
+	})
+	tasks, err := ScanProjectsWith("go", "2006-01-02", FrontmatterConfig{}, nil, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 1 || tasks[0].Body != "proj" {
+		t.Errorf("got %+v, want single project 'proj'", tasks)
+	}
+}
+
+func TestResolveScanBackend(t *testing.T) {
+	for _, name := range []string{"go", "builtin", "GO"} {
+		if got, err := resolveScanBackend(name); err != nil || got != scanBackendGo {
+			t.Errorf("resolveScanBackend(%q) = %q, %v", name, got, err)
+		}
+	}
+	if got, _ := resolveScanBackend("ripgrep"); got != scanBackendRg {
+		t.Errorf("ripgrep resolved to %q", got)
+	}
+	want := scanBackendGo
+	if rgAvailable() {
+		want = scanBackendRg
+	}
+	if got, _ := resolveScanBackend(""); got != want {
+		t.Errorf("auto resolved to %q, want %q", got, want)
+	}
+	if _, err := resolveScanBackend("grep"); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+}
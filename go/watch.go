@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// taskfileSeparator terminates each taskfile snapshot in `task watch` output.
+const taskfileSeparator = "\f"
+
+const defaultWatchDebounce = 200 * time.Millisecond
+
+// dirWatcher delivers the paths of changed files and directories below the
+// watched roots. Implementations are platform specific (see watch_linux.go).
+type dirWatcher interface {
+	Events() <-chan string
+	Close() error
+}
+
+// TaskDiff lists the tasks that appeared, disappeared or changed between two scans.
+type TaskDiff struct {
+	Added   []TaskJSON `json:"added"`
+	Removed []TaskJSON `json:"removed"`
+	Changed []TaskJSON `json:"changed"`
+}
+
+// Empty reports whether the diff contains no changes.
+func (d TaskDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+// taskIdentities keys tasks by file and body, numbering repeated bodies within
+// a file in line order, so a task keeps its identity when lines above it are
+// inserted or removed.
+func taskIdentities(tasks []Task, dateFmt string) (map[string]TaskJSON, []string) {
+	sorted := append([]Task(nil), tasks...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].FilePath != sorted[j].FilePath {
+			return sorted[i].FilePath < sorted[j].FilePath
+		}
+		return sorted[i].LineNumber < sorted[j].LineNumber
+	})
+	byKey := make(map[string]TaskJSON, len(sorted))
+	keys := make([]string, 0, len(sorted))
+	seen := make(map[string]int)
+	for _, t := range sorted {
+		base := t.FilePath + "\x00" + t.Body
+		key := fmt.Sprintf("%s\x00%d", base, seen[base])
+		seen[base]++
+		byKey[key] = toTaskJSON(t, dateFmt, "")
+		keys = append(keys, key)
+	}
+	return byKey, keys
+}
+
+// DiffTasks compares two task lists. A task whose file and body are unchanged
+// but whose line, date, time, duration, tags, status or markers differ is
+// reported as changed.
+func DiffTasks(before, after []Task, dateFmt string) TaskDiff {
+	oldByKey, oldKeys := taskIdentities(before, dateFmt)
+	newByKey, newKeys := taskIdentities(after, dateFmt)
+
+	diff := TaskDiff{Added: []TaskJSON{}, Removed: []TaskJSON{}, Changed: []TaskJSON{}}
+	for _, k := range newKeys {
+		old, ok := oldByKey[k]
+		if !ok {
+			diff.Added = append(diff.Added, newByKey[k])
+		} else if !reflect.DeepEqual(old, newByKey[k]) {
+			diff.Changed = append(diff.Changed, newByKey[k])
+		}
+	}
+	for _, k := range oldKeys {
+		if _, ok := newByKey[k]; !ok {
+			diff.Removed = append(diff.Removed, oldByKey[k])
+		}
+	}
+	return diff
+}
+
+// isWatchedChange reports whether a changed path should trigger a rescan:
+// markdown files and directories (new directories may contain notes), but not
+// hidden files such as editor swap files.
+func isWatchedChange(path string) bool {
+	base := filepath.Base(path)
+	if strings.HasPrefix(base, ".") {
+		return false
+	}
+	if strings.HasSuffix(base, ".md") {
+		return true
+	}
+	info, err := os.Stat(path)
+	return err == nil && info.IsDir()
+}
+
+// watchLoop calls emit once after each burst of relevant events, waiting for
+// debounce of quiet before firing. It returns when events is closed.
+func watchLoop(events <-chan string, debounce time.Duration, emit func() error) error {
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	pending := false
+	for {
+		select {
+		case path, ok := <-events:
+			if !ok {
+				if pending {
+					return emit()
+				}
+				return nil
+			}
+			if !isWatchedChange(path) {
+				continue
+			}
+			if pending && !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(debounce)
+			pending = true
+		case <-timer.C:
+			pending = false
+			if err := emit(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// cmdWatch streams taskfile updates whenever a markdown file under the source
+// directories changes. With --format taskfile (the default) each update is the
+// full taskfile followed by a form-feed line; with --format diff each update is
+// one JSON line listing added, removed and changed tasks. Remaining arguments
+// are passed to the list pipeline (e.g. --tag, --markers).
+func cmdWatch(notesPaths []string, ctx *ParseContext, args []string, cfg Config) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+	format := fs.String("format", "taskfile", "output format: taskfile or diff")
+	debounce := fs.Duration("debounce", defaultWatchDebounce, "quiet period before re-emitting")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *format != "taskfile" && *format != "diff" {
+		return fmt.Errorf("unknown watch format %q (want taskfile or diff)", *format)
+	}
+
+	roots := expandGlobs(notesPaths)
+	w, err := newDirWatcher(roots)
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	defer useWarmIndex(ctx, cfg)()
+
+	emit := watchEmitter(os.Stdout, notesPaths, ctx, cfg, *format, fs.Args())
+	if err := emit(); err != nil {
+		return err
+	}
+	return watchLoop(w.Events(), *debounce, emit)
+}
+
+// watchEmitter returns a function that rescans and writes one update to out.
+// In diff mode the first call reports every task as added, and calls that find
+// no changes write nothing.
+func watchEmitter(out io.Writer, notesPaths []string, ctx *ParseContext, cfg Config, format string, listArgs []string) func() error {
+	var previous []Task
+	started := false
+	return func() error {
+		if format == "taskfile" {
+			taskfile, err := renderList(notesPaths, ctx, listArgs, cfg)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "taskbuffer: %v\n", err)
+				return nil
+			}
+			_, err = fmt.Fprintf(out, "%s%s\n", taskfile, taskfileSeparator)
+			return err
+		}
+
+		tasks, err := loadOpenTasks(notesPaths, ctx, cfg)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "taskbuffer: %v\n", err)
+			return nil
+		}
+		diff := DiffTasks(previous, tasks, ctx.formats.GoDate)
+		previous = tasks
+		if diff.Empty() && started {
+			return nil
+		}
+		started = true
+		data, err := json.Marshal(diff)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(out, "%s\n", data)
+		return err
+	}
+}
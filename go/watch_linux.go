@@ -0,0 +1,128 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_CREATE | syscall.IN_DELETE |
+	syscall.IN_MOVED_FROM | syscall.IN_MOVED_TO | syscall.IN_DELETE_SELF
+
+// inotifyWatcher watches a set of directories with Linux inotify, adding
+// watches for subdirectories as they are created.
+type inotifyWatcher struct {
+	file   *os.File
+	fd     int
+	events chan string
+
+	mu   sync.Mutex
+	dirs map[int32]string // watch descriptor -> directory
+}
+
+// newDirWatcher watches every directory below roots that the scanner would
+// visit. File roots are covered by watching their parent directory.
+func newDirWatcher(roots []string) (dirWatcher, error) {
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("inotify init: %w", err)
+	}
+	w := &inotifyWatcher{
+		file:   os.NewFile(uintptr(fd), "inotify"),
+		fd:     fd,
+		events: make(chan string, 64),
+		dirs:   make(map[int32]string),
+	}
+
+	var dirRoots []string
+	for _, r := range roots {
+		info, err := os.Stat(r)
+		if err != nil {
+			w.Close()
+			return nil, err
+		}
+		if info.IsDir() {
+			dirRoots = append(dirRoots, r)
+		} else if err := w.add(filepath.Dir(r)); err != nil {
+			w.Close()
+			return nil, err
+		}
+	}
+	if err := walkDirs(dirRoots, w.add); err != nil {
+		w.Close()
+		return nil, err
+	}
+
+	go w.run()
+	return w, nil
+}
+
+func (w *inotifyWatcher) add(dir string) error {
+	wd, err := syscall.InotifyAddWatch(w.fd, dir, inotifyMask)
+	if err != nil {
+		return fmt.Errorf("watching %s: %w", dir, err)
+	}
+	w.mu.Lock()
+	w.dirs[int32(wd)] = dir
+	w.mu.Unlock()
+	return nil
+}
+
+func (w *inotifyWatcher) Events() <-chan string { return w.events }
+
+func (w *inotifyWatcher) Close() error {
+	return w.file.Close()
+}
+
+// run reads raw inotify events until the watcher is closed, translating them
+// into paths on the events channel.
+func (w *inotifyWatcher) run() {
+	defer close(w.events)
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			if !errors.Is(err, os.ErrClosed) && Verbose {
+				fmt.Fprintf(os.Stderr, "taskbuffer: inotify read: %v\n", err)
+			}
+			return
+		}
+		for off := 0; off+syscall.SizeofInotifyEvent <= n; {
+			wd := int32(binary.NativeEndian.Uint32(buf[off:]))
+			mask := binary.NativeEndian.Uint32(buf[off+4:])
+			nameLen := int(binary.NativeEndian.Uint32(buf[off+12:]))
+			nameStart := off + syscall.SizeofInotifyEvent
+			name := strings.TrimRight(string(buf[nameStart:nameStart+nameLen]), "\x00")
+			off = nameStart + nameLen
+
+			w.mu.Lock()
+			dir, ok := w.dirs[wd]
+			if mask&syscall.IN_IGNORED != 0 {
+				delete(w.dirs, wd)
+			}
+			w.mu.Unlock()
+			if !ok {
+				continue
+			}
+
+			path := dir
+			if name != "" {
+				path = filepath.Join(dir, name)
+			}
+			if mask&syscall.IN_ISDIR != 0 && mask&(syscall.IN_CREATE|syscall.IN_MOVED_TO) != 0 &&
+				!strings.HasPrefix(name, ".") {
+				// Watch the new subtree; files written before the watch was
+				// added are picked up by the rescan this event triggers.
+				walkDirs([]string{path}, w.add)
+			}
+			w.events <- path
+		}
+	}
+}
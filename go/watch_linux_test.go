@@ -0,0 +1,76 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// waitForEvent reads events until one equals want or the timeout expires.
+func waitForEvent(t *testing.T, events <-chan string, want string) {
+	t.Helper()
+	timeout := time.After(2 * time.Second)
+	for {
+		select {
+		case got, ok := <-events:
+			if !ok {
+				t.Fatalf("events closed before %s", want)
+			}
+			if got == want {
+				return
+			}
+		case <-timeout:
+			t.Fatalf("no event for %s", want)
+		}
+	}
+}
+
+func TestInotifyWatcher_ReportsWrites(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"sub/a.md": "- [ ] A\n"})
+	w, err := newDirWatcher([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	target := filepath.Join(dir, "sub", "a.md")
+	os.WriteFile(target, []byte("- [ ] A changed\n"), 0644)
+	waitForEvent(t, w.Events(), target)
+}
+
+func TestInotifyWatcher_FollowsNewDirectories(t *testing.T) {
+	dir := t.TempDir()
+	w, err := newDirWatcher([]string{dir})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	newDir := filepath.Join(dir, "new")
+	os.Mkdir(newDir, 0755)
+	waitForEvent(t, w.Events(), newDir)
+
+	target := filepath.Join(newDir, "b.md")
+	os.WriteFile(target, []byte("- [ ] B\n"), 0644)
+	waitForEvent(t, w.Events(), target)
+}
+
+func TestInotifyWatcher_CloseEndsEvents(t *testing.T) {
+	w, err := newDirWatcher([]string{t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	select {
+	case _, ok := <-w.Events():
+		if ok {
+			t.Error("unexpected event after close")
+		}
+	case <-time.After(2 * time.Second):
+		t.Error("events channel not closed after Close")
+	}
+}
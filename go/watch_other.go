@@ -0,0 +1,10 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+// newDirWatcher is only implemented on Linux, where it uses inotify.
+func newDirWatcher(roots []string) (dirWatcher, error) {
+	return nil, fmt.Errorf("task watch requires Linux inotify")
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiffTasks_AddedRemovedChanged(t *testing.T) {
+	d1 := time.Date(2026, 2, 17, 0, 0, 0, 0, time.UTC)
+	d2 := time.Date(2026, 2, 18, 0, 0, 0, 0, time.UTC)
+	before := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Keep", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Reschedule", DueDate: &d1, Status: "open"},
+		{FilePath: "/a.md", LineNumber: 3, Body: "Delete me", Status: "open"},
+	}
+	after := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Keep", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Reschedule", DueDate: &d2, Status: "open"},
+		{FilePath: "/b.md", LineNumber: 1, Body: "Brand new", Status: "open"},
+	}
+	diff := DiffTasks(before, after, "2006-01-02")
+
+	if len(diff.Added) != 1 || diff.Added[0].Body != "Brand new" {
+		t.Errorf("added = %+v", diff.Added)
+	}
+	if len(diff.Removed) != 1 || diff.Removed[0].Body != "Delete me" {
+		t.Errorf("removed = %+v", diff.Removed)
+	}
+	if len(diff.Changed) != 1 || diff.Changed[0].Due != "2026-02-18" {
+		t.Errorf("changed = %+v", diff.Changed)
+	}
+}
+
+func TestDiffTasks_LineShiftIsChangeNotReplace(t *testing.T) {
+	before := []Task{{FilePath: "/a.md", LineNumber: 1, Body: "Task", Status: "open"}}
+	after := []Task{{FilePath: "/a.md", LineNumber: 4, Body: "Task", Status: "open"}}
+	diff := DiffTasks(before, after, "2006-01-02")
+	if len(diff.Added) != 0 || len(diff.Removed) != 0 || len(diff.Changed) != 1 {
+		t.Errorf("diff = %+v, want a single change", diff)
+	}
+	if diff.Changed[0].Line != 4 {
+		t.Errorf("changed line = %d, want 4", diff.Changed[0].Line)
+	}
+}
+
+func TestDiffTasks_DuplicateBodies(t *testing.T) {
+	before := []Task{
+		{FilePath: "/a.md", LineNumber: 1, Body: "Water plants", Status: "open"},
+		{FilePath: "/a.md", LineNumber: 2, Body: "Water plants", Status: "open"},
+	}
+	after := before[:1]
+	diff := DiffTasks(before, after, "2006-01-02")
+	if len(diff.Removed) != 1 || diff.Removed[0].Line != 2 {
+		t.Errorf("removed = %+v, want the second duplicate", diff.Removed)
+	}
+	if !DiffTasks(before, before, "2006-01-02").Empty() {
+		t.Error("identical lists should produce an empty diff")
+	}
+}
+
+func TestIsWatchedChange(t *testing.T) {
+	dir := t.TempDir()
+	cases := map[string]bool{
+		filepath.Join(dir, "note.md"):      true,
+		filepath.Join(dir, ".note.md.swp"): false,
+		filepath.Join(dir, "4913"):         false,
+		filepath.Join(dir, "image.png"):    false,
+		dir:                                true,
+	}
+	for path, want := range cases {
+		if got := isWatchedChange(path); got != want {
+			t.Errorf("isWatchedChange(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestWatchLoop_DebouncesBursts(t *testing.T) {
+	dir := t.TempDir()
+	events := make(chan string)
+	emits := make(chan struct{}, 10)
+	done := make(chan error)
+	go func() {
+		done <- watchLoop(events, 50*time.Millisecond, func() error {
+			emits <- struct{}{}
+			return nil
+		})
+	}()
+
+	for i := 0; i < 5; i++ {
+		events <- filepath.Join(dir, "a.md")
+		time.Sleep(5 * time.Millisecond)
+	}
+	events <- filepath.Join(dir, ".swap") // ignored
+	select {
+	case <-emits:
+	case <-time.After(time.Second):
+		t.Fatal("no emit after burst")
+	}
+	select {
+	case <-emits:
+		t.Error("burst produced more than one emit")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(events)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatchEmitter_DiffMode(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] One\n"})
+	var out bytes.Buffer
+	emit := watchEmitter(&out, []string{dir}, DefaultParseContext(), Config{}, "diff", nil)
+
+	if err := emit(); err != nil {
+		t.Fatal(err)
+	}
+	// No change: nothing written
+	if err := emit(); err != nil {
+		t.Fatal(err)
+	}
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] One\n- [ ] Two\n"})
+	if err := emit(); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d updates, want 2:\n%s", len(lines), out.String())
+	}
+	var first, second TaskDiff
+	json.Unmarshal([]byte(lines[0]), &first)
+	json.Unmarshal([]byte(lines[1]), &second)
+	if len(first.Added) != 1 || first.Added[0].Body != "One" {
+		t.Errorf("initial update = %s", lines[0])
+	}
+	if len(second.Added) != 1 || second.Added[0].Body != "Two" || len(second.Changed) != 0 {
+		t.Errorf("second update = %s", lines[1])
+	}
+}
+
+func TestWatchEmitter_TaskfileMode(t *testing.T) {
+	dir := t.TempDir()
+	writeTree(t, dir, map[string]string{"a.md": "- [ ] One\n"})
+	var out bytes.Buffer
+	emit := watchEmitter(&out, []string{dir}, DefaultParseContext(), Config{}, "taskfile", []string{"--markers"})
+	emit()
+	emit()
+	snapshots := strings.Split(out.String(), taskfileSeparator+"\n")
+	if len(snapshots) != 3 || snapshots[2] != "" {
+		t.Fatalf("want two separated snapshots, got %q", out.String())
+	}
+	if !strings.Contains(snapshots[0], "One") {
+		t.Errorf("snapshot = %q", snapshots[0])
+	}
+}
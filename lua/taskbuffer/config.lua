@@ -13,6 +13,7 @@
 ---@field marker_prefix string prefix for state markers
 
 ---@class TaskbufferGlobalKeymaps
+---@field start_task string|false
 ---@field complete string|false
 ---@field defer string|false
 ---@field check_off string|false
@@ -32,6 +33,7 @@
 ---@field shift_date_back string|false
 ---@field shift_date_forward string|false
 ---@field set_date_today string|false
+---@field set_priority string|false
 ---@field quickfix string|false
 ---@field undo string|boolean|false
 ---@field redo string|boolean|false
@@ -56,6 +58,11 @@
 ---@field require_tags string[] frontmatter tags required for inheritance (empty = all files)
 ---@field status TaskbufferFrontmatterStatus status configuration
 
+---@class TaskbufferSubtasks
+---@field inherit_due boolean undated subtasks take the parent's due date
+---@field inherit_tags boolean subtasks carry the parent's tags as well
+---@field cascade_complete string completing a parent completes its open subtasks: "ask"|"always"|"never"
+
 ---@class TaskbufferInbox
 ---@field file string path to inbox markdown file
 ---@field header string|nil optional heading to insert below
@@ -66,13 +73,21 @@
 ---@field tmpdir string directory for temporary taskfile output
 ---@field show_undated boolean whether to show undated tasks by default
 ---@field sources string[] directories or glob patterns to scan
+---@field scan_backend string scanner: "auto"|"rg"|"go"
+---@field index boolean cache parsed tasks in state_dir and only re-parse changed files
+---@field recur_from string next occurrence of a recurring task counts from "due" or "completion"
+---@field skip_blocks string[]|false markdown blocks whose checkboxes are not tasks: "fenced"|"indented"|"comment"
+---@field priority_syntax string[]|false priority syntaxes to recognize: "bang"|"letter"|"field"
+---@field sort string order within a horizon: "date"|"priority"
 ---@field inbox TaskbufferInbox default location for new tasks
 ---@field formats TaskbufferFormats task syntax formats
 ---@field keymaps TaskbufferKeymaps keymap bindings
----@field horizons table[]|nil horizon specs (label, after, undated, order)
+---@field horizons table[]|nil horizon specs (label, after, undated, untimed, order)
 ---@field horizons_overlap string overlap strategy: "sorted"|"first_match"|"narrowest"
+---@field overdue_by_time boolean tasks due today at a time already past count as overdue
 ---@field week_start string first day of the week: "monday"|"sunday"|etc.
 ---@field frontmatter TaskbufferFrontmatter frontmatter configuration
+---@field subtasks TaskbufferSubtasks indented subtasks
 
 ---@class TaskbufferConfigModule
 ---@field defaults TaskbufferConfig
@@ -95,10 +110,30 @@ M.defaults = {
     horizons = nil,
     horizons_overlap = "sorted",
     week_start = "monday",
+    overdue_by_time = false,
 
     -- Task sources: directories (recursive) or glob patterns
     sources = { "~/Documents/Notes" },
 
+    -- Scanner backend: "auto" (rg if installed, else built-in), "rg", or "go"
+    scan_backend = "auto",
+
+    -- Persistent task index in state_dir (only changed files are re-parsed)
+    index = false,
+
+    -- Recurring tasks: next occurrence counts from "due" or "completion" date
+    recur_from = "due",
+
+    -- Checkboxes in these markdown blocks are examples, not tasks (false = none)
+    skip_blocks = { "fenced", "indented", "comment" },
+
+    -- Priority syntaxes: "bang" (!!! !! !), "letter" ((A) (B) (C)) and
+    -- "field" (priority:: high); the first is used when setting one (false = none)
+    priority_syntax = { "bang", "letter", "field" },
+
+    -- Order within a horizon: "date" or "priority"
+    sort = "date",
+
     -- Default location for new tasks created via `task create`
     inbox = {
         file = "~/Documents/Notes/inbox.md",
@@ -116,6 +151,13 @@ M.defaults = {
         },
     },
 
+    -- Subtasks: checkboxes indented under another task
+    subtasks = {
+        inherit_due = false,
+        inherit_tags = false,
+        cascade_complete = "ask",
+    },
+
     -- Task syntax formats (passed to Go binary)
     formats = {
         date = "%Y-%m-%d",
@@ -130,6 +172,7 @@ M.defaults = {
     -- Keymaps: set to false to disable, or override the key string
     keymaps = {
         global = {
+            start_task = "<leader>tb",
             complete = "<leader>tc",
             defer = "<leader>td",
             check_off = "<leader>tx",
@@ -149,6 +192,7 @@ M.defaults = {
             shift_date_back = "<M-Left>",
             shift_date_forward = "<M-Right>",
             set_date_today = "<C-T>",
+            set_priority = "<leader>tp",
             quickfix = "<M-C-q>",
             undo = true,
             redo = true,
@@ -261,6 +305,37 @@ function M.config_json_arg()
     if M.values.week_start ~= "monday" then
         cfg.week_start = M.values.week_start
     end
+    if M.values.overdue_by_time then
+        cfg.overdue_by_time = true
+    end
+    if M.values.scan_backend and M.values.scan_backend ~= "auto" then
+        cfg.scan_backend = M.values.scan_backend
+    end
+    if M.values.index then
+        cfg.index = true
+    end
+    if M.values.recur_from and M.values.recur_from ~= "due" then
+        cfg.recur_from = M.values.recur_from
+    end
+    local skip = M.values.skip_blocks
+    if not skip or #skip == 0 then
+        cfg.skip_blocks = { "none" }
+    elseif not vim.deep_equal(skip, M.defaults.skip_blocks) then
+        cfg.skip_blocks = skip
+    end
+    local prio = M.values.priority_syntax
+    if not prio or #prio == 0 then
+        cfg.priority_syntax = { "none" }
+    elseif not vim.deep_equal(prio, M.defaults.priority_syntax) then
+        cfg.priority_syntax = prio
+    end
+    if M.values.sort and M.values.sort ~= "date" then
+        cfg.sort = M.values.sort
+    end
+    local sub = M.values.subtasks
+    if sub and (sub.inherit_due or sub.inherit_tags) then
+        cfg.subtasks = { inherit_due = sub.inherit_due, inherit_tags = sub.inherit_tags }
+    end
     local fm = M.values.frontmatter
     if fm then
         cfg.frontmatter = {
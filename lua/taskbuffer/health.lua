@@ -24,8 +24,12 @@ function M.check()
     -- 3. ripgrep
     if vim.fn.executable("rg") == 1 then
         vim.health.ok("ripgrep (rg) found")
-    else
+    elseif config.scan_backend == "rg" then
         vim.health.error("ripgrep (rg) not found", { "Install ripgrep: https://github.com/BurntSushi/ripgrep" })
+    else
+        vim.health.warn("ripgrep (rg) not found, using the built-in scanner", {
+            "Install ripgrep for faster scans: https://github.com/BurntSushi/ripgrep",
+        })
     end
 
     -- 4. Source directories
@@ -63,7 +63,8 @@ local function shift_task_dates_bulk(lines, days)
     local fm_shifted_files = {} -- track FM edits to deduplicate per file
     for _, line in ipairs(lines) do
         local filepath, linenumber = util.parse_taskfile_line(line)
-        if filepath and linenumber then
+        -- note lines point at their task's line; only edit it once
+        if filepath and linenumber and util.taskfile_line_body(line) then
             local source_line = util.read_line_from_file(filepath, linenumber)
             if source_line then
                 local new_line = util.shift_date_in_string(source_line, days)
@@ -117,7 +118,19 @@ end
 --- Get filepath and linenumber from a taskfile line.
 local function get_task_location_from_taskfile()
     local line = vim.fn.getline(".")
-    return util.parse_taskfile_line(line)
+    local filepath, linenumber = util.parse_taskfile_line(line)
+    return filepath, linenumber, util.taskfile_line_fingerprint(line)
+end
+
+--- Build mutation args for a taskfile line, guarded by the task's fingerprint
+--- so a stale taskfile cannot edit the wrong line.
+local function taskfile_task_args(cmd)
+    local filepath, linenumber, fingerprint = get_task_location_from_taskfile()
+    local args = { cmd, filepath, tostring(linenumber) }
+    if fingerprint then
+        vim.list_extend(args, { "--expect", fingerprint })
+    end
+    return args
 end
 
 local function get_task_location_from_current_buffer()
@@ -126,6 +139,29 @@ local function get_task_location_from_current_buffer()
     return filepath, linenumber
 end
 
+--- Append --cascade to a complete/check command when the task under the
+--- cursor has open subtasks and `subtasks.cascade_complete` allows it
+--- ("always", or "ask" and the user agrees).
+---@param args string[]
+---@param linenumber integer
+---@return string[]
+local function with_cascade(args, linenumber)
+    local cfg = get_config()
+    local mode = cfg.subtasks and cfg.subtasks.cascade_complete or "ask"
+    if mode == "never" then
+        return args
+    end
+    local lines = vim.api.nvim_buf_get_lines(0, 0, -1, false)
+    local n = util.open_subtask_count(lines, linenumber, cfg.formats.checkbox)
+    if n == 0 then
+        return args
+    end
+    if mode == "always" or vim.fn.confirm(("Also complete %d open subtasks?"):format(n), "&Yes\n&No", 2) == 1 then
+        table.insert(args, "--cascade")
+    end
+    return args
+end
+
 local function shift_task_date_in_taskfile(days)
     local buffer = require("taskbuffer.buffer")
     local cfg = get_config()
@@ -188,7 +224,8 @@ local function set_task_dates_today_bulk(lines)
     local fm_set_files = {} -- track FM edits to deduplicate per file
     for _, line in ipairs(lines) do
         local filepath, linenumber = util.parse_taskfile_line(line)
-        if filepath and linenumber then
+        -- note lines point at their task's line; only edit it once
+        if filepath and linenumber and util.taskfile_line_body(line) then
             local source_line = util.read_line_from_file(filepath, linenumber)
             if source_line then
                 local new_line = util.set_date_today_in_string(source_line)
@@ -347,7 +384,13 @@ function M.setup_keymaps()
 
     map("n", "global", "complete", function()
         local filepath, linenumber = get_task_location_from_current_buffer()
-        util.run_task_cmd({ "complete-at", filepath, tostring(linenumber) }, false)
+        util.run_task_cmd(with_cascade({ "complete-at", filepath, tostring(linenumber) }, linenumber), false)
+        vim.cmd("edit!")
+    end)
+
+    map("n", "global", "start_task", function()
+        local filepath, linenumber = get_task_location_from_current_buffer()
+        util.run_task_cmd({ "start-at", filepath, tostring(linenumber) }, false)
         vim.cmd("edit!")
     end)
 
@@ -359,7 +402,7 @@ function M.setup_keymaps()
 
     map("n", "global", "check_off", function()
         local filepath, linenumber = get_task_location_from_current_buffer()
-        util.run_task_cmd({ "check", filepath, tostring(linenumber) }, false)
+        util.run_task_cmd(with_cascade({ "check", filepath, tostring(linenumber) }, linenumber), false)
         vim.cmd("edit!")
     end)
 
@@ -380,39 +423,8 @@ function M.setup_keymaps()
         group = augroup,
         pattern = { "taskfile" },
         callback = function()
-            local config = get_config()
-            local state_path = config.state_dir .. "/current_task"
-
             map("n", "taskfile", "start_task", function()
-                local f = io.open(state_path, "r")
-                if f then
-                    f:close()
-                    os.execute(config.task_bin .. " stop")
-                end
-                local line = vim.fn.getline(".")
-                local filepath = string.sub(line, 1, string.find(line, ":") - 1)
-                local linenumber =
-                    string.sub(line, string.find(line, ":") + 1, string.find(line, ":", string.find(line, ":") + 1) - 1)
-                local datetime = os.time()
-                local function trim(s)
-                    return (s:gsub("^%s+", ""):gsub("%s+$", ""))
-                end
-                local task_content = string.match(line, "^.-|.-|.-|(.*)$")
-                task_content = task_content and task_content:match("^(.-)%s*::") or task_content
-                if task_content then
-                    task_content = trim(task_content)
-                end
-                local g, err = io.open(state_path, "w")
-                if not g then
-                    vim.notify("[taskbuffer] failed to write state: " .. err, vim.log.levels.ERROR)
-                    return
-                end
-                g:write(datetime .. "\t" .. task_content .. "\t" .. filepath .. "\t" .. linenumber)
-                g:close()
-                local fmts = config.formats
-                local start_suffix = " " .. fmts.marker_prefix .. "start [["
-                    .. os.date(fmts.date) .. "]] " .. os.date(fmts.time)
-                util.append_to_line(filepath, tonumber(linenumber), start_suffix)
+                util.run_task_cmd(taskfile_task_args("start-at"), true)
             end, { buffer = true, desc = "Start task" })
 
             local function go_to_file()
@@ -429,13 +441,11 @@ function M.setup_keymaps()
             vim.keymap.set("n", "<CR>", go_to_file, { buffer = true, desc = "Go to task source" })
 
             map("n", "taskfile", "irrelevant", function()
-                local filepath, linenumber = get_task_location_from_taskfile()
-                util.run_task_cmd({ "irrelevant", filepath, tostring(linenumber) }, true)
+                util.run_task_cmd(taskfile_task_args("irrelevant"), true)
             end, { buffer = true })
 
             map("n", "taskfile", "undo_irrelevant", function()
-                local filepath, linenumber = get_task_location_from_taskfile()
-                util.run_task_cmd({ "unset", filepath, tostring(linenumber) }, true)
+                util.run_task_cmd(taskfile_task_args("unset"), true)
             end, { buffer = true })
 
             map("n", "taskfile", "filter_tags", function()
@@ -484,6 +494,16 @@ function M.setup_keymaps()
                 set_date_today_in_taskfile()
             end, { buffer = true, desc = "Set task date to today" })
 
+            map("n", "taskfile", "set_priority", function()
+                local args = taskfile_task_args("edit")
+                vim.ui.select({ "high", "medium", "low", "none" }, { prompt = "Priority" }, function(choice)
+                    if choice then
+                        vim.list_extend(args, { "--priority", choice })
+                        util.run_task_cmd(args, true)
+                    end
+                end)
+            end, { buffer = true, desc = "Set task priority" })
+
             map("v", "taskfile", "set_date_today", function()
                 local lines = util.get_visual_lines()
                 vim.api.nvim_feedkeys(vim.api.nvim_replace_termcodes("<Esc>", true, false, true), "nx", false)
@@ -11,6 +11,80 @@ function M.parse_taskfile_line(line)
     return filepath, linenumber
 end
 
+--- Body of the task on a taskfile line: the column between the duration and
+--- the tags, without the indent that nests subtasks under their parent.
+---@param line string
+---@return string|nil
+function M.taskfile_line_body(line)
+    return line:match("|\t %s*(.-) \t")
+end
+
+--- Fingerprint of the task on a taskfile line, for `--expect`: the first 12
+--- hex digits of the SHA-256 of its status and body. The taskfile only lists
+--- open tasks.
+---@param line string
+---@return string|nil
+function M.taskfile_line_fingerprint(line)
+    local body = M.taskfile_line_body(line)
+    if not body then
+        return nil
+    end
+    return vim.fn.sha256("open " .. body):sub(1, 12)
+end
+
+--- Width of the leading whitespace of a line, tabs advancing to the next
+--- multiple of 4 (as the Go binary nests subtasks).
+---@param line string
+---@return integer
+local function indent_width(line)
+    local col = 0
+    for c in line:gmatch(".") do
+        if c == " " then
+            col = col + 1
+        elseif c == "\t" then
+            col = col + 4 - col % 4
+        else
+            break
+        end
+    end
+    return col
+end
+
+--- Count the open subtasks nested under the task on line `target`: the task
+--- lines below it up to the next one that is not indented further.
+---@param lines string[]
+---@param target integer
+---@param checkbox TaskbufferCheckbox
+---@return integer
+function M.open_subtask_count(lines, target, checkbox)
+    local function checkbox_of(l)
+        local rest = l:gsub("^%s+", "")
+        for _, cb in pairs(checkbox) do
+            if rest:sub(1, #cb + 1) == cb .. " " then
+                return cb
+            end
+        end
+        return nil
+    end
+    if not lines[target] then
+        return 0
+    end
+    local indent = indent_width(lines[target])
+    local count = 0
+    for i = target + 1, #lines do
+        local cb = checkbox_of(lines[i])
+        if cb then
+            if indent_width(lines[i]) <= indent then
+                break
+            end
+            if cb == checkbox.open then
+                count = count + 1
+            end
+        end
+    end
+    return count
+end
+
 --- Read a specific line from a file on disk.
 ---@param path string
 ---@param target integer
@@ -419,16 +493,34 @@ function M.taskfile_lines_to_qf(lines)
 end
 
 --- Run a Go binary command and optionally refresh the taskfile buffer.
+--- The config is passed along, so the binary parses lines (and checks
+--- --expect fingerprints) with the same formats the taskfile was listed with.
 ---@param args string[]
 ---@param refresh boolean
 ---@return boolean success
 function M.run_task_cmd(args, refresh)
-    local config = require("taskbuffer.config").values
-    local cmd = { config.task_bin }
+    local config = require("taskbuffer.config")
+    local cmd = { config.values.task_bin, "--config", config.config_json_arg() }
     for _, a in ipairs(args) do
         table.insert(cmd, a)
     end
     local result = vim.system(cmd, { text = true }):wait()
+    if result.code == 3 or result.code == 4 then
+        -- The task is no longer on the line the taskfile points at; the file
+        -- was left alone. 3: several lines now hold it, 4: none does.
+        if result.code == 3 then
+            vim.notify(
+                "[taskbuffer] task is ambiguous, refresh and pick the line to change: " .. (result.stderr or ""),
+                vim.log.levels.ERROR
+            )
+        else
+            vim.notify("[taskbuffer] task moved or not found: " .. (result.stderr or ""), vim.log.levels.ERROR)
+        end
+        if refresh then
+            require("taskbuffer.buffer").refresh_and_restore_cursor()
+        end
+        return false
+    end
     if result.code ~= 0 then
         vim.notify("[taskbuffer] task command failed: " .. (result.stderr or ""), vim.log.levels.ERROR)
         return false
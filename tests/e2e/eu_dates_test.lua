@@ -1,5 +1,6 @@
 -- E2E test: European date format with simple wrapper.
--- Verifies config handoff for formats.date and 2-element date_wrapper.
+-- Verifies config handoff for formats.date and 2-element date_wrapper, both
+-- to `task list` and to the global complete and check-off keymaps.
 
 vim.opt.rtp:prepend("/plugin")
 vim.opt.rtp:prepend("/deps/plenary.nvim")
@@ -21,4 +22,11 @@ h.check_content({
     "Undated EU task",
     "#health",
 })
+
+-- The next occurrence is only found and written in the configured date
+-- format if the keymaps pass the config to the binary
+local notes = "/root/Documents/Notes/eu_dates.md"
+vim.cmd("edit " .. notes)
+h.check_keymap("global", "complete", "Blumen gießen", notes, "- [ ] Blumen gießen every 1w #home {11.03.2026}")
+h.check_keymap("global", "check_off", "Rasen mähen", notes, "- [ ] Rasen mähen every 2w #home {19.03.2026}")
 h.finish()
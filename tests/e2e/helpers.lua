@@ -82,6 +82,36 @@ function M.check_absent(unexpected)
     end
 end
 
+--- Run a keymap's callback with the cursor on the first buffer line
+--- containing needle, then check that file holds expected.
+function M.check_keymap(context, action, needle, file, expected)
+    local ok, err = pcall(function()
+        local lines = vim.api.nvim_buf_get_lines(0, 0, -1, false)
+        local row
+        for i, line in ipairs(lines) do
+            if line:find(needle, 1, true) then
+                row = i
+                break
+            end
+        end
+        if not row then
+            error(needle .. " not found in buffer")
+        end
+        vim.api.nvim_win_set_cursor(0, { row, 0 })
+        local lhs = require("taskbuffer.config").values.keymaps[context][action]
+        local km = vim.fn.maparg(lhs, "n", false, true)
+        if not km.callback then
+            error("no callback mapped to " .. lhs)
+        end
+        km.callback()
+        local content = table.concat(vim.fn.readfile(file), "\n")
+        if not content:find(expected, 1, true) then
+            error(file .. " does not contain " .. expected .. ":\n" .. content)
+        end
+    end)
+    M.check(context .. " " .. action .. " on: " .. needle, ok, err)
+end
+
 function M.finish()
     io.write("\n")
     io.write(string.format("Results: %d passed, %d failed\n", M.passed, M.failed))
@@ -1,5 +1,6 @@
 -- E2E test: minimal date wrapper with custom tag prefix.
--- Verifies config handoff for 2-element date_wrapper and tag_prefix.
+-- Verifies config handoff for 2-element date_wrapper, tag_prefix and
+-- priority_syntax, both to `task list` and to the mutations run from keymaps.
 
 vim.opt.rtp:prepend("/plugin")
 vim.opt.rtp:prepend("/deps/plenary.nvim")
@@ -12,6 +13,7 @@ h.check_setup({
         date_wrapper = { "[", "]" },
         tag_prefix = "@",
     },
+    priority_syntax = { "letter" },
 })
 h.check_health()
 h.check_tasks()
@@ -22,4 +24,15 @@ h.check_content({
     "@books",
     "@work",
 })
+
+-- The --expect fingerprint only matches if the binary parses the line with
+-- the same date wrapper and tag prefix
+local notes = "/root/Documents/Notes/minimal_wrapper.md"
+h.check_keymap("taskfile", "irrelevant", "Submit PR", notes, "- [-] Submit PR @work [2026-03-05]")
+
+-- A priority is written in the first configured syntax
+vim.ui.select = function(_, _, on_choice)
+    on_choice("high")
+end
+h.check_keymap("taskfile", "set_priority", "Read chapter 3", notes, "- [ ] Read chapter 3 @books (A) [2026-03-04]")
 h.finish()